@@ -135,7 +135,9 @@ function uploadFiles() {
 
     Promise.all(promises)
         .then(results => {
-            showStatusMessage('所有文件上传完成', 'success');
+            // 批量上传返回 files，分片上传返回合并报告，都带有最终路径和冲突处理结果
+            const saved = results.flatMap(result => result.files || []);
+            showUploadNotes(saved);
             fetchFileList(); // 上传完成后刷新文件列表
             // 清空已选择的文件
             selectedFiles = [];
@@ -234,7 +236,13 @@ function uploadLargeFile(file) {
                                 if (currentChunk < chunks) {
                                     uploadNextChunk();
                                 } else {
-                                    resolve({message: `文件 ${file.name} 上传完成`});
+                                    let report = null;
+                                    try {
+                                        report = JSON.parse(xhr.responseText).report;
+                                    } catch (error) {
+                                        // 响应无法解析时只提示上传完成
+                                    }
+                                    resolve({message: `文件 ${file.name} 上传完成`, files: report ? [report] : []});
                                 }
                             } else {
                                 try {
@@ -333,6 +341,36 @@ function showUploadResult(response) {
     statusDiv.innerHTML = message;
 }
 
+// 显示上传完成消息，同名文件被重命名、覆盖（保留历史版本）或跳过时逐个说明
+function showUploadNotes(saved) {
+    const notes = [];
+    saved.forEach(result => {
+        if (result.skipped) {
+            notes.push(`${result.path} 已存在，已跳过`);
+        } else if (result.renamed) {
+            notes.push(`同名文件已存在，另存为 ${result.path}`);
+        } else if (result.versioned) {
+            notes.push(`${result.path} 已覆盖，原内容已保存为历史版本`);
+        }
+    });
+    if (notes.length === 0) {
+        showStatusMessage('所有文件上传完成', 'success');
+        return;
+    }
+
+    // 说明需要用户留意，不自动清除；文件名用 textContent 写入
+    statusDiv.innerHTML = '<div class="success-message">所有文件上传完成</div>';
+    const list = document.createElement('ul');
+    list.className = 'upload-notes';
+    notes.forEach(note => {
+        const item = document.createElement('li');
+        item.textContent = note;
+        list.appendChild(item);
+    });
+    statusDiv.appendChild(list);
+    updateProgress(0);
+}
+
 // 显示状态消息
 function showStatusMessage(message, type = 'info') {
     statusDiv.innerHTML = `<div class="${type}-message">${message}</div>`;
@@ -345,29 +383,53 @@ function showStatusMessage(message, type = 'info') {
     }, 3000);
 }
 
-// 获取文件列表
+// 获取文件列表（根目录）
 function fetchFileList() {
-    const xhr = new XMLHttpRequest();
-    xhr.open('GET', '/files', true);
-    
-    xhr.onreadystatechange = function () {
-        if (xhr.readyState === 4) {
-            if (xhr.status === 200) {
-                try {
-                    const response = JSON.parse(xhr.responseText);
-                    renderFileTree(response.files);
-                } catch (error) {
-                    // 解析失败，静默处理
+    fetchDirectory('')
+        .then(files => renderFileTree(files))
+        .catch(() => {
+            // 请求失败，静默处理
+        });
+}
+
+// 获取单个目录下的全部条目（自动跟随分页游标）
+function fetchDirectory(path, cursor = '', collected = []) {
+    return new Promise((resolve, reject) => {
+        const params = new URLSearchParams({ path: path, depth: 1, limit: 500 });
+        if (cursor) {
+            params.set('cursor', cursor);
+        }
+
+        const xhr = new XMLHttpRequest();
+        xhr.open('GET', `/files?${params.toString()}`, true);
+        
+        xhr.onreadystatechange = function () {
+            if (xhr.readyState !== 4) {
+                return;
+            }
+            if (xhr.status !== 200) {
+                reject(new Error(`获取文件列表失败，状态码: ${xhr.status}`));
+                return;
+            }
+            try {
+                const response = JSON.parse(xhr.responseText);
+                const files = collected.concat(response.files || []);
+                if (response.next_cursor) {
+                    fetchDirectory(path, response.next_cursor, files).then(resolve, reject);
+                } else {
+                    resolve(files);
                 }
+            } catch (error) {
+                reject(error);
             }
-        }
-    };
-    
-    xhr.addEventListener('error', function() {
-        // 网络错误，静默处理
+        };
+        
+        xhr.addEventListener('error', function() {
+            reject(new Error('网络请求出错'));
+        });
+        
+        xhr.send();
     });
-    
-    xhr.send();
 }
 
 // 渲染文件树
@@ -418,28 +480,41 @@ function createFileTreeItem(file) {
     li.appendChild(nameSpan);
     li.appendChild(infoSpan);
     
-    // 如果是文件夹且有子项，递归创建子项
-    if (file.is_dir && file.children && file.children.length > 0) {
+    // 如果是文件夹，点击时按需加载子项
+    if (file.is_dir) {
         const childrenUl = document.createElement('ul');
         childrenUl.className = 'file-tree-children';
         
-        file.children.forEach(child => {
-            const childItem = createFileTreeItem(child);
-            childrenUl.appendChild(childItem);
-        });
+        let loaded = false;
+        let expanded = false;
         
         // 添加点击事件折叠/展开
-        let expanded = false;
         li.addEventListener('click', function(e) {
             e.stopPropagation();
             expanded = !expanded;
-            if (expanded) {
-                childrenUl.style.display = 'block';
-                icon.className = 'file-tree-item-icon fas fa-folder-open';
-            } else {
+            if (!expanded) {
                 childrenUl.style.display = 'none';
                 icon.className = 'file-tree-item-icon fas fa-folder';
+                return;
+            }
+
+            childrenUl.style.display = 'block';
+            icon.className = 'file-tree-item-icon fas fa-folder-open';
+            if (loaded) {
+                return;
             }
+
+            loaded = true;
+            fetchDirectory(file.path)
+                .then(children => {
+                    childrenUl.innerHTML = '';
+                    children.forEach(child => {
+                        childrenUl.appendChild(createFileTreeItem(child));
+                    });
+                })
+                .catch(() => {
+                    loaded = false;
+                });
         });
         
         childrenUl.style.display = 'none';
@@ -3,22 +3,296 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Storage backends selectable with LFS_STORAGE_BACKEND.
+const (
+	BackendFS    = "fs"    // Plain file tree
+	BackendDedup = "dedup" // File tree whose identical files share one content-addressed blob
+)
+
+// Upload conflict policies selectable with LFS_CONFLICT_POLICY.
+const (
+	ConflictOverwrite = "overwrite" // Replace the existing file
+	ConflictRename    = "rename"    // Store the upload as "name (1).ext"
+	ConflictSkip      = "skip"      // Keep the existing file and drop the upload
+	ConflictFail      = "fail"      // Reject the upload
+)
+
+// Default janitor settings.
+const (
+	DefaultJanitorInterval = time.Hour
+	DefaultJanitorMaxAge   = 24 * time.Hour
+)
+
+// DefaultJobWorkers is the default number of background jobs run at the same time.
+const DefaultJobWorkers = 3
+
+// Default scrub settings.
+const (
+	DefaultScrubInterval = 7 * 24 * time.Hour
+	DefaultScrubRate     = 50 // MB/s
+)
+
+// Default version retention.
+const (
+	DefaultVersionKeep   = 10
+	DefaultVersionMaxAge = 30 * 24 * time.Hour
+)
+
+// DefaultTrashMaxAge is how long deleted files stay in the trash by default.
+const DefaultTrashMaxAge = 30 * 24 * time.Hour
+
+// Default storage guardrails.
+const (
+	DefaultMinFree           = 1 << 30 // 1 GiB
+	DefaultUsageScanInterval = 5 * time.Minute
 )
 
 // Config represents the application configuration.
 type Config struct {
-	StoragePath string `json:"storage_path"` // File storage path
+	StoragePath     string        `json:"storage_path"`     // File storage path
+	StorageBackend  string        `json:"storage_backend"`  // Storage backend: fs or dedup
+	JanitorInterval time.Duration `json:"janitor_interval"` // How often abandoned uploads are cleaned up
+	JanitorMaxAge   time.Duration `json:"janitor_max_age"`  // Age after which untouched upload state is considered abandoned
+	JobWorkers      int           `json:"job_workers"`      // Number of background jobs (hashing, scrubbing) run at the same time
+	ScrubInterval   time.Duration `json:"scrub_interval"`   // How often stored files are re-verified against their checksums
+	ScrubRate       int           `json:"scrub_rate"`       // Maximum scrub read rate in MB/s
+	Versioning      bool          `json:"versioning"`       // Keep the previous content of overwritten files
+	VersionKeep     int           `json:"version_keep"`     // Number of versions kept per file
+	VersionMaxAge   time.Duration `json:"version_max_age"`  // Age after which versions are pruned
+	TrashMaxAge     time.Duration `json:"trash_max_age"`    // Age after which deleted files are purged from the trash
+	Trash           bool          `json:"trash"`            // Move deleted files to the trash instead of removing them
+	HashOnWrite     bool          `json:"hash_on_write"`    // Compute digests while uploads are written
+	ConflictPolicy  string        `json:"conflict_policy"`  // Conflict policy of uploads that do not choose one
+
+	Quota             int64            `json:"quota"`               // Maximum bytes stored in total, 0 for no limit
+	DirQuotas         map[string]int64 `json:"dir_quotas"`          // Maximum bytes per top-level directory
+	MinFree           int64            `json:"min_free"`            // Free space kept on the filesystem, uploads are refused below it
+	UsageScanInterval time.Duration    `json:"usage_scan_interval"` // How often directory usage is measured for quotas
+
+	Users             map[string]string `json:"-"`                   // Basic auth passwords by user name, plain or "sha256:<hex>"
+	Admins            []string          `json:"admins"`              // Users allowed to force unlock other users' locks
+	TrustedUserHeader string            `json:"trusted_user_header"` // Header carrying the user name set by a trusted proxy
+	TrustedProxies    []string          `json:"trusted_proxies"`     // Proxy addresses or CIDRs allowed to set TrustedUserHeader
 }
 
 // LoadConfig loads configuration from environment variables.
 // If LFS_STORAGE_PATH is not set, uses default path "$HOME/Downloads/".
+// LFS_JANITOR_INTERVAL and LFS_JANITOR_MAX_AGE accept Go durations such as "30m" or "48h".
+// LFS_JOB_WORKERS sets how many background jobs run at the same time.
+// LFS_SCRUB_INTERVAL is a Go duration, LFS_SCRUB_RATE the scrub read rate in MB/s.
+// LFS_STORAGE_BACKEND selects the storage backend (fs or dedup, default fs).
+// LFS_VERSIONING enables version history; LFS_VERSION_KEEP and LFS_VERSION_MAX_AGE
+// set how many versions are kept per file and for how long.
+// LFS_CONFLICT_POLICY is the conflict policy of uploads that do not send one (overwrite,
+// rename, skip or fail); it defaults to overwrite with versioning, so replaced content is
+// kept as a version, and to rename without it.
+// LFS_TRASH_MAX_AGE sets how long deleted files stay in the trash; LFS_TRASH=false deletes files
+// immediately. LFS_HASH_ON_WRITE=false stops hashing uploads while they are written.
+// LFS_QUOTA and LFS_MIN_FREE are sizes such as "500G" or "1073741824" (0 disables them);
+// LFS_DIR_QUOTAS lists top-level directory quotas as "photos=100G,backups=1T".
+// LFS_USAGE_SCAN_INTERVAL sets how often directory usage is measured.
+// LFS_USERS lists Basic auth users as "alice:secret,bob:sha256:<hex>"; LFS_ADMINS names
+// the users allowed to force unlock. LFS_TRUSTED_USER_HEADER (e.g. "X-Forwarded-User")
+// is only honoured for requests from LFS_TRUSTED_PROXIES, a list of addresses or CIDRs.
 func LoadConfig() Config {
 	storagePath := os.Getenv("LFS_STORAGE_PATH")
 	if storagePath == "" {
 		storagePath = "$HOME/Downloads/"
 		fmt.Printf("STORAGE_PATH not set, using default: %s\n", storagePath)
 	}
+	backend := os.Getenv("LFS_STORAGE_BACKEND")
+	switch backend {
+	case BackendFS, BackendDedup:
+	case "":
+		backend = BackendFS
+	default:
+		fmt.Printf("Invalid LFS_STORAGE_BACKEND %q, using default: %s\n", backend, BackendFS)
+		backend = BackendFS
+	}
+	versioning := boolFromEnv("LFS_VERSIONING", false)
+	conflictPolicy := strings.ToLower(strings.TrimSpace(os.Getenv("LFS_CONFLICT_POLICY")))
+	switch conflictPolicy {
+	case ConflictOverwrite, ConflictRename, ConflictSkip, ConflictFail:
+	default:
+		def := ConflictRename
+		if versioning {
+			def = ConflictOverwrite
+		}
+		if conflictPolicy != "" {
+			fmt.Printf("Invalid LFS_CONFLICT_POLICY %q, using default: %s\n", conflictPolicy, def)
+		}
+		conflictPolicy = def
+	}
 	return Config{
-		StoragePath: storagePath,
+		StoragePath:     storagePath,
+		StorageBackend:  backend,
+		JanitorInterval: durationFromEnv("LFS_JANITOR_INTERVAL", DefaultJanitorInterval),
+		JanitorMaxAge:   durationFromEnv("LFS_JANITOR_MAX_AGE", DefaultJanitorMaxAge),
+		JobWorkers:      intFromEnv("LFS_JOB_WORKERS", DefaultJobWorkers),
+		ScrubInterval:   durationFromEnv("LFS_SCRUB_INTERVAL", DefaultScrubInterval),
+		ScrubRate:       intFromEnv("LFS_SCRUB_RATE", DefaultScrubRate),
+		Versioning:      versioning,
+		VersionKeep:     intFromEnv("LFS_VERSION_KEEP", DefaultVersionKeep),
+		VersionMaxAge:   durationFromEnv("LFS_VERSION_MAX_AGE", DefaultVersionMaxAge),
+		TrashMaxAge:     durationFromEnv("LFS_TRASH_MAX_AGE", DefaultTrashMaxAge),
+		Trash:           boolFromEnv("LFS_TRASH", true),
+		HashOnWrite:     boolFromEnv("LFS_HASH_ON_WRITE", true),
+		ConflictPolicy:  conflictPolicy,
+
+		Quota:             sizeFromEnv("LFS_QUOTA", 0),
+		DirQuotas:         dirQuotasFromEnv("LFS_DIR_QUOTAS"),
+		MinFree:           sizeFromEnv("LFS_MIN_FREE", DefaultMinFree),
+		UsageScanInterval: durationFromEnv("LFS_USAGE_SCAN_INTERVAL", DefaultUsageScanInterval),
+
+		Users:             usersFromEnv("LFS_USERS"),
+		Admins:            listFromEnv("LFS_ADMINS"),
+		TrustedUserHeader: strings.TrimSpace(os.Getenv("LFS_TRUSTED_USER_HEADER")),
+		TrustedProxies:    listFromEnv("LFS_TRUSTED_PROXIES"),
+	}
+}
+
+// durationFromEnv reads a positive duration from an environment variable,
+// falling back to def when it is unset or invalid.
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		fmt.Printf("Invalid %s %q, using default: %s\n", key, value, def)
+		return def
+	}
+	return d
+}
+
+// intFromEnv reads a positive integer from an environment variable,
+// falling back to def when it is unset or invalid.
+func intFromEnv(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		fmt.Printf("Invalid %s %q, using default: %d\n", key, value, def)
+		return def
+	}
+	return n
+}
+
+// boolFromEnv reads a boolean ("true", "1", "false", ...) from an environment variable,
+// falling back to def when it is unset or invalid.
+func boolFromEnv(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		fmt.Printf("Invalid %s %q, using default: %t\n", key, value, def)
+		return def
+	}
+	return b
+}
+
+// sizeFromEnv reads a non-negative size from an environment variable,
+// falling back to def when it is unset or invalid.
+func sizeFromEnv(key string, def int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := parseSize(value)
+	if err != nil {
+		fmt.Printf("Invalid %s %q, using default: %d\n", key, value, def)
+		return def
+	}
+	return n
+}
+
+// dirQuotasFromEnv reads comma separated "dir=size" quotas from an environment
+// variable. Only top-level directories can have a quota; invalid entries are skipped.
+func dirQuotasFromEnv(key string) map[string]int64 {
+	quotas := make(map[string]int64)
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		dir, value, ok := strings.Cut(entry, "=")
+		dir = strings.Trim(strings.TrimSpace(dir), "/")
+		n, err := parseSize(value)
+		if !ok || dir == "" || strings.Contains(dir, "/") || dir == "." || dir == ".." || err != nil {
+			fmt.Printf("Invalid %s entry %q, skipping\n", key, entry)
+			continue
+		}
+		quotas[dir] = n
+	}
+	return quotas
+}
+
+// usersFromEnv reads comma separated "user:password" entries from an environment
+// variable. Entries without a user name or password are skipped.
+func usersFromEnv(key string) map[string]string {
+	users := make(map[string]string)
+	for _, entry := range listFromEnv(key) {
+		user, password, ok := strings.Cut(entry, ":")
+		if !ok || user == "" || password == "" {
+			fmt.Printf("Invalid %s entry for %q, skipping\n", key, user)
+			continue
+		}
+		users[user] = password
+	}
+	return users
+}
+
+// listFromEnv reads a comma separated list from an environment variable,
+// dropping empty entries.
+func listFromEnv(key string) []string {
+	var list []string
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// sizeUnits are the size suffixes accepted by parseSize, in powers of 1024.
+var sizeUnits = map[string]int64{
+	"":  1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+// parseSize parses a non-negative byte count with an optional K, M, G or T suffix
+// (powers of 1024; "KB", "KiB" and lowercase forms are accepted as well).
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if strings.HasSuffix(s, "IB") {
+		s = strings.TrimSuffix(s, "IB")
+	} else {
+		s = strings.TrimSuffix(s, "B")
+	}
+	i := len(s)
+	for i > 0 && (s[i-1] < '0' || s[i-1] > '9') {
+		i--
+	}
+	unit, ok := sizeUnits[strings.TrimSpace(s[i:])]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q", s[i:])
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(s[:i]), 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)/unit {
+		return 0, fmt.Errorf("invalid size %q", s)
 	}
+	return n * unit, nil
 }
@@ -1,10 +1,15 @@
 package app
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
 	"embed"
+	"encoding/hex"
 	"log"
 	"net"
 	"net/http"
+	"path/filepath"
 	"strings"
 
 	"lfs/config"
@@ -22,15 +27,36 @@ import (
 // App represents the core application structure.
 // It uses dependency injection to assemble all components including services, handlers, and HTTP server.
 type App struct {
-	config         config.Config
-	fileService    interfaces.FileService
-	chatService    interfaces.ChatService
-	metricsService interfaces.MetricsService
-	staticService  interfaces.StaticFileService
-	fileHandlers   *handlers.FileHandlers
-	chatHandlers   *handlers.ChatHandlers
-	router         *gin.Engine
-	server         *http.Server
+	config          config.Config
+	fileService     interfaces.FileService
+	chatService     interfaces.ChatService
+	metricsService  interfaces.MetricsService
+	staticService   interfaces.StaticFileService
+	lfsService      interfaces.LFSService
+	lockService     interfaces.LockService
+	uploadService   interfaces.UploadService
+	tusService      interfaces.TusService
+	janitorService  interfaces.JanitorService
+	jobService      interfaces.JobService
+	eventService    interfaces.EventService
+	scrubService    interfaces.ScrubService
+	storageService  interfaces.StorageService
+	versionService  interfaces.VersionService
+	trashService    interfaces.TrashService
+	fileHandlers    *handlers.FileHandlers
+	chatHandlers    *handlers.ChatHandlers
+	lfsHandlers     *handlers.LFSHandlers
+	lockHandlers    *handlers.LockHandlers
+	uploadHandlers  *handlers.UploadHandlers
+	tusHandlers     *handlers.TusHandlers
+	jobHandlers     *handlers.JobHandlers
+	eventHandlers   *handlers.EventHandlers
+	scrubHandlers   *handlers.ScrubHandlers
+	storageHandlers *handlers.StorageHandlers
+	versionHandlers *handlers.VersionHandlers
+	trashHandlers   *handlers.TrashHandlers
+	router          *gin.Engine
+	server          *http.Server
 }
 
 // NewApp creates and initializes a new application instance.
@@ -43,33 +69,120 @@ func NewApp(cfg config.Config, staticFiles embed.FS) *App {
 	// Initialize static file service (subPath is "web/static" because embed path is "web/static/*")
 	staticService := static.NewService(staticFiles, "web/static", compressor)
 
-	// Initialize MD5 cache
-	md5Cache := storage.NewMD5CacheAdapter()
+	// Initialize background job service; MD5 values are calculated on it
+	jobService := services.NewJobService(cfg.JobWorkers)
+
+	// Initialize event service for hash, upload and file change notifications
+	eventService := services.NewEventService()
+
+	// Initialize MD5 cache, reusing hashes persisted by earlier runs
+	md5Cache, err := storage.NewMD5CacheAdapter(cfg.StoragePath, jobService, eventService)
+	if err != nil {
+		log.Printf("Failed to load hash index, MD5 values will be recalculated: %v", err)
+	}
 
 	// Initialize storage adapter
-	storageAdapter := storage.NewStorageAdapter(cfg.StoragePath, md5Cache)
+	storageAdapter := storage.NewStorageAdapter(cfg.StoragePath, md5Cache, cfg.HashOnWrite, cfg.Trash, eventService)
+
+	// File routes and uploads go through the configured backend; maintenance
+	// (cleanup, scrubbing) works on the underlying file tree either way
+	var fileStorage interfaces.Storage = storageAdapter
+	var trash interfaces.Trasher = storageAdapter
+	var dedup interfaces.Deduplicator
+	if cfg.StorageBackend == config.BackendDedup {
+		dedupStorage, err := storage.NewDedupStorage(storageAdapter)
+		if err != nil {
+			log.Fatalf("Failed to load dedup index: %v", err)
+		}
+		fileStorage, trash, dedup = dedupStorage, dedupStorage, dedupStorage
+	}
+
+	// Version history wraps whichever backend is in use
+	var versions interfaces.Versioner
+	if cfg.Versioning {
+		versionedStorage, err := storage.NewVersionedStorage(fileStorage, cfg.StoragePath, cfg.VersionKeep, cfg.VersionMaxAge)
+		if err != nil {
+			log.Fatalf("Failed to load version index: %v", err)
+		}
+		fileStorage, versions = versionedStorage, versionedStorage
+	}
+
+	// Initialize file hasher (MD5, SHA-1, SHA-256, BLAKE3, CRC32C)
+	fileHasher := storage.NewFileHasherAdapter(cfg.StoragePath, md5Cache)
+
+	// Initialize lock store
+	lockStore, err := storage.NewLockStore(cfg.StoragePath)
+	if err != nil {
+		log.Fatalf("Failed to load lock store: %v", err)
+	}
+
+	// Initialize upload session store
+	uploadStore, err := storage.NewUploadStore(cfg.StoragePath)
+	if err != nil {
+		log.Fatalf("Failed to load upload sessions: %v", err)
+	}
+
+	// Initialize tus upload store
+	tusStore, err := storage.NewTusStore(cfg.StoragePath)
+	if err != nil {
+		log.Fatalf("Failed to load tus uploads: %v", err)
+	}
 
-	// Initialize MD5 calculator
-	md5Calculator := storage.NewMD5CalculatorAdapter(cfg.StoragePath, md5Cache)
+	// Internal storage for LFS objects and upload data, hidden from file routes.
+	// Its files never appear in listings, so they are not hashed on write and publish no events.
+	internalStorage := storage.NewStorageAdapter(filepath.Join(cfg.StoragePath, storage.InternalDirName), md5Cache, false, false, nil)
 
 	// Initialize service layer
-	fileService := services.NewFileService(storageAdapter, md5Calculator, cfg.StoragePath)
-	chatService := services.NewChatService()
+	lockService := services.NewLockService(lockStore)
+	storageService := services.NewStorageService(dedup, storageAdapter, uploadStore, tusStore, services.QuotaLimits{
+		Total:       cfg.Quota,
+		Directories: cfg.DirQuotas,
+		MinFree:     cfg.MinFree,
+	}, cfg.UsageScanInterval)
+	fileService := services.NewFileService(fileStorage, fileHasher, lockService, storageService, cfg.StoragePath)
+	chatService := services.NewChatService(eventService)
 	metricsService := services.NewMetricsService()
+	lfsService := services.NewLFSService(internalStorage)
+	uploadService := services.NewUploadService(uploadStore, fileStorage, internalStorage, lockService, storageService, eventService)
+	tusService := services.NewTusService(tusStore, fileStorage, internalStorage, lockService, storageService, eventService)
+	janitorService := services.NewJanitorService(storageAdapter, versions, uploadStore, tusStore, metricsService, cfg.JanitorInterval, cfg.JanitorMaxAge)
+	versionService := services.NewVersionService(versions, lockService)
+	trashService := services.NewTrashService(trash, lockService, metricsService, cfg.JanitorInterval, cfg.TrashMaxAge)
+	scrubService := services.NewScrubService(storageAdapter, jobService, metricsService, eventService, cfg.ScrubInterval, int64(cfg.ScrubRate)*1024*1024)
 
 	// Initialize handlers
-	fileHandlers := handlers.NewFileHandlers(fileService)
+	fileHandlers := handlers.NewFileHandlers(fileService, versionService, storageService, interfaces.ConflictPolicy(cfg.ConflictPolicy))
 	chatHandlers := handlers.NewChatHandlers(chatService)
+	lfsHandlers := handlers.NewLFSHandlers(lfsService)
+	lockHandlers := handlers.NewLockHandlers(lockService)
+	uploadHandlers := handlers.NewUploadHandlers(uploadService)
+	tusHandlers := handlers.NewTusHandlers(tusService)
+	jobHandlers := handlers.NewJobHandlers(jobService)
+	eventHandlers := handlers.NewEventHandlers(eventService)
+	scrubHandlers := handlers.NewScrubHandlers(scrubService)
+	storageHandlers := handlers.NewStorageHandlers(storageService)
+	versionHandlers := handlers.NewVersionHandlers(versionService)
+	trashHandlers := handlers.NewTrashHandlers(trashService)
 
 	// Create Gin engine
 	router := gin.New()
 
 	// Apply middleware
-	setupMiddleware(router, staticService, compressor)
+	setupMiddleware(router, cfg, staticService, compressor)
 
 	// Register routes
 	fileHandlers.Register(router)
 	chatHandlers.Register(router)
+	lfsHandlers.Register(router)
+	lockHandlers.Register(router)
+	uploadHandlers.Register(router)
+	tusHandlers.Register(router)
+	jobHandlers.Register(router)
+	eventHandlers.Register(router)
+	scrubHandlers.Register(router)
+	storageHandlers.Register(router)
+	versionHandlers.Register(router)
+	trashHandlers.Register(router)
 	setupStaticRoutes(router, staticService)
 	setupMetricsRoute(router, metricsService)
 
@@ -86,15 +199,36 @@ func NewApp(cfg config.Config, staticFiles embed.FS) *App {
 	})
 
 	return &App{
-		config:         cfg,
-		fileService:    fileService,
-		chatService:    chatService,
-		metricsService: metricsService,
-		staticService:  staticService,
-		fileHandlers:   fileHandlers,
-		chatHandlers:   chatHandlers,
-		router:         router,
-		server:         server,
+		config:          cfg,
+		fileService:     fileService,
+		chatService:     chatService,
+		metricsService:  metricsService,
+		staticService:   staticService,
+		lfsService:      lfsService,
+		lockService:     lockService,
+		uploadService:   uploadService,
+		tusService:      tusService,
+		janitorService:  janitorService,
+		jobService:      jobService,
+		eventService:    eventService,
+		scrubService:    scrubService,
+		storageService:  storageService,
+		versionService:  versionService,
+		trashService:    trashService,
+		fileHandlers:    fileHandlers,
+		chatHandlers:    chatHandlers,
+		lfsHandlers:     lfsHandlers,
+		lockHandlers:    lockHandlers,
+		uploadHandlers:  uploadHandlers,
+		tusHandlers:     tusHandlers,
+		jobHandlers:     jobHandlers,
+		eventHandlers:   eventHandlers,
+		scrubHandlers:   scrubHandlers,
+		storageHandlers: storageHandlers,
+		versionHandlers: versionHandlers,
+		trashHandlers:   trashHandlers,
+		router:          router,
+		server:          server,
 	}
 }
 
@@ -136,6 +270,26 @@ func (a *App) Run() error {
 		log.Printf("Access the server at: http://%s:%s", host, port)
 	}
 
+	// Run background jobs such as MD5 calculation
+	a.jobService.Start(context.Background())
+	log.Printf("Job manager running with %d workers", a.config.JobWorkers)
+
+	// Clean up abandoned uploads in the background
+	a.janitorService.Start(context.Background())
+	log.Printf("Janitor running every %s, removing upload state untouched for %s", a.config.JanitorInterval, a.config.JanitorMaxAge)
+
+	// Re-verify stored files against their recorded checksums
+	a.scrubService.Start(context.Background())
+	log.Printf("Scrub running every %s at up to %d MB/s", a.config.ScrubInterval, a.config.ScrubRate)
+
+	// Permanently delete trash items once they expire
+	a.trashService.Start(context.Background())
+	log.Printf("Trash keeping deleted files for %s", a.config.TrashMaxAge)
+
+	// Measure directory usage for quotas and /storage/usage
+	a.storageService.Start(context.Background())
+	log.Printf("Storage usage scanned every %s, keeping %d bytes free", a.config.UsageScanInterval, a.config.MinFree)
+
 	log.Println("Static files embedded and cached successfully")
 	log.Println("HTTP/2 and Gzip compression enabled")
 	return a.server.ListenAndServe()
@@ -180,11 +334,12 @@ func getLocalIPs() []string {
 	return ips
 }
 
-// setupMiddleware configures HTTP middleware including logging, recovery, CORS, and gzip compression.
-func setupMiddleware(r *gin.Engine, staticService interfaces.StaticFileService, compressor interfaces.Compressor) {
+// setupMiddleware configures HTTP middleware including logging, recovery, CORS, identity, and gzip compression.
+func setupMiddleware(r *gin.Engine, cfg config.Config, staticService interfaces.StaticFileService, compressor interfaces.Compressor) {
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 	r.Use(corsMiddleware())
+	r.Use(identityMiddleware(cfg))
 	r.Use(gzipMiddleware(compressor, staticService))
 }
 
@@ -209,12 +364,17 @@ func corsMiddleware() gin.HandlerFunc {
 			c.Header("Access-Control-Allow-Origin", "*")
 		}
 
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Range")
+		c.Header("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Range, "+
+			"Content-Range, Content-MD5, Digest, Content-Digest, If-Match, If-None-Match, "+
+			"Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata, Upload-Checksum, Upload-Defer-Length, X-HTTP-Method-Override, X-Requested-With")
+		c.Header("Access-Control-Expose-Headers", "ETag, Last-Modified, Range, Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Checksum-Algorithm, "+
+			"Upload-Offset, Upload-Length, Upload-Metadata, Upload-Expires")
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Max-Age", "86400")
 
-		if c.Request.Method == "OPTIONS" {
+		// Only CORS preflights are answered here; other OPTIONS requests (tus discovery) reach their handlers
+		if c.Request.Method == "OPTIONS" && c.GetHeader("Access-Control-Request-Method") != "" {
 			c.AbortWithStatus(204)
 			return
 		}
@@ -223,6 +383,118 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// identityMiddleware returns a middleware that stores the requesting user in the request context.
+// The user is taken from cfg.TrustedUserHeader when the request comes directly from one of
+// cfg.TrustedProxies, otherwise from HTTP Basic credentials checked against cfg.Users.
+// Requests with wrong credentials are rejected; requests without credentials are unauthenticated.
+func identityMiddleware(cfg config.Config) gin.HandlerFunc {
+	admins := make(map[string]bool, len(cfg.Admins))
+	for _, name := range cfg.Admins {
+		admins[name] = true
+	}
+	proxies := parseTrustedProxies(cfg.TrustedProxies)
+	if cfg.TrustedUserHeader != "" && len(proxies) == 0 {
+		log.Printf("LFS_TRUSTED_USER_HEADER is set without LFS_TRUSTED_PROXIES, ignoring %s", cfg.TrustedUserHeader)
+	}
+	if len(cfg.Users) == 0 && len(proxies) == 0 {
+		log.Printf("No users configured, requests are unauthenticated and cannot create locks")
+	}
+
+	return func(c *gin.Context) {
+		var name string
+		if cfg.TrustedUserHeader != "" && isTrustedProxy(c.Request.RemoteAddr, proxies) {
+			name = strings.TrimSpace(c.Request.Header.Get(cfg.TrustedUserHeader))
+		}
+		if user, password, ok := c.Request.BasicAuth(); name == "" && ok && len(cfg.Users) > 0 {
+			stored, known := cfg.Users[user]
+			if !known || !checkPassword(stored, password) {
+				c.Header("LFS-Authenticate", `Basic realm="Git LFS"`)
+				c.Header("WWW-Authenticate", `Basic realm="Git LFS"`)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Invalid credentials"})
+				return
+			}
+			name = user
+		}
+
+		identity := interfaces.Identity{Name: name, Admin: name != "" && admins[name]}
+		c.Request = c.Request.WithContext(interfaces.WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+// checkPassword compares a password with a configured one, either plain or "sha256:<hex>",
+// in constant time.
+func checkPassword(stored, password string) bool {
+	sum := sha256.Sum256([]byte(password))
+	if digest, ok := strings.CutPrefix(stored, "sha256:"); ok {
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(digest))) == 1
+	}
+	storedSum := sha256.Sum256([]byte(stored))
+	return subtle.ConstantTimeCompare(sum[:], storedSum[:]) == 1
+}
+
+// parseTrustedProxies parses proxy addresses and CIDRs, skipping invalid entries.
+func parseTrustedProxies(entries []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		cidr := entry
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Printf("Invalid trusted proxy %q, skipping", entry)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// isTrustedProxy reports whether the direct peer of a request is one of the trusted proxies.
+// Forwarding headers such as X-Forwarded-For are ignored because clients can set them.
+func isTrustedProxy(remoteAddr string, proxies []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range proxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// uncompressedPrefixes lists path prefixes of WebSocket and API endpoints that must not be
+// gzip-encoded by the middleware (they stream their own bodies).
+var uncompressedPrefixes = []string{
+	"/ws/",
+	"/files",
+	"/upload",
+	"/batch-",
+	"/download",
+	"/metrics",
+	"/objects",
+	"/locks",
+	"/directories",
+	"/tus",
+	"/jobs",
+	"/events",
+	"/scrub",
+	"/storage",
+	"/versions",
+	"/trash",
+}
+
 // gzipMiddleware returns a gzip compression middleware.
 // It compresses supported responses but excludes WebSocket and API endpoints.
 func gzipMiddleware(compressor interfaces.Compressor, staticService interfaces.StaticFileService) gin.HandlerFunc {
@@ -230,7 +502,7 @@ func gzipMiddleware(compressor interfaces.Compressor, staticService interfaces.S
 		path := c.Request.URL.Path
 		// WebSocket connections and API endpoints should not be compressed (handled by specific handlers)
 		// Static file service already handles gzip compression
-		if path == "/static/" || path == "/" || strings.HasPrefix(path, "/ws/") || strings.HasPrefix(path, "/files") || strings.HasPrefix(path, "/upload") || strings.HasPrefix(path, "/download") || strings.HasPrefix(path, "/metrics") || path == "/favicon.ico" {
+		if path == "/static/" || path == "/" || path == "/favicon.ico" || hasAnyPrefix(path, uncompressedPrefixes) {
 			c.Next()
 			return
 		}
@@ -248,6 +520,16 @@ func gzipMiddleware(compressor interfaces.Compressor, staticService interfaces.S
 	}
 }
 
+// hasAnyPrefix reports whether path starts with any of the given prefixes.
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // staticFileHandler returns a handler for static file requests.
 // Supports ETag cache validation and gzip compression.
 func staticFileHandler(service interfaces.StaticFileService) gin.HandlerFunc {
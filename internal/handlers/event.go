@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventKeepAlive is how often an SSE comment is sent so proxies keep idle streams open.
+const eventKeepAlive = 30 * time.Second
+
+// EventHandlers handles the server-sent events stream.
+type EventHandlers struct {
+	eventService interfaces.EventService
+}
+
+// NewEventHandlers creates and returns a new event handlers instance.
+func NewEventHandlers(eventService interfaces.EventService) *EventHandlers {
+	return &EventHandlers{
+		eventService: eventService,
+	}
+}
+
+// Register registers event routes.
+func (h *EventHandlers) Register(r *gin.Engine) {
+	r.GET("/events", h.StreamEvents)
+}
+
+// StreamEvents handles GET /events as a text/event-stream.
+// The optional path query parameter (repeatable) limits events to those paths and
+// their children; type is a comma-separated list of event types.
+func (h *EventHandlers) StreamEvents(c *gin.Context) {
+	filter := interfaces.EventFilter{Paths: c.QueryArray("path")}
+	if types := c.Query("type"); types != "" {
+		filter.Types = strings.Split(types, ",")
+	}
+
+	events, unsubscribe := h.eventService.Subscribe(filter)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(eventKeepAlive)
+	defer ticker.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(c.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
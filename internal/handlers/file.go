@@ -2,8 +2,13 @@ package handlers
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -36,16 +41,49 @@ func errorResponse(c *gin.Context, statusCode int, message string) {
 	c.JSON(statusCode, Response{Error: message})
 }
 
+// storageStatusCode maps storage and file service errors to HTTP status codes.
+func storageStatusCode(err error) int {
+	switch {
+	case errors.Is(err, interfaces.ErrInvalidPath), errors.Is(err, interfaces.ErrInvalidCursor),
+		errors.Is(err, interfaces.ErrUnsupportedArchiveFormat), errors.Is(err, interfaces.ErrChunkChecksumAlgorithm),
+		errors.Is(err, interfaces.ErrChunkSizeMismatch), errors.Is(err, interfaces.ErrUnsupportedHashAlgorithm),
+		errors.Is(err, interfaces.ErrInvalidConflictPolicy), errors.Is(err, interfaces.ErrChunkOutOfRange):
+		return http.StatusBadRequest
+	case errors.Is(err, interfaces.ErrChunkChecksumMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, os.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, os.ErrExist), errors.Is(err, interfaces.ErrDirectoryNotEmpty):
+		return http.StatusConflict
+	case errors.Is(err, interfaces.ErrPathLocked):
+		return http.StatusLocked
+	case errors.Is(err, interfaces.ErrQuotaExceeded), errors.Is(err, interfaces.ErrInsufficientSpace):
+		return http.StatusInsufficientStorage
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // FileHandlers handles file-related HTTP requests.
 // It depends on FileService to handle business logic, achieving separation of concerns.
 type FileHandlers struct {
-	fileService interfaces.FileService
+	fileService     interfaces.FileService
+	versionService  interfaces.VersionService
+	quota           interfaces.QuotaChecker
+	defaultConflict interfaces.ConflictPolicy
 }
 
 // NewFileHandlers creates and returns a new file handlers instance.
-func NewFileHandlers(fileService interfaces.FileService) *FileHandlers {
+// versionService serves downloads of earlier versions (?version=N), quota rejects
+// multipart uploads by their Content-Length before the body is read, and
+// defaultConflict applies to uploads that do not choose a conflict policy.
+func NewFileHandlers(fileService interfaces.FileService, versionService interfaces.VersionService, quota interfaces.QuotaChecker,
+	defaultConflict interfaces.ConflictPolicy) *FileHandlers {
 	return &FileHandlers{
-		fileService: fileService,
+		fileService:     fileService,
+		versionService:  versionService,
+		quota:           quota,
+		defaultConflict: defaultConflict,
 	}
 }
 
@@ -54,35 +92,98 @@ func (h *FileHandlers) Register(r *gin.Engine) {
 	r.POST("/upload", h.UploadFile)
 	r.POST("/batch-upload", h.BatchUpload)
 	r.POST("/upload-chunk", h.UploadChunk)
-	r.GET("/download/:filename", h.DownloadFile)
-	r.GET("/download-chunk/:filename", h.DownloadChunk)
+	r.GET("/download/*path", h.DownloadFile)
+	r.HEAD("/download/*path", h.DownloadFile)
+	r.GET("/download-chunk/*path", h.DownloadChunk)
 	r.GET("/batch-download", h.BatchDownload)
+	r.GET("/download-folder/*path", h.DownloadFolder)
 	r.GET("/files", h.ListFiles)
-	r.GET("/file-md5/:filename", h.GetFileMD5)
-	r.GET("/file-md5-progress/:filename", h.GetFileMD5Progress)
+	r.GET("/file-md5/*path", h.GetFileMD5)
+	r.GET("/file-hash/*path", h.GetFileHash)
+	r.GET("/file-md5-progress/*path", h.GetFileMD5Progress)
+	r.DELETE("/files/*path", h.DeleteFile)
+	r.PATCH("/files/*path", h.UpdateFile)
+	r.PUT("/files/*path", h.PutFile)
+	r.POST("/directories/*path", h.CreateDirectory)
+	r.DELETE("/directories/*path", h.DeleteDirectory)
+}
+
+// updateFileRequest is the request body of PATCH /files/*path.
+// Exactly one of Name (rename in place) or Destination (move) must be set.
+type updateFileRequest struct {
+	Name        string `json:"name,omitempty"`
+	Destination string `json:"destination,omitempty"`
+}
+
+// uploadTargetDir returns the upload target directory from the "dir" form field
+// or the X-Target-Dir header; an empty string means the storage root.
+func uploadTargetDir(c *gin.Context) string {
+	if dir := c.PostForm("dir"); dir != "" {
+		return dir
+	}
+	return c.GetHeader("X-Target-Dir")
+}
+
+// uploadConflict returns the conflict policy of an upload from the "conflict" form
+// field or query parameter: overwrite, rename, skip or fail. Uploads that do not
+// choose one use the configured default.
+func (h *FileHandlers) uploadConflict(c *gin.Context) (interfaces.ConflictPolicy, error) {
+	value := c.PostForm("conflict")
+	if value == "" {
+		value = c.Query("conflict")
+	}
+	if strings.TrimSpace(value) == "" && h.defaultConflict != "" {
+		return h.defaultConflict, nil
+	}
+	return interfaces.ParseConflictPolicy(value)
+}
+
+// checkRequestSpace rejects a multipart upload whose Content-Length would exceed the
+// global quota or the minimum free space, before the body is spooled to disk.
+// Directory quotas are checked per file once the form has been parsed.
+func (h *FileHandlers) checkRequestSpace(c *gin.Context) bool {
+	if err := h.quota.CheckUpload(c.Request.Context(), "", c.Request.ContentLength); err != nil {
+		errorResponse(c, storageStatusCode(err), err.Error())
+		return false
+	}
+	return true
 }
 
 // UploadFile handles single file upload requests with resumable transfer support.
+// The response data holds the path the file was finally stored under.
 func (h *FileHandlers) UploadFile(c *gin.Context) {
+	if !h.checkRequestSpace(c) {
+		return
+	}
 	file, err := c.FormFile("file")
 	if err != nil {
 		errorResponse(c, http.StatusBadRequest, "Failed to get file: "+err.Error())
 		return
 	}
+	conflict, err := h.uploadConflict(c)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	rangeHeader := c.GetHeader("Range")
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
-	if err := h.fileService.UploadFile(ctx, file, rangeHeader); err != nil {
+	result, err := h.fileService.UploadFile(ctx, uploadTargetDir(c), file, rangeHeader, conflict)
+	if err != nil {
 		if ctx.Err() != nil {
 			return
 		}
-		errorResponse(c, http.StatusInternalServerError, "Failed to save file: "+err.Error())
+		errorResponse(c, storageStatusCode(err), "Failed to save file: "+err.Error())
 		return
 	}
 
-	successResponse(c, "File uploaded successfully", nil)
+	if result.Skipped {
+		successResponse(c, "File already exists, upload skipped", result)
+		return
+	}
+	successResponse(c, "File uploaded successfully", result)
 }
 
 // UploadChunk handles file chunk upload requests.
@@ -119,32 +220,101 @@ func (h *FileHandlers) UploadChunk(c *gin.Context) {
 		return
 	}
 
+	hashAlgorithm, chunkHash, err := chunkChecksum(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conflict, err := h.uploadConflict(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	chunkInfo := interfaces.FileChunkInfo{
-		FileName:   fileName,
-		TotalSize:  totalSize,
-		ChunkIndex: chunkIndex,
-		ChunkSize:  chunkSize,
-		TotalChunk: totalChunk,
-		MD5:        md5sum,
+		FileName:           path.Join(uploadTargetDir(c), fileName),
+		TotalSize:          totalSize,
+		ChunkIndex:         chunkIndex,
+		ChunkSize:          chunkSize,
+		TotalChunk:         totalChunk,
+		MD5:                md5sum,
+		ChunkHashAlgorithm: hashAlgorithm,
+		ChunkHash:          chunkHash,
+		Conflict:           conflict,
 	}
 
 	ctx := c.Request.Context()
-	if err := h.fileService.UploadFileChunk(ctx, chunkInfo, file); err != nil {
+	report, err := h.fileService.UploadFileChunk(ctx, chunkInfo, file)
+	if err != nil {
 		if ctx.Err() != nil {
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, interfaces.ErrChunkChecksumMismatch) {
+			// Tell the client exactly which chunks to send again
+			retryChunks := []int{chunkIndex}
+			if report != nil {
+				retryChunks = report.RetryChunks
+			}
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":       err.Error(),
+				"code":        "CHUNK_CHECKSUM_MISMATCH",
+				"retryChunks": retryChunks,
+				"report":      report,
+			})
+			return
+		}
+		c.JSON(storageStatusCode(err), gin.H{"error": err.Error(), "report": report})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message":    "Chunk uploaded successfully",
 		"chunkIndex": chunkIndex,
-	})
+		"merged":     report != nil,
+	}
+	if report != nil {
+		response["report"] = report
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// chunkChecksum returns the optional per-chunk checksum of a chunk upload, taken from the
+// chunkHash and chunkHashAlgorithm form fields or, failing that, the Content-Digest header.
+// The algorithm defaults to md5; the returned digest is hex-encoded.
+func chunkChecksum(c *gin.Context) (algorithm, sum string, err error) {
+	if sum = c.PostForm("chunkHash"); sum != "" {
+		algorithm = strings.ToLower(c.DefaultPostForm("chunkHashAlgorithm", "md5"))
+		if _, err := hex.DecodeString(sum); err != nil {
+			return "", "", errors.New("Invalid chunkHash")
+		}
+		return algorithm, strings.ToLower(sum), nil
+	}
+
+	header := c.GetHeader("Content-Digest")
+	if header == "" {
+		return "", "", nil
+	}
+	digests, err := parseDigestHeader(header)
+	if err != nil {
+		return "", "", err
+	}
+	// Prefer the strongest supported algorithm
+	for _, name := range []string{"sha256", "sha1", "md5", "crc32c"} {
+		for _, digest := range digests {
+			if digest.Algorithm == name {
+				return name, hex.EncodeToString(digest.Sum), nil
+			}
+		}
+	}
+	return "", "", interfaces.ErrChunkChecksumAlgorithm
 }
 
 // BatchUpload handles batch file upload requests.
 func (h *FileHandlers) BatchUpload(c *gin.Context) {
+	if !h.checkRequestSpace(c) {
+		return
+	}
 	form, err := c.MultipartForm()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -157,37 +327,116 @@ func (h *FileHandlers) BatchUpload(c *gin.Context) {
 		return
 	}
 
+	conflict, err := h.uploadConflict(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx := c.Request.Context()
-	successCount, errorCount, errors := h.fileService.BatchUpload(ctx, files)
+	results, errors := h.fileService.BatchUpload(ctx, uploadTargetDir(c), files, conflict)
 
+	skippedCount := 0
+	for _, result := range results {
+		if result.Skipped {
+			skippedCount++
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Batch upload completed",
 		"total":         len(files),
-		"success_count": successCount,
-		"error_count":   errorCount,
+		"success_count": len(results) - skippedCount,
+		"skipped_count": skippedCount,
+		"error_count":   len(errors),
 		"errors":        errors,
+		"files":         results,
 	})
 }
 
-// DownloadFile handles file download requests with resumable transfer support.
+// DownloadFile handles GET and HEAD file download requests with resumable transfer
+// support and conditional requests (If-None-Match, If-Modified-Since).
+// The wildcard path may reference files in subdirectories; ?version=N downloads
+// an earlier version of the file.
 func (h *FileHandlers) DownloadFile(c *gin.Context) {
-	filename := c.Param("filename")
+	filename := strings.TrimPrefix(c.Param("path"), "/")
 	rangeHeader := c.GetHeader("Range")
 	ctx := c.Request.Context()
 
-	if err := h.fileService.DownloadFile(ctx, c, filename, rangeHeader); err != nil {
+	var err error
+	statusCode := storageStatusCode
+	if value := c.Query("version"); value != "" {
+		version, ok := parseVersion(value)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version: " + value})
+			return
+		}
+		err = h.versionService.DownloadVersion(ctx, c, filename, version, rangeHeader)
+		statusCode = versionStatusCode
+	} else {
+		err = h.fileService.DownloadFile(ctx, c, filename, rangeHeader)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		if !c.Writer.Written() {
+			c.JSON(statusCode(err), gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// DownloadFolder streams a directory and everything below it as an archive.
+// The format query parameter defaults to zip.
+func (h *FileHandlers) DownloadFolder(c *gin.Context) {
+	dir := strings.TrimPrefix(c.Param("path"), "/")
+
+	name := path.Base(dir)
+	if dir == "" {
+		name = "files"
+	}
+	h.streamArchive(c, []string{dir}, name)
+}
+
+// archiveContentTypes maps archive formats to their response content types.
+var archiveContentTypes = map[string]string{
+	interfaces.ArchiveFormatZip:   "application/zip",
+	interfaces.ArchiveFormatTar:   "application/x-tar",
+	interfaces.ArchiveFormatTarGz: "application/gzip",
+}
+
+// streamArchive writes the given paths to the response as an archive named name.format.
+// The archive is produced while it is sent, so no Content-Length is set; if writing fails
+// midway the archive is left without its trailer and the error is only logged.
+func (h *FileHandlers) streamArchive(c *gin.Context, paths []string, name string) {
+	format := c.DefaultQuery("format", interfaces.ArchiveFormatZip)
+	contentType, ok := archiveContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported archive format: " + format})
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", name, format))
+
+	ctx := c.Request.Context()
+	opts := interfaces.ArchiveOptions{Format: format, Compression: c.Query("compression")}
+	if err := h.fileService.DownloadArchive(ctx, c.Writer, paths, opts); err != nil {
 		if ctx.Err() != nil {
 			return
 		}
 		if !c.Writer.Written() {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			header.Del("Content-Disposition")
+			c.JSON(storageStatusCode(err), gin.H{"error": err.Error()})
+			return
 		}
+		log.Printf("Error: archive download interrupted: %v", err)
 	}
 }
 
 // DownloadChunk handles file chunk download requests.
 func (h *FileHandlers) DownloadChunk(c *gin.Context) {
-	filename := c.Param("filename")
+	filename := strings.TrimPrefix(c.Param("path"), "/")
 
 	chunkIndex, err := strconv.ParseInt(c.Query("chunkIndex"), 10, 64)
 	if err != nil {
@@ -207,12 +456,14 @@ func (h *FileHandlers) DownloadChunk(c *gin.Context) {
 			return
 		}
 		if !c.Writer.Written() {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			c.JSON(storageStatusCode(err), gin.H{"error": err.Error()})
 		}
 	}
 }
 
 // BatchDownload handles batch file download requests.
+// With a format query parameter (zip, tar or tar.gz) the files are streamed as one archive,
+// otherwise only their existence is checked.
 func (h *FileHandlers) BatchDownload(c *gin.Context) {
 	filenames := c.QueryArray("filenames")
 	if len(filenames) == 0 {
@@ -220,6 +471,11 @@ func (h *FileHandlers) BatchDownload(c *gin.Context) {
 		return
 	}
 
+	if c.Query("format") != "" {
+		h.streamArchive(c, filenames, "download")
+		return
+	}
+
 	// Single file: directly return download link
 	if len(filenames) == 1 {
 		// Check if file exists
@@ -300,32 +556,61 @@ func (h *FileHandlers) BatchDownload(c *gin.Context) {
 }
 
 // ListFiles handles file list query requests.
+// Query parameters: path, depth (default 1, 0 = unlimited), limit, cursor, sort (name|size|mtime), order (asc|desc).
 func (h *FileHandlers) ListFiles(c *gin.Context) {
-	pathParam := c.Query("path")
+	opts := interfaces.ListOptions{
+		Path:   c.Query("path"),
+		Depth:  1,
+		Cursor: c.Query("cursor"),
+		Sort:   c.DefaultQuery("sort", "name"),
+		Order:  c.DefaultQuery("order", "asc"),
+	}
 
-	if pathParam != "" && strings.Contains(pathParam, "..") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path"})
+	if depth := c.Query("depth"); depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid depth"})
+			return
+		}
+		opts.Depth = n
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		opts.Limit = n
+	}
+
+	if opts.Sort != "name" && opts.Sort != "size" && opts.Sort != "mtime" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort"})
+		return
+	}
+	if opts.Order != "asc" && opts.Order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order"})
 		return
 	}
 
 	ctx := c.Request.Context()
-	files, err := h.fileService.ListFiles(ctx, pathParam)
+	list, err := h.fileService.ListFiles(ctx, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(storageStatusCode(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"files": files})
+	c.JSON(http.StatusOK, list)
 }
 
 // GetFileMD5 handles file MD5 query requests.
 func (h *FileHandlers) GetFileMD5(c *gin.Context) {
-	filename := c.Param("filename")
+	filename := strings.TrimPrefix(c.Param("path"), "/")
 	ctx := c.Request.Context()
 
 	md5sum, err := h.fileService.GetFileMD5(ctx, filename)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(storageStatusCode(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -335,9 +620,32 @@ func (h *FileHandlers) GetFileMD5(c *gin.Context) {
 	})
 }
 
+// GetFileHash handles multi-algorithm digest requests.
+// The algo query parameter is a comma-separated list (md5, sha1, sha256, blake3, crc32c)
+// and defaults to sha256; digests are hex-encoded.
+func (h *FileHandlers) GetFileHash(c *gin.Context) {
+	filename := strings.TrimPrefix(c.Param("path"), "/")
+	ctx := c.Request.Context()
+
+	algorithms := strings.Split(c.DefaultQuery("algo", "sha256"), ",")
+	hashes, err := h.fileService.GetFileHashes(ctx, filename, algorithms)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		c.JSON(storageStatusCode(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"filename": filename,
+		"hashes":   hashes,
+	})
+}
+
 // GetFileMD5Progress handles MD5 calculation progress query requests.
 func (h *FileHandlers) GetFileMD5Progress(c *gin.Context) {
-	filename := c.Param("filename")
+	filename := strings.TrimPrefix(c.Param("path"), "/")
 	if filename == "" {
 		errorResponse(c, http.StatusBadRequest, "filename is required")
 		return
@@ -357,3 +665,73 @@ func (h *FileHandlers) GetFileMD5Progress(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// DeleteFile handles file deletion requests.
+func (h *FileHandlers) DeleteFile(c *gin.Context) {
+	filePath := strings.TrimPrefix(c.Param("path"), "/")
+
+	if err := h.fileService.DeleteFile(c.Request.Context(), filePath); err != nil {
+		errorResponse(c, storageStatusCode(err), "Failed to delete file: "+err.Error())
+		return
+	}
+
+	successResponse(c, "File deleted successfully", gin.H{"path": filePath})
+}
+
+// UpdateFile handles rename and move requests.
+func (h *FileHandlers) UpdateFile(c *gin.Context) {
+	filePath := strings.TrimPrefix(c.Param("path"), "/")
+
+	var req updateFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return
+	}
+
+	ctx := c.Request.Context()
+	var err error
+	newPath := req.Destination
+	switch {
+	case req.Name != "" && req.Destination == "":
+		newPath = path.Join(path.Dir(filePath), req.Name)
+		err = h.fileService.RenameFile(ctx, filePath, req.Name)
+	case req.Destination != "" && req.Name == "":
+		err = h.fileService.MoveFile(ctx, filePath, req.Destination)
+	default:
+		errorResponse(c, http.StatusBadRequest, "Exactly one of name or destination is required")
+		return
+	}
+
+	if err != nil {
+		errorResponse(c, storageStatusCode(err), "Failed to update file: "+err.Error())
+		return
+	}
+
+	successResponse(c, "File updated successfully", gin.H{"path": strings.TrimPrefix(newPath, "/")})
+}
+
+// CreateDirectory handles directory creation requests.
+func (h *FileHandlers) CreateDirectory(c *gin.Context) {
+	dirPath := strings.TrimPrefix(c.Param("path"), "/")
+
+	if err := h.fileService.CreateDirectory(c.Request.Context(), dirPath); err != nil {
+		errorResponse(c, storageStatusCode(err), "Failed to create directory: "+err.Error())
+		return
+	}
+
+	successResponse(c, "Directory created successfully", gin.H{"path": dirPath})
+}
+
+// DeleteDirectory handles directory deletion requests.
+// Non-empty directories are only deleted with ?recursive=true.
+func (h *FileHandlers) DeleteDirectory(c *gin.Context) {
+	dirPath := strings.TrimPrefix(c.Param("path"), "/")
+	recursive := c.Query("recursive") == "true"
+
+	if err := h.fileService.DeleteDirectory(c.Request.Context(), dirPath, recursive); err != nil {
+		errorResponse(c, storageStatusCode(err), "Failed to delete directory: "+err.Error())
+		return
+	}
+
+	successResponse(c, "Directory deleted successfully", gin.H{"path": dirPath})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandlers handles background job requests.
+type JobHandlers struct {
+	jobService interfaces.JobService
+}
+
+// NewJobHandlers creates and returns a new job handlers instance.
+func NewJobHandlers(jobService interfaces.JobService) *JobHandlers {
+	return &JobHandlers{
+		jobService: jobService,
+	}
+}
+
+// Register registers job routes.
+func (h *JobHandlers) Register(r *gin.Engine) {
+	r.GET("/jobs", h.ListJobs)
+	r.GET("/jobs/:id", h.GetJob)
+	r.DELETE("/jobs/:id", h.CancelJob)
+}
+
+// jobStatusCode maps job errors to HTTP status codes.
+func jobStatusCode(err error) int {
+	switch {
+	case errors.Is(err, interfaces.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, interfaces.ErrJobFinished):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ListJobs handles GET /jobs, optionally filtered by the state and type query parameters.
+func (h *JobHandlers) ListJobs(c *gin.Context) {
+	state := c.Query("state")
+	switch state {
+	case "", interfaces.JobQueued, interfaces.JobRunning, interfaces.JobSucceeded,
+		interfaces.JobFailed, interfaces.JobCanceled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job state"})
+		return
+	}
+
+	jobs := h.jobService.List(state, c.Query("type"))
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetJob handles GET /jobs/:id.
+func (h *JobHandlers) GetJob(c *gin.Context) {
+	job, err := h.jobService.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(jobStatusCode(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob handles DELETE /jobs/:id. Running jobs stop shortly after the response.
+func (h *JobHandlers) CancelJob(c *gin.Context) {
+	if err := h.jobService.Cancel(c.Param("id")); err != nil {
+		c.JSON(jobStatusCode(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lfsMediaType is the media type used by the Git LFS API.
+const lfsMediaType = "application/vnd.git-lfs+json"
+
+// LFSHandlers handles Git LFS Batch API requests.
+// Point a repository at the server with `git config lfs.url http://host:8080`.
+type LFSHandlers struct {
+	lfsService interfaces.LFSService
+}
+
+// NewLFSHandlers creates and returns a new Git LFS handlers instance.
+func NewLFSHandlers(lfsService interfaces.LFSService) *LFSHandlers {
+	return &LFSHandlers{
+		lfsService: lfsService,
+	}
+}
+
+// Register registers Git LFS routes.
+func (h *LFSHandlers) Register(r *gin.Engine) {
+	r.POST("/objects/batch", h.Batch)
+	r.POST("/objects/verify", h.Verify)
+	r.PUT("/objects/:oid", h.Upload)
+	r.GET("/objects/:oid", h.Download)
+}
+
+// lfsError writes an error in the Git LFS error format.
+func lfsError(c *gin.Context, statusCode int, message string) {
+	c.Header("Content-Type", lfsMediaType)
+	c.JSON(statusCode, gin.H{"message": message})
+}
+
+// lfsStatusCode maps service errors to HTTP status codes.
+func lfsStatusCode(err error) int {
+	switch {
+	case errors.Is(err, interfaces.ErrLFSObjectNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, interfaces.ErrLFSInvalidOID),
+		errors.Is(err, interfaces.ErrLFSSizeMismatch),
+		errors.Is(err, interfaces.ErrLFSHashMismatch):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Batch handles POST /objects/batch.
+func (h *LFSHandlers) Batch(c *gin.Context) {
+	var req interfaces.LFSBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		lfsError(c, http.StatusBadRequest, "Invalid batch request: "+err.Error())
+		return
+	}
+
+	resp, err := h.lfsService.Batch(c.Request.Context(), req, requestBaseURL(c))
+	if err != nil {
+		lfsError(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	c.Header("Content-Type", lfsMediaType)
+	c.JSON(http.StatusOK, resp)
+}
+
+// Upload handles PUT /objects/:oid with the raw object content as body.
+func (h *LFSHandlers) Upload(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.lfsService.UploadObject(ctx, c.Param("oid"), c.Request.ContentLength, c.Request.Body); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		lfsError(c, lfsStatusCode(err), err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Download handles GET /objects/:oid.
+func (h *LFSHandlers) Download(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.lfsService.DownloadObject(ctx, c, c.Param("oid"), c.GetHeader("Range")); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		if !c.Writer.Written() {
+			lfsError(c, lfsStatusCode(err), err.Error())
+		}
+	}
+}
+
+// Verify handles POST /objects/verify.
+func (h *LFSHandlers) Verify(c *gin.Context) {
+	var obj interfaces.LFSObject
+	if err := c.ShouldBindJSON(&obj); err != nil {
+		lfsError(c, http.StatusBadRequest, "Invalid verify request: "+err.Error())
+		return
+	}
+
+	if err := h.lfsService.VerifyObject(c.Request.Context(), obj); err != nil {
+		lfsError(c, lfsStatusCode(err), err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// requestBaseURL returns the scheme and host the client used to reach the server,
+// honouring X-Forwarded-Proto when running behind a reverse proxy.
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LockHandlers handles Git LFS file locking API requests.
+type LockHandlers struct {
+	lockService interfaces.LockService
+}
+
+// NewLockHandlers creates and returns a new lock handlers instance.
+func NewLockHandlers(lockService interfaces.LockService) *LockHandlers {
+	return &LockHandlers{
+		lockService: lockService,
+	}
+}
+
+// lockRef is the optional ref object sent with locking requests.
+type lockRef struct {
+	Name string `json:"name"`
+}
+
+// createLockRequest is the request body of POST /locks.
+type createLockRequest struct {
+	Path string   `json:"path"`
+	Ref  *lockRef `json:"ref,omitempty"`
+}
+
+// verifyLocksRequest is the request body of POST /locks/verify.
+type verifyLocksRequest struct {
+	Cursor string   `json:"cursor,omitempty"`
+	Limit  int      `json:"limit,omitempty"`
+	Ref    *lockRef `json:"ref,omitempty"`
+}
+
+// unlockRequest is the request body of POST /locks/:id/unlock.
+type unlockRequest struct {
+	Force bool     `json:"force,omitempty"`
+	Ref   *lockRef `json:"ref,omitempty"`
+}
+
+// Register registers lock routes.
+func (h *LockHandlers) Register(r *gin.Engine) {
+	r.POST("/locks", h.CreateLock)
+	r.GET("/locks", h.ListLocks)
+	r.POST("/locks/verify", h.VerifyLocks)
+	r.POST("/locks/:id/unlock", h.Unlock)
+}
+
+// CreateLock handles POST /locks.
+func (h *LockHandlers) CreateLock(c *gin.Context) {
+	var req createLockRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Path == "" {
+		lfsError(c, http.StatusBadRequest, "Invalid lock request")
+		return
+	}
+
+	ref := ""
+	if req.Ref != nil {
+		ref = req.Ref.Name
+	}
+
+	lock, err := h.lockService.CreateLock(c.Request.Context(), req.Path, ref)
+	if err != nil {
+		if errors.Is(err, interfaces.ErrLockExists) {
+			c.Header("Content-Type", lfsMediaType)
+			c.JSON(http.StatusConflict, gin.H{"lock": lock, "message": err.Error()})
+			return
+		}
+		if errors.Is(err, interfaces.ErrUnauthenticated) {
+			lfsUnauthorized(c, err.Error())
+			return
+		}
+		lfsError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("Content-Type", lfsMediaType)
+	c.JSON(http.StatusCreated, gin.H{"lock": lock})
+}
+
+// ListLocks handles GET /locks.
+func (h *LockHandlers) ListLocks(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	filter := interfaces.LockFilter{
+		Path:   c.Query("path"),
+		ID:     c.Query("id"),
+		Cursor: c.Query("cursor"),
+		Limit:  limit,
+	}
+
+	locks, next, err := h.lockService.ListLocks(c.Request.Context(), filter)
+	if err != nil {
+		lfsError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if locks == nil {
+		locks = []interfaces.Lock{}
+	}
+
+	response := gin.H{"locks": locks}
+	if next != "" {
+		response["next_cursor"] = next
+	}
+	c.Header("Content-Type", lfsMediaType)
+	c.JSON(http.StatusOK, response)
+}
+
+// VerifyLocks handles POST /locks/verify.
+func (h *LockHandlers) VerifyLocks(c *gin.Context) {
+	var req verifyLocksRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			lfsError(c, http.StatusBadRequest, "Invalid verify request")
+			return
+		}
+	}
+
+	ours, theirs, next, err := h.lockService.VerifyLocks(c.Request.Context(), req.Cursor, req.Limit)
+	if err != nil {
+		lfsError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := gin.H{"ours": ours, "theirs": theirs}
+	if next != "" {
+		response["next_cursor"] = next
+	}
+	c.Header("Content-Type", lfsMediaType)
+	c.JSON(http.StatusOK, response)
+}
+
+// Unlock handles POST /locks/:id/unlock.
+func (h *LockHandlers) Unlock(c *gin.Context) {
+	var req unlockRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			lfsError(c, http.StatusBadRequest, "Invalid unlock request")
+			return
+		}
+	}
+
+	lock, err := h.lockService.Unlock(c.Request.Context(), c.Param("id"), req.Force)
+	if err != nil {
+		switch {
+		case errors.Is(err, interfaces.ErrUnauthenticated):
+			lfsUnauthorized(c, err.Error())
+		case errors.Is(err, interfaces.ErrLockNotFound):
+			lfsError(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, interfaces.ErrLockNotOwner), errors.Is(err, interfaces.ErrLockForbidden):
+			lfsError(c, http.StatusForbidden, err.Error())
+		default:
+			lfsError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	c.Header("Content-Type", lfsMediaType)
+	c.JSON(http.StatusOK, gin.H{"lock": lock})
+}
+
+// lfsUnauthorized writes a 401 response asking the Git LFS client for Basic credentials.
+func lfsUnauthorized(c *gin.Context, message string) {
+	c.Header("LFS-Authenticate", `Basic realm="Git LFS"`)
+	lfsError(c, http.StatusUnauthorized, message)
+}
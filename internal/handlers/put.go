@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// digestAlgorithms maps RFC 3230 / RFC 9530 digest algorithm names to the names used by the services.
+var digestAlgorithms = map[string]string{
+	"md5":     "md5",
+	"sha":     "sha1",
+	"sha-256": "sha256",
+	"crc32c":  "crc32c",
+}
+
+// putStatusCode maps PUT upload errors to HTTP status codes.
+func putStatusCode(err error) int {
+	switch {
+	case errors.Is(err, interfaces.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, interfaces.ErrOffsetMismatch), errors.Is(err, interfaces.ErrUploadBusy):
+		return http.StatusConflict
+	case errors.Is(err, interfaces.ErrContentLengthMismatch), errors.Is(err, interfaces.ErrUploadInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, interfaces.ErrDigestMismatch):
+		return http.StatusUnprocessableEntity
+	default:
+		return storageStatusCode(err)
+	}
+}
+
+// PutFile handles PUT /files/*path. The raw request body is streamed straight to disk.
+// With Content-Range: bytes start-end/total the body is one segment of a resumable upload;
+// "bytes */total" with an empty body asks how many bytes have been received.
+func (h *FileHandlers) PutFile(c *gin.Context) {
+	filePath := strings.TrimPrefix(c.Param("path"), "/")
+	if filePath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": interfaces.ErrInvalidPath.Error()})
+		return
+	}
+
+	opts := interfaces.PutFileOptions{
+		Length:      c.Request.ContentLength,
+		IfMatch:     c.GetHeader("If-Match"),
+		IfNoneMatch: c.GetHeader("If-None-Match"),
+	}
+	if header := c.GetHeader("Content-Range"); header != "" {
+		start, end, total, err := parseContentRange(header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if start >= 0 && opts.Length >= 0 && opts.Length != end-start+1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": interfaces.ErrContentLengthMismatch.Error()})
+			return
+		}
+		opts.Ranged, opts.Start, opts.End, opts.Total = true, start, end, total
+	}
+
+	digests, err := requestDigests(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts.Digests = digests
+
+	ctx := c.Request.Context()
+	result, err := h.fileService.PutFile(ctx, filePath, c.Request.Body, opts)
+	if opts.Ranged {
+		setReceivedRange(c, result.Offset)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		c.JSON(putStatusCode(err), gin.H{"error": err.Error(), "offset": result.Offset})
+		return
+	}
+
+	if !result.Complete {
+		c.JSON(http.StatusAccepted, result)
+		return
+	}
+	c.Header("ETag", result.File.ETag())
+	if result.Created {
+		c.JSON(http.StatusCreated, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// setReceivedRange reports the bytes received so far of a ranged upload as "Range: bytes=0-<n-1>".
+func setReceivedRange(c *gin.Context, offset int64) {
+	if offset > 0 {
+		c.Header("Range", "bytes=0-"+strconv.FormatInt(offset-1, 10))
+	}
+}
+
+// parseContentRange parses "bytes start-end/total" or "bytes */total".
+// For the latter start is -1. The total length must be known.
+func parseContentRange(header string) (start, end, total int64, err error) {
+	invalid := errors.New("Invalid Content-Range")
+
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	rangePart, totalPart, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	total, err = strconv.ParseInt(totalPart, 10, 64)
+	if err != nil || total < 0 {
+		return 0, 0, 0, invalid
+	}
+	if rangePart == "*" {
+		return -1, -1, total, nil
+	}
+
+	startPart, endPart, ok := strings.Cut(rangePart, "-")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	start, err = strconv.ParseInt(startPart, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, 0, invalid
+	}
+	end, err = strconv.ParseInt(endPart, 10, 64)
+	if err != nil || end < start || end >= total {
+		return 0, 0, 0, invalid
+	}
+	return start, end, total, nil
+}
+
+// requestDigests collects the body digests sent in Content-MD5, Digest (RFC 3230)
+// and Content-Digest (RFC 9530) headers.
+func requestDigests(c *gin.Context) ([]interfaces.ContentDigest, error) {
+	var digests []interfaces.ContentDigest
+	if header := c.GetHeader("Content-MD5"); header != "" {
+		sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header))
+		if err != nil || len(sum) != md5.Size {
+			return nil, errors.New("Invalid Content-MD5")
+		}
+		digests = append(digests, interfaces.ContentDigest{Algorithm: "md5", Sum: sum})
+	}
+	for _, name := range []string{"Digest", "Content-Digest"} {
+		header := c.GetHeader(name)
+		if header == "" {
+			continue
+		}
+		parsed, err := parseDigestHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		if len(parsed) == 0 {
+			return nil, errors.New("No supported algorithm in " + name)
+		}
+		digests = append(digests, parsed...)
+	}
+	return digests, nil
+}
+
+// parseDigestHeader parses a Digest ("md5=<base64>") or Content-Digest ("sha-256=:<base64>:")
+// header. Algorithms not listed in digestAlgorithms are skipped.
+func parseDigestHeader(header string) ([]interfaces.ContentDigest, error) {
+	var digests []interfaces.ContentDigest
+	for _, member := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok {
+			return nil, errors.New("Invalid digest header")
+		}
+		algorithm, supported := digestAlgorithms[strings.ToLower(name)]
+		if !supported {
+			continue
+		}
+		if len(value) >= 2 && value[0] == ':' && value[len(value)-1] == ':' {
+			value = value[1 : len(value)-1]
+		}
+		sum, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, errors.New("Invalid digest header")
+		}
+		digests = append(digests, interfaces.ContentDigest{Algorithm: algorithm, Sum: sum})
+	}
+	return digests, nil
+}
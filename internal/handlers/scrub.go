@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScrubHandlers handles storage scrub requests.
+type ScrubHandlers struct {
+	scrubService interfaces.ScrubService
+}
+
+// NewScrubHandlers creates and returns a new scrub handlers instance.
+func NewScrubHandlers(scrubService interfaces.ScrubService) *ScrubHandlers {
+	return &ScrubHandlers{
+		scrubService: scrubService,
+	}
+}
+
+// Register registers scrub routes.
+func (h *ScrubHandlers) Register(r *gin.Engine) {
+	r.GET("/scrub/report", h.GetReport)
+	r.POST("/scrub", h.StartScrub)
+}
+
+// GetReport handles GET /scrub/report, returning the most recent scrub report.
+func (h *ScrubHandlers) GetReport(c *gin.Context) {
+	report, err := h.scrubService.Report(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No scrub has completed yet"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// StartScrub handles POST /scrub, starting a scrub now instead of waiting for the schedule.
+// The response is the scrub job; its progress is available under /jobs.
+func (h *ScrubHandlers) StartScrub(c *gin.Context) {
+	job, err := h.scrubService.RunNow()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Location", "/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StorageHandlers handles storage statistics and usage requests.
+type StorageHandlers struct {
+	storageService interfaces.StorageService
+}
+
+// NewStorageHandlers creates and returns a new storage handlers instance.
+func NewStorageHandlers(storageService interfaces.StorageService) *StorageHandlers {
+	return &StorageHandlers{
+		storageService: storageService,
+	}
+}
+
+// Register registers storage routes.
+func (h *StorageHandlers) Register(r *gin.Engine) {
+	r.GET("/storage/dedup-stats", h.GetDedupStats)
+	r.GET("/storage/usage", h.GetUsage)
+}
+
+// GetDedupStats handles GET /storage/dedup-stats.
+func (h *StorageHandlers) GetDedupStats(c *gin.Context) {
+	stats, err := h.storageService.DedupStats(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, interfaces.ErrDedupDisabled) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetUsage handles GET /storage/usage: used and free bytes overall and per top-level directory.
+func (h *StorageHandlers) GetUsage(c *gin.Context) {
+	usage, err := h.storageService.Usage(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trashStatusCode maps trash errors to HTTP status codes.
+func trashStatusCode(err error) int {
+	if errors.Is(err, interfaces.ErrTrashItemNotFound) {
+		return http.StatusNotFound
+	}
+	return storageStatusCode(err)
+}
+
+// TrashHandlers handles recycle bin requests.
+type TrashHandlers struct {
+	trashService interfaces.TrashService
+}
+
+// NewTrashHandlers creates and returns a new trash handlers instance.
+func NewTrashHandlers(trashService interfaces.TrashService) *TrashHandlers {
+	return &TrashHandlers{
+		trashService: trashService,
+	}
+}
+
+// Register registers trash routes.
+func (h *TrashHandlers) Register(r *gin.Engine) {
+	r.GET("/trash", h.ListTrash)
+	r.POST("/trash/:id/restore", h.RestoreTrash)
+	r.DELETE("/trash/:id", h.PurgeTrash)
+}
+
+// ListTrash handles GET /trash.
+func (h *TrashHandlers) ListTrash(c *gin.Context) {
+	items, err := h.trashService.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// RestoreTrash handles POST /trash/:id/restore. The response holds the path the item
+// was restored to, which differs from the original when that name was taken.
+func (h *TrashHandlers) RestoreTrash(c *gin.Context) {
+	item, err := h.trashService.Restore(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		errorResponse(c, trashStatusCode(err), "Failed to restore: "+err.Error())
+		return
+	}
+	successResponse(c, "Restored successfully", item)
+}
+
+// PurgeTrash handles DELETE /trash/:id, deleting the item permanently.
+func (h *TrashHandlers) PurgeTrash(c *gin.Context) {
+	if err := h.trashService.Purge(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(trashStatusCode(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
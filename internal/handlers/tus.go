@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tus protocol constants.
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,creation-with-upload,termination,checksum,expiration"
+	tusContentType = "application/offset+octet-stream"
+)
+
+// statusChecksumMismatch is the tus checksum extension status for a failed Upload-Checksum.
+const statusChecksumMismatch = 460
+
+// TusHandlers handles tus 1.0 resumable upload requests.
+type TusHandlers struct {
+	tusService interfaces.TusService
+}
+
+// NewTusHandlers creates and returns a new tus handlers instance.
+func NewTusHandlers(tusService interfaces.TusService) *TusHandlers {
+	return &TusHandlers{
+		tusService: tusService,
+	}
+}
+
+// Register registers tus routes.
+func (h *TusHandlers) Register(r *gin.Engine) {
+	for _, p := range []string{"/tus", "/tus/"} {
+		r.OPTIONS(p, h.Options)
+		r.POST(p, h.tusResumable(h.Create))
+	}
+	r.OPTIONS("/tus/:id", h.Options)
+	r.HEAD("/tus/:id", h.tusResumable(h.Head))
+	r.PATCH("/tus/:id", h.tusResumable(h.Patch))
+	r.DELETE("/tus/:id", h.tusResumable(h.Terminate))
+	// X-HTTP-Method-Override lets clients behind proxies without PATCH support tunnel through POST
+	r.POST("/tus/:id", h.tusResumable(h.MethodOverride))
+}
+
+// tusResumable sets the Tus-Resumable response header and rejects requests
+// for protocol versions other than 1.0.0.
+func (h *TusHandlers) tusResumable(next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+		c.Header("Cache-Control", "no-store")
+		if c.GetHeader("Tus-Resumable") != tusVersion {
+			c.Header("Tus-Version", tusVersion)
+			c.Status(http.StatusPreconditionFailed)
+			return
+		}
+		next(c)
+	}
+}
+
+// tusStatusCode maps tus errors to HTTP status codes.
+func tusStatusCode(err error) int {
+	switch {
+	case errors.Is(err, interfaces.ErrTusUploadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, interfaces.ErrTusInvalidMetadata), errors.Is(err, interfaces.ErrTusChecksumAlgorithm):
+		return http.StatusBadRequest
+	case errors.Is(err, interfaces.ErrTusOffsetMismatch):
+		return http.StatusConflict
+	case errors.Is(err, interfaces.ErrTusSizeExceeded):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, interfaces.ErrTusChecksumMismatch):
+		return statusChecksumMismatch
+	case errors.Is(err, interfaces.ErrTusUploadLocked):
+		return http.StatusLocked
+	default:
+		return storageStatusCode(err)
+	}
+}
+
+// tusError writes a plain-text tus error response.
+func tusError(c *gin.Context, err error) {
+	c.String(tusStatusCode(err), err.Error())
+}
+
+// setUploadHeaders writes the state of an upload as tus response headers.
+func setUploadHeaders(c *gin.Context, upload interfaces.TusUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// Options handles OPTIONS requests with the server's tus capabilities.
+func (h *TusHandlers) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Checksum-Algorithm", strings.Join(interfaces.TusChecksumAlgorithms, ","))
+	c.Status(http.StatusNoContent)
+}
+
+// Create handles POST /tus/, optionally with the first bytes of the upload in the body.
+func (h *TusHandlers) Create(c *gin.Context) {
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		c.String(http.StatusBadRequest, "Upload-Defer-Length is not supported")
+		return
+	}
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.String(http.StatusBadRequest, "Invalid Upload-Length")
+		return
+	}
+
+	ctx := c.Request.Context()
+	upload, err := h.tusService.Create(ctx, length, c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		tusError(c, err)
+		return
+	}
+	c.Header("Location", requestBaseURL(c)+"/tus/"+upload.ID)
+
+	// creation-with-upload: the body carries data starting at offset 0
+	if c.GetHeader("Content-Type") == tusContentType && c.Request.ContentLength != 0 {
+		checksum, err := parseUploadChecksum(c.GetHeader("Upload-Checksum"))
+		if err != nil {
+			tusError(c, err)
+			return
+		}
+		upload, err = h.tusService.Write(ctx, upload.ID, 0, c.Request.Body, checksum)
+		if err != nil && ctx.Err() == nil && !errors.Is(err, interfaces.ErrTusChecksumMismatch) {
+			tusError(c, err)
+			return
+		}
+	}
+
+	setUploadHeaders(c, upload)
+	c.Status(http.StatusCreated)
+}
+
+// Head handles HEAD /tus/:id and reports the current offset.
+func (h *TusHandlers) Head(c *gin.Context) {
+	upload, err := h.tusService.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.Status(tusStatusCode(err))
+		return
+	}
+
+	setUploadHeaders(c, upload)
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.Metadata != "" {
+		c.Header("Upload-Metadata", upload.Metadata)
+	}
+	c.Status(http.StatusOK)
+}
+
+// Patch handles PATCH /tus/:id and appends the request body at Upload-Offset.
+func (h *TusHandlers) Patch(c *gin.Context) {
+	if c.GetHeader("Content-Type") != tusContentType {
+		c.String(http.StatusUnsupportedMediaType, "Content-Type must be "+tusContentType)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.String(http.StatusBadRequest, "Invalid Upload-Offset")
+		return
+	}
+	checksum, err := parseUploadChecksum(c.GetHeader("Upload-Checksum"))
+	if err != nil {
+		tusError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	upload, err := h.tusService.Write(ctx, c.Param("id"), offset, c.Request.Body, checksum)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		tusError(c, err)
+		return
+	}
+
+	setUploadHeaders(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// Terminate handles DELETE /tus/:id.
+func (h *TusHandlers) Terminate(c *gin.Context) {
+	if err := h.tusService.Terminate(c.Request.Context(), c.Param("id")); err != nil {
+		tusError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// MethodOverride dispatches POST /tus/:id according to X-HTTP-Method-Override.
+func (h *TusHandlers) MethodOverride(c *gin.Context) {
+	switch strings.ToUpper(c.GetHeader("X-HTTP-Method-Override")) {
+	case http.MethodPatch:
+		h.Patch(c)
+	case http.MethodHead:
+		h.Head(c)
+	case http.MethodDelete:
+		h.Terminate(c)
+	default:
+		c.Status(http.StatusMethodNotAllowed)
+	}
+}
+
+// parseUploadChecksum parses an Upload-Checksum header of the form "<algorithm> <base64 digest>".
+// An empty header yields a nil checksum.
+func parseUploadChecksum(header string) (*interfaces.TusChecksum, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	parts := strings.Fields(header)
+	if len(parts) != 2 {
+		return nil, interfaces.ErrTusChecksumAlgorithm
+	}
+	sum, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, interfaces.ErrTusInvalidMetadata
+	}
+	return &interfaces.TusChecksum{Algorithm: strings.ToLower(parts[0]), Sum: sum}, nil
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadHandlers handles upload session requests.
+type UploadHandlers struct {
+	uploadService interfaces.UploadService
+}
+
+// NewUploadHandlers creates and returns a new upload session handlers instance.
+func NewUploadHandlers(uploadService interfaces.UploadService) *UploadHandlers {
+	return &UploadHandlers{
+		uploadService: uploadService,
+	}
+}
+
+// Register registers upload session routes.
+func (h *UploadHandlers) Register(r *gin.Engine) {
+	r.POST("/uploads", h.CreateSession)
+	r.GET("/uploads/:id", h.GetSession)
+	r.DELETE("/uploads/:id", h.AbortSession)
+	r.PUT("/uploads/:id/chunks/:n", h.PutChunk)
+	r.POST("/uploads/:id/complete", h.CompleteSession)
+}
+
+// uploadStatusCode maps upload session errors to HTTP status codes.
+func uploadStatusCode(err error) int {
+	switch {
+	case errors.Is(err, interfaces.ErrUploadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, interfaces.ErrUploadInvalid), errors.Is(err, interfaces.ErrChunkOutOfRange),
+		errors.Is(err, interfaces.ErrChunkSizeMismatch):
+		return http.StatusBadRequest
+	case errors.Is(err, interfaces.ErrUploadIncomplete), errors.Is(err, interfaces.ErrUploadBusy):
+		return http.StatusConflict
+	case errors.Is(err, interfaces.ErrUploadHashMismatch):
+		return http.StatusUnprocessableEntity
+	default:
+		return storageStatusCode(err)
+	}
+}
+
+// CreateSession handles POST /uploads.
+func (h *UploadHandlers) CreateSession(c *gin.Context) {
+	var req interfaces.UploadSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload session request"})
+		return
+	}
+
+	status, err := h.uploadService.CreateSession(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(uploadStatusCode(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Location", "/uploads/"+status.ID)
+	c.JSON(http.StatusCreated, status)
+}
+
+// GetSession handles GET /uploads/:id.
+func (h *UploadHandlers) GetSession(c *gin.Context) {
+	status, err := h.uploadService.GetSession(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(uploadStatusCode(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// PutChunk handles PUT /uploads/:id/chunks/:n; the request body is the raw chunk content.
+func (h *UploadHandlers) PutChunk(c *gin.Context) {
+	index, err := strconv.Atoi(c.Param("n"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chunk index"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	status, err := h.uploadService.PutChunk(ctx, c.Param("id"), index, c.Request.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		c.JSON(uploadStatusCode(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// CompleteSession handles POST /uploads/:id/complete.
+func (h *UploadHandlers) CompleteSession(c *gin.Context) {
+	ctx := c.Request.Context()
+	file, err := h.uploadService.Complete(ctx, c.Param("id"))
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		c.JSON(uploadStatusCode(err), gin.H{"error": err.Error()})
+		return
+	}
+	successResponse(c, "Upload completed", file)
+}
+
+// AbortSession handles DELETE /uploads/:id.
+func (h *UploadHandlers) AbortSession(c *gin.Context) {
+	if err := h.uploadService.Abort(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(uploadStatusCode(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// restoreSuffix ends the path of a restore request: POST /versions/<path>/restore.
+const restoreSuffix = "/restore"
+
+// restoreVersionRequest is the optional JSON body of a restore request.
+type restoreVersionRequest struct {
+	Version int `json:"version"`
+}
+
+// versionStatusCode maps version errors to HTTP status codes.
+func versionStatusCode(err error) int {
+	if errors.Is(err, interfaces.ErrVersioningDisabled) || errors.Is(err, interfaces.ErrVersionNotFound) {
+		return http.StatusNotFound
+	}
+	return storageStatusCode(err)
+}
+
+// parseVersion parses a version number query parameter; versions start at 1.
+func parseVersion(value string) (int, bool) {
+	version, err := strconv.Atoi(value)
+	return version, err == nil && version > 0
+}
+
+// VersionHandlers handles file version history requests.
+type VersionHandlers struct {
+	versionService interfaces.VersionService
+}
+
+// NewVersionHandlers creates and returns a new version handlers instance.
+func NewVersionHandlers(versionService interfaces.VersionService) *VersionHandlers {
+	return &VersionHandlers{
+		versionService: versionService,
+	}
+}
+
+// Register registers version routes. Downloading a version is served by
+// GET /download/*path?version=N.
+func (h *VersionHandlers) Register(r *gin.Engine) {
+	r.GET("/versions/*path", h.ListVersions)
+	r.POST("/versions/*path", h.RestoreVersion)
+}
+
+// ListVersions handles GET /versions/*path, listing the versions of a file newest first.
+func (h *VersionHandlers) ListVersions(c *gin.Context) {
+	filePath := strings.TrimPrefix(c.Param("path"), "/")
+
+	versions, err := h.versionService.ListVersions(c.Request.Context(), filePath)
+	if err != nil {
+		c.JSON(versionStatusCode(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"path": filePath, "versions": versions})
+}
+
+// RestoreVersion handles POST /versions/*path/restore. The version is taken from the
+// version query parameter or a {"version": N} body; the current content becomes a new version.
+func (h *VersionHandlers) RestoreVersion(c *gin.Context) {
+	wildcard := c.Param("path")
+	if !strings.HasSuffix(wildcard, restoreSuffix) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	filePath := strings.TrimPrefix(strings.TrimSuffix(wildcard, restoreSuffix), "/")
+
+	value := c.Query("version")
+	if value == "" {
+		var req restoreVersionRequest
+		if err := c.ShouldBindJSON(&req); err == nil {
+			value = strconv.Itoa(req.Version)
+		}
+	}
+	version, ok := parseVersion(value)
+	if !ok {
+		errorResponse(c, http.StatusBadRequest, "A positive version number is required")
+		return
+	}
+
+	if err := h.versionService.RestoreVersion(c.Request.Context(), filePath, version); err != nil {
+		errorResponse(c, versionStatusCode(err), "Failed to restore version: "+err.Error())
+		return
+	}
+	successResponse(c, "Version restored successfully", gin.H{"path": filePath, "version": version})
+}
@@ -0,0 +1,34 @@
+package interfaces
+
+import "errors"
+
+// 归档下载相关错误。
+var (
+	// ErrUnsupportedArchiveFormat 表示不支持的归档格式或压缩方式。
+	ErrUnsupportedArchiveFormat = errors.New("unsupported archive format")
+)
+
+// 支持的归档格式。
+const (
+	ArchiveFormatZip   = "zip"
+	ArchiveFormatTar   = "tar"
+	ArchiveFormatTarGz = "tar.gz"
+)
+
+// ZIP 归档成员的压缩方式。
+const (
+	// ArchiveCompressionAuto 对已压缩的文件（图片、视频、压缩包等）仅存储，其余文件使用 deflate 压缩。
+	ArchiveCompressionAuto = "auto"
+
+	// ArchiveCompressionStore 所有文件仅存储，不压缩。
+	ArchiveCompressionStore = "store"
+
+	// ArchiveCompressionDeflate 所有文件都使用 deflate 压缩。
+	ArchiveCompressionDeflate = "deflate"
+)
+
+// ArchiveOptions 表示归档下载的选项。
+type ArchiveOptions struct {
+	Format      string // 归档格式：zip、tar 或 tar.gz
+	Compression string // ZIP 成员的压缩方式，空字符串表示 auto；tar 格式忽略此选项
+}
@@ -26,16 +26,20 @@ type Cache interface {
 
 // MD5Cache 定义MD5值缓存的专用接口。
 // 提供MD5值的缓存、计算状态跟踪和进度查询功能。
+// 缓存以文件完整路径为键，文件的大小、修改时间或 inode 变化后缓存自动失效。
 type MD5Cache interface {
 	// GetMD5 从缓存中获取文件的MD5值。
-	// 返回MD5值和是否存在。
-	GetMD5(filePath, fileName string, size int64) (md5 string, exists bool)
+	// 返回MD5值和是否存在，文件已被修改时视为不存在。
+	GetMD5(filePath string) (md5 string, exists bool)
 
-	// SetMD5 将MD5值设置到缓存中。
-	SetMD5(filePath, fileName, md5 string, size int64) error
+	// SetMD5 将文件当前内容的MD5值设置到缓存中。
+	SetMD5(filePath, md5 string) error
+
+	// SetHashes 将文件当前内容的多种摘要（键为算法名）合并到缓存中。
+	SetHashes(filePath string, hashes map[string]string) error
 
 	// SetCalculating 标记文件正在计算MD5。
-	SetCalculating(filePath, fileName string, size int64) error
+	SetCalculating(filePath string) error
 
 	// UpdateProgress 更新MD5计算的进度。
 	// progress 为进度百分比（0-100）。
@@ -47,4 +51,10 @@ type MD5Cache interface {
 	// GetProgress 获取MD5计算的进度信息。
 	// 返回进度百分比（0-100）、是否完成、错误信息（如果有）。
 	GetProgress(filePath string) (progress float64, completed bool, errMsg string)
+
+	// Invalidate 删除文件（或目录下所有文件）的缓存条目。
+	Invalidate(filePath string) error
+
+	// Rename 将文件（或目录下所有文件）的缓存条目迁移到新路径。
+	Rename(oldPath, newPath string) error
 }
@@ -0,0 +1,35 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+)
+
+// CleanupStats 表示一次清理删除的内容。
+type CleanupStats struct {
+	ChunkDirs      int   `json:"chunk_dirs"`      // 删除的分片目录数
+	TempFiles      int   `json:"temp_files"`      // 删除的临时文件和残留数据数
+	UploadSessions int   `json:"upload_sessions"` // 删除的上传会话数
+	TusUploads     int   `json:"tus_uploads"`     // 删除的 tus 上传数
+	Versions       int   `json:"versions"`        // 删除的过期历史版本数
+	TrashItems     int   `json:"trash_items"`     // 清除的回收站条目数
+	FreedBytes     int64 `json:"freed_bytes"`     // 释放的字节数
+}
+
+// Add 将另一次清理的统计累加到当前统计。
+func (s *CleanupStats) Add(other CleanupStats) {
+	s.ChunkDirs += other.ChunkDirs
+	s.TempFiles += other.TempFiles
+	s.UploadSessions += other.UploadSessions
+	s.TusUploads += other.TusUploads
+	s.Versions += other.Versions
+	s.TrashItems += other.TrashItems
+	s.FreedBytes += other.FreedBytes
+}
+
+// StaleFileCleaner 定义清理存储中残留文件的接口。
+type StaleFileCleaner interface {
+	// CleanStaleFiles 删除超过 maxAge 未修改的分片目录、临时文件和没有元数据的上传数据。
+	// 有元数据的上传会话由各自的存储管理，不在此处删除。
+	CleanStaleFiles(ctx context.Context, maxAge time.Duration) (CleanupStats, error)
+}
@@ -0,0 +1,52 @@
+package interfaces
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidConflictPolicy 表示上传冲突策略不受支持（支持 overwrite、rename、skip 和 fail）。
+var ErrInvalidConflictPolicy = errors.New("invalid conflict policy")
+
+// ConflictPolicy 表示上传的目标文件已存在时的处理方式。
+type ConflictPolicy string
+
+// 上传冲突策略。
+const (
+	// ConflictOverwrite 原子替换已有文件。
+	ConflictOverwrite ConflictPolicy = "overwrite"
+
+	// ConflictRename 以 "name (1).ext" 形式的新名称保存，已有文件保持不变。
+	ConflictRename ConflictPolicy = "rename"
+
+	// ConflictSkip 保留已有文件，不保存上传的内容。
+	ConflictSkip ConflictPolicy = "skip"
+
+	// ConflictFail 返回包装 os.ErrExist 的错误。
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// DefaultConflictPolicy 是没有指定冲突策略时的处理方式，不会修改已有文件。
+// 服务端可以配置其他默认策略（启用版本历史时默认为 overwrite），由处理上传请求的一方传入。
+const DefaultConflictPolicy = ConflictRename
+
+// ParseConflictPolicy 解析冲突策略（不区分大小写），空字符串返回 DefaultConflictPolicy。
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case "":
+		return DefaultConflictPolicy, nil
+	case ConflictOverwrite, ConflictRename, ConflictSkip, ConflictFail:
+		return policy, nil
+	default:
+		return "", ErrInvalidConflictPolicy
+	}
+}
+
+// SaveResult 表示上传文件的保存结果。
+type SaveResult struct {
+	Path      string         `json:"path"`               // 文件最终保存的相对路径，按 rename 策略重命名时与上传的文件名不同
+	Conflict  ConflictPolicy `json:"conflict,omitempty"` // 实际使用的冲突策略，续传时为空
+	Skipped   bool           `json:"skipped"`            // 目标已存在且策略为 skip，上传的内容没有保存
+	Renamed   bool           `json:"renamed"`            // 目标已存在，按 rename 策略以新名称保存
+	Versioned bool           `json:"versioned"`          // 覆盖了已有文件，旧内容已保存为历史版本
+}
@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrDedupDisabled 表示当前存储后端不支持去重。
+var ErrDedupDisabled = errors.New("deduplication is not enabled")
+
+// DedupStats 表示去重存储的空间统计。
+type DedupStats struct {
+	Files         int     `json:"files"`          // 指向去重对象的文件数
+	Objects       int     `json:"objects"`        // 实际保存的对象数
+	LogicalBytes  int64   `json:"logical_bytes"`  // 所有文件大小之和
+	PhysicalBytes int64   `json:"physical_bytes"` // 所有对象大小之和
+	SavedBytes    int64   `json:"saved_bytes"`    // 去重节省的字节数
+	Ratio         float64 `json:"ratio"`          // 逻辑字节数与物理字节数之比，没有对象时为 1
+}
+
+// Deduplicator 定义去重存储的统计接口。
+type Deduplicator interface {
+	// DedupStats 返回当前的去重统计。
+	DedupStats(ctx context.Context) (DedupStats, error)
+}
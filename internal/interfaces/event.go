@@ -0,0 +1,91 @@
+package interfaces
+
+import (
+	"strings"
+	"time"
+)
+
+// 事件类型。
+const (
+	EventHashProgress   = "hash.progress"   // MD5计算进度，结束时 progress 为 1 并带上 md5，失败时带上 error
+	EventUploadProgress = "upload.progress" // 上传进度（分片上传、上传会话和 tus）
+	EventUploadComplete = "upload.complete" // 上传完成，文件已写入目标路径
+	EventFileChanged    = "file.changed"    // 文件或目录被创建、修改、删除或移动
+	EventFileCorrupted  = "file.corrupted"  // 巡检发现文件损坏、丢失或无法读取，action 为问题类型
+)
+
+// 文件变更类型。
+const (
+	FileCreated  = "created"
+	FileModified = "modified"
+	FileDeleted  = "deleted"
+	FileMoved    = "moved"
+)
+
+// Event 表示推送给客户端的一个事件。
+type Event struct {
+	ID       uint64    `json:"id"`                  // 由事件服务分配的递增序号
+	Type     string    `json:"type"`                // 事件类型
+	Path     string    `json:"path"`                // 相对存储根目录的路径（使用 / 分隔）
+	OldPath  string    `json:"old_path,omitempty"`  // 移动前的路径，仅 moved
+	Action   string    `json:"action,omitempty"`    // 文件变更类型（file.changed）或巡检问题类型（file.corrupted）
+	IsDir    bool      `json:"is_dir,omitempty"`    // 变更的是否为目录
+	UploadID string    `json:"upload_id,omitempty"` // 上传会话或 tus 上传的ID
+	Progress float64   `json:"progress,omitempty"`  // 进度 0.0-1.0
+	Bytes    int64     `json:"bytes,omitempty"`     // 已接收的字节数
+	Total    int64     `json:"total,omitempty"`     // 总字节数
+	MD5      string    `json:"md5,omitempty"`       // 计算完成的MD5
+	Error    string    `json:"error,omitempty"`     // 错误信息
+	Time     time.Time `json:"time"`                // 事件发生时间
+}
+
+// EventFilter 表示客户端的订阅条件，字段为空时不过滤。
+type EventFilter struct {
+	Types []string // 只接收这些类型的事件
+	Paths []string // 只接收这些路径及其子路径（或其上级目录）的事件
+}
+
+// Matches 判断事件是否符合订阅条件。
+// 上级目录的变更（例如删除整个目录）也会推送给订阅其子路径的客户端。
+func (f EventFilter) Matches(e Event) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == e.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Paths) == 0 {
+		return true
+	}
+	for _, p := range f.Paths {
+		if pathsOverlap(p, e.Path) || (e.OldPath != "" && pathsOverlap(p, e.OldPath)) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathsOverlap 判断两个相对路径是否相同或一个是另一个的上级目录，空路径表示根目录。
+func pathsOverlap(a, b string) bool {
+	a, b = strings.Trim(a, "/"), strings.Trim(b, "/")
+	if a == "" || b == "" || a == b {
+		return true
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return strings.HasPrefix(b, a+"/")
+}
+
+// EventPublisher 定义发布事件的接口。
+// Publish 不会阻塞，处理不过来的订阅者会丢失事件。
+type EventPublisher interface {
+	// Publish 发布事件，ID 和 Time 为空时自动填充。
+	Publish(event Event)
+}
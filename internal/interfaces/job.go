@@ -0,0 +1,69 @@
+package interfaces
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 后台任务相关错误。
+var (
+	// ErrJobNotFound 表示任务不存在或已从历史记录中移除。
+	ErrJobNotFound = errors.New("job not found")
+
+	// ErrJobFinished 表示任务已经结束，无法取消。
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// 任务状态。
+const (
+	JobQueued    = "queued"    // 等待执行
+	JobRunning   = "running"   // 正在执行
+	JobSucceeded = "succeeded" // 执行成功
+	JobFailed    = "failed"    // 执行失败
+	JobCanceled  = "canceled"  // 已取消
+)
+
+// 任务优先级，数值越大越先执行。
+const (
+	JobPriorityLow    = 0  // 后台预计算，例如列表触发的MD5计算
+	JobPriorityNormal = 10 // 默认优先级
+	JobPriorityHigh   = 20 // 用户正在等待结果
+)
+
+// 任务类型。
+const (
+	JobTypeMD5   = "md5"   // 计算文件MD5
+	JobTypeScrub = "scrub" // 巡检存储文件
+)
+
+// JobFunc 是任务的执行函数。
+// ctx 在任务被取消时结束，progress 用于报告 0.0-1.0 的进度。
+type JobFunc func(ctx context.Context, progress func(float64)) error
+
+// JobRequest 表示提交的任务。
+type JobRequest struct {
+	Type     string  // 任务类型
+	Target   string  // 任务对象，例如相对路径；同类型同对象的未结束任务只保留一个
+	Priority int     // 优先级
+	Run      JobFunc // 执行函数
+}
+
+// Job 表示一个后台任务的状态。
+type Job struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`
+	Target     string     `json:"target,omitempty"`
+	Priority   int        `json:"priority"`
+	State      string     `json:"state"`
+	Progress   float64    `json:"progress"` // 0.0-1.0
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Finished 判断任务是否已经结束。
+func (j Job) Finished() bool {
+	return j.State == JobSucceeded || j.State == JobFailed || j.State == JobCanceled
+}
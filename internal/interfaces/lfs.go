@@ -0,0 +1,69 @@
+package interfaces
+
+import (
+	"errors"
+)
+
+// Git LFS 批量接口相关错误。
+var (
+	// ErrLFSInvalidOID 表示对象ID不是合法的SHA-256十六进制字符串。
+	ErrLFSInvalidOID = errors.New("invalid object id")
+
+	// ErrLFSObjectNotFound 表示对象不存在或大小不匹配。
+	ErrLFSObjectNotFound = errors.New("object does not exist")
+
+	// ErrLFSSizeMismatch 表示上传内容的长度与声明的大小不一致。
+	ErrLFSSizeMismatch = errors.New("object size mismatch")
+
+	// ErrLFSHashMismatch 表示上传内容的SHA-256与对象ID不一致。
+	ErrLFSHashMismatch = errors.New("object hash mismatch")
+)
+
+// LFSObject 表示Git LFS中的一个对象（指针）。
+type LFSObject struct {
+	OID  string `json:"oid"`  // 对象ID（SHA-256）
+	Size int64  `json:"size"` // 对象大小（字节）
+}
+
+// LFSRef 表示批量请求关联的Git引用。
+type LFSRef struct {
+	Name string `json:"name"` // 引用名称，例如 refs/heads/main
+}
+
+// LFSBatchRequest 表示 POST /objects/batch 的请求体。
+type LFSBatchRequest struct {
+	Operation string      `json:"operation"`           // upload 或 download
+	Transfers []string    `json:"transfers,omitempty"` // 客户端支持的传输方式
+	Ref       *LFSRef     `json:"ref,omitempty"`       // 关联的Git引用
+	Objects   []LFSObject `json:"objects"`             // 请求的对象列表
+	HashAlgo  string      `json:"hash_algo,omitempty"` // 哈希算法，仅支持 sha256
+}
+
+// LFSAction 表示客户端需要执行的一个传输动作。
+type LFSAction struct {
+	Href      string            `json:"href"`                 // 动作的目标URL
+	Header    map[string]string `json:"header,omitempty"`     // 需要附带的请求头
+	ExpiresIn int               `json:"expires_in,omitempty"` // 有效期（秒）
+}
+
+// LFSObjectError 表示单个对象的处理错误。
+type LFSObjectError struct {
+	Code    int    `json:"code"`    // HTTP风格的错误码
+	Message string `json:"message"` // 错误描述
+}
+
+// LFSObjectResult 表示批量响应中单个对象的处理结果。
+type LFSObjectResult struct {
+	OID           string                `json:"oid"`
+	Size          int64                 `json:"size"`
+	Authenticated bool                  `json:"authenticated,omitempty"`
+	Actions       map[string]*LFSAction `json:"actions,omitempty"` // upload、download、verify
+	Error         *LFSObjectError       `json:"error,omitempty"`
+}
+
+// LFSBatchResponse 表示 POST /objects/batch 的响应体。
+type LFSBatchResponse struct {
+	Transfer string            `json:"transfer,omitempty"`  // 服务端选择的传输方式
+	Objects  []LFSObjectResult `json:"objects"`             // 各对象的处理结果
+	HashAlgo string            `json:"hash_algo,omitempty"` // 使用的哈希算法
+}
@@ -0,0 +1,99 @@
+package interfaces
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 文件锁相关错误。
+var (
+	// ErrLockExists 表示路径已被锁定。
+	ErrLockExists = errors.New("path is already locked")
+
+	// ErrLockNotFound 表示锁不存在。
+	ErrLockNotFound = errors.New("lock not found")
+
+	// ErrLockNotOwner 表示当前用户不是锁的持有者。
+	ErrLockNotOwner = errors.New("lock is owned by another user")
+
+	// ErrPathLocked 表示写入的路径被其他用户锁定。
+	ErrPathLocked = errors.New("path is locked by another user")
+
+	// ErrUnauthenticated 表示操作需要经过认证的用户身份。
+	ErrUnauthenticated = errors.New("authentication required")
+
+	// ErrLockForbidden 表示只有管理员可以强制解除其他用户的锁。
+	ErrLockForbidden = errors.New("only administrators can force unlock another user's lock")
+)
+
+// LockOwner 表示锁的持有者。
+type LockOwner struct {
+	Name string `json:"name"` // 持有者名称
+}
+
+// Lock 表示一个文件锁，格式与Git LFS锁定接口一致。
+type Lock struct {
+	ID       string    `json:"id"`        // 锁ID
+	Path     string    `json:"path"`      // 被锁定的相对路径
+	LockedAt time.Time `json:"locked_at"` // 加锁时间
+	Owner    LockOwner `json:"owner"`     // 持有者
+	Ref      string    `json:"ref,omitempty"`
+}
+
+// LockFilter 表示查询锁列表的过滤和分页条件。
+type LockFilter struct {
+	Path   string // 按路径精确过滤，空字符串表示不过滤
+	ID     string // 按锁ID过滤，空字符串表示不过滤
+	Cursor string // 分页游标（上一页返回的 next_cursor）
+	Limit  int    // 每页数量，0表示使用默认值
+}
+
+// LockStore 定义文件锁的持久化存储接口。
+// 同一路径同时只能存在一个锁。
+type LockStore interface {
+	// Create 保存新锁，路径已被锁定时返回已有的锁和 ErrLockExists。
+	Create(lock Lock) (Lock, error)
+
+	// Get 根据ID获取锁，不存在时返回 ErrLockNotFound。
+	Get(id string) (Lock, error)
+
+	// FindByPath 根据路径获取锁，返回锁和是否存在。
+	FindByPath(path string) (Lock, bool)
+
+	// List 返回所有锁，按加锁时间排序。
+	List() []Lock
+
+	// Delete 删除锁，不存在时返回 ErrLockNotFound。
+	Delete(id string) error
+}
+
+// Identity 表示发起请求的用户。
+type Identity struct {
+	Name  string // 用户名，未认证时为空
+	Admin bool   // 是否为管理员，管理员可以强制解除其他用户的锁
+}
+
+// identityContextKey 是请求上下文中保存用户身份的键。
+type identityContextKey struct{}
+
+// WithIdentity 返回携带用户身份的上下文。
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// IdentityFromContext 从上下文中获取用户身份，未设置时返回未认证的空身份。
+func IdentityFromContext(ctx context.Context) Identity {
+	identity, _ := ctx.Value(identityContextKey{}).(Identity)
+	return identity
+}
+
+// WithOwner 返回携带用户名的上下文，用于以保存的上传者身份继续处理上传。
+func WithOwner(ctx context.Context, owner string) context.Context {
+	return WithIdentity(ctx, Identity{Name: owner})
+}
+
+// OwnerFromContext 从上下文中获取用户名，未认证时返回空字符串。
+func OwnerFromContext(ctx context.Context) string {
+	return IdentityFromContext(ctx).Name
+}
@@ -0,0 +1,47 @@
+package interfaces
+
+import (
+	"errors"
+)
+
+// 原始请求体上传相关错误。
+var (
+	// ErrPreconditionFailed 表示 If-Match 或 If-None-Match 条件不成立。
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	// ErrOffsetMismatch 表示分段的起始位置与已接收的字节数不一致。
+	ErrOffsetMismatch = errors.New("offset does not match received bytes")
+
+	// ErrDigestMismatch 表示请求体与 Content-MD5 或 Digest 请求头不一致。
+	ErrDigestMismatch = errors.New("content digest mismatch")
+
+	// ErrContentLengthMismatch 表示请求体长度与 Content-Length 或 Content-Range 不一致。
+	ErrContentLengthMismatch = errors.New("content length mismatch")
+)
+
+// ContentDigest 表示请求体的一个校验值。
+type ContentDigest struct {
+	Algorithm string // 校验算法（md5 或 sha256）
+	Sum       []byte // 校验值
+}
+
+// PutFileOptions 表示以原始请求体写入文件的参数。
+type PutFileOptions struct {
+	Length      int64           // 请求体长度，-1 表示未知
+	Ranged      bool            // 是否为分段上传（带 Content-Range）
+	Start       int64           // 分段的起始位置，-1 表示只查询已接收的字节数（bytes */total）
+	End         int64           // 分段的结束位置（闭区间）
+	Total       int64           // 文件总大小
+	Digests     []ContentDigest // 请求体的校验值，全部校验通过才接收
+	IfMatch     string          // If-Match 请求头
+	IfNoneMatch string          // If-None-Match 请求头
+}
+
+// PutFileResult 表示以原始请求体写入文件的结果。
+type PutFileResult struct {
+	Offset   int64         `json:"offset"`         // 已接收的字节数
+	Total    int64         `json:"total"`          // 文件总大小
+	Complete bool          `json:"complete"`       // 文件是否已写入目标路径
+	Created  bool          `json:"created"`        // 目标文件是否为新建
+	File     *FileMetadata `json:"file,omitempty"` // 完成后的文件元数据
+}
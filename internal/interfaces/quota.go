@@ -0,0 +1,68 @@
+package interfaces
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 存储空间相关错误，均对应 507 Insufficient Storage。
+var (
+	// ErrQuotaExceeded 表示写入后会超出全局配额或目录配额。
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+	// ErrInsufficientSpace 表示写入后文件系统的剩余空间会低于最小剩余空间。
+	ErrInsufficientSpace = errors.New("insufficient free disk space")
+)
+
+// DirectoryUsage 表示一个顶层目录的空间使用情况。
+type DirectoryUsage struct {
+	Path          string `json:"path"`                  // 顶层目录名
+	UsedBytes     int64  `json:"used_bytes"`            // 目录下所有文件的大小
+	ReservedBytes int64  `json:"reserved_bytes"`        // 写入该目录、尚未完成的上传预留的字节数
+	QuotaBytes    int64  `json:"quota_bytes,omitempty"` // 目录配额，0 表示不限制
+	FreeBytes     int64  `json:"free_bytes"`            // 还可以写入的字节数，同时受目录配额、全局配额和最小剩余空间限制，已扣除预留
+}
+
+// StorageUsage 表示存储目录的空间使用情况。
+type StorageUsage struct {
+	DiskTotalBytes int64            `json:"disk_total_bytes"`      // 文件系统的总容量
+	DiskFreeBytes  int64            `json:"disk_free_bytes"`       // 文件系统的可用空间
+	MinFreeBytes   int64            `json:"min_free_bytes"`        // 最小剩余空间，低于该值时拒绝上传
+	QuotaBytes     int64            `json:"quota_bytes,omitempty"` // 全局配额，0 表示不限制
+	UsedBytes      int64            `json:"used_bytes"`            // 存储目录下所有内容的大小，包括内部数据
+	ReservedBytes  int64            `json:"reserved_bytes"`        // 已接受但尚未完成的上传预留的字节数
+	FreeBytes      int64            `json:"free_bytes"`            // 还可以写入的字节数，同时受全局配额和最小剩余空间限制，已扣除预留
+	RootFilesBytes int64            `json:"root_files_bytes"`      // 根目录下文件的大小
+	InternalBytes  int64            `json:"internal_bytes"`        // 回收站、历史版本、上传会话等内部数据的大小
+	Directories    []DirectoryUsage `json:"directories"`           // 每个顶层目录的使用情况，按名称排序
+	ScannedAt      time.Time        `json:"scanned_at"`            // 最近一次统计目录用量的时间
+}
+
+// UsageScanner 定义统计存储空间的接口。
+type UsageScanner interface {
+	// ScanUsage 遍历存储目录，统计每个顶层目录、根目录文件和内部数据的大小。
+	// 分片上传暂存的分片和去重对象（与文件共享数据）不计入用量；返回值中与配额相关的字段为空。
+	ScanUsage(ctx context.Context) (StorageUsage, error)
+
+	// DiskSpace 返回存储目录所在文件系统的总容量和可用空间（字节）。
+	DiskSpace() (total, available int64, err error)
+}
+
+// QuotaChecker 定义上传前的空间检查接口。
+// 已接受但尚未完成的上传（预留的空间和未完成的上传会话）在检查时计入用量，
+// 同时进行的多个上传不会各自按同一份用量通过检查。
+type QuotaChecker interface {
+	// CheckUpload 检查向 filePath 写入 size 字节是否超出配额或使剩余空间低于最小剩余空间，
+	// 超出配额返回 ErrQuotaExceeded，剩余空间不足返回 ErrInsufficientSpace。
+	// filePath 为空时不检查目录配额，size 小于0（长度未知）时按0检查。
+	CheckUpload(ctx context.Context, filePath string, size int64) error
+
+	// ReserveUpload 与 CheckUpload 一样检查，通过后为写入 filePath 预留 size 字节，直到调用返回的 release。
+	// 同一路径上同时进行的上传共用一份预留，按其中最大的 size 计算。
+	// 上传结束后必须调用 release，written 为实际写入存储的字节数（失败或跳过时为0），在下次统计前计入用量。
+	ReserveUpload(ctx context.Context, filePath string, size int64) (release func(written int64), err error)
+
+	// RecordUpload 将不经过预留写入 filePath 的 size 字节（上传会话完成时的合并）计入用量，直到下次统计。
+	RecordUpload(filePath string, size int64)
+}
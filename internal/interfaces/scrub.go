@@ -0,0 +1,48 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+)
+
+// 巡检发现的问题类型。
+const (
+	ScrubMismatch   = "mismatch"   // 文件大小、修改时间和 inode 未变，但内容的摘要与索引不一致
+	ScrubMissing    = "missing"    // 索引中有记录的文件已不存在
+	ScrubUnreadable = "unreadable" // 读取文件出错，例如磁盘坏道
+)
+
+// ScrubIssue 表示巡检发现的一个问题。
+type ScrubIssue struct {
+	Path       string    `json:"path"`                // 相对存储根目录的路径（使用 / 分隔）
+	Kind       string    `json:"kind"`                // 问题类型
+	Algorithm  string    `json:"algorithm,omitempty"` // 不一致的摘要算法，仅 mismatch
+	Expected   string    `json:"expected,omitempty"`  // 索引中记录的摘要
+	Actual     string    `json:"actual,omitempty"`    // 重新计算的摘要，仅 mismatch
+	Error      string    `json:"error,omitempty"`     // 错误信息，仅 unreadable
+	DetectedAt time.Time `json:"detected_at"`         // 发现时间
+}
+
+// ScrubReport 表示一次巡检的结果。
+type ScrubReport struct {
+	StartedAt     time.Time    `json:"started_at"`
+	FinishedAt    time.Time    `json:"finished_at"`
+	Completed     bool         `json:"completed"`       // 是否遍历完整个存储目录，被取消或出错时为 false
+	Error         string       `json:"error,omitempty"` // 中止巡检的错误
+	FilesChecked  int          `json:"files_checked"`   // 读取过的文件数
+	FilesVerified int          `json:"files_verified"`  // 与索引中的摘要比对过的文件数
+	FilesRecorded int          `json:"files_recorded"`  // 之前没有有效摘要、本次计算后写入索引的文件数
+	BytesChecked  int64        `json:"bytes_checked"`   // 读取的字节数
+	Issues        []ScrubIssue `json:"issues"`          // 发现的问题
+}
+
+// Scrubber 定义重新校验存储文件的接口。
+type Scrubber interface {
+	// Scrub 以不超过 bytesPerSecond 的速度读取所有文件并重新计算摘要，与摘要索引比对。
+	// 没有有效摘要的文件计算后写入索引，供以后的巡检比对；bytesPerSecond 不大于0时不限速。
+	// 结果会被保存，被取消时也会保存已检查部分的报告。
+	Scrub(ctx context.Context, bytesPerSecond int64, progress func(float64)) (ScrubReport, error)
+
+	// LastScrubReport 返回最近一次保存的巡检报告，从未巡检过时返回 os.ErrNotExist。
+	LastScrubReport(ctx context.Context) (ScrubReport, error)
+}
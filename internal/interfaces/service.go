@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"io"
 	"mime/multipart"
 
 	"github.com/gin-gonic/gin"
@@ -10,17 +11,23 @@ import (
 // FileService 定义文件服务的业务逻辑接口。
 // 封装文件上传、下载、列表和MD5计算等核心功能。
 type FileService interface {
-	// UploadFile 上传单个文件，支持断点续传。
-	// rangeHeader 用于指定上传范围，空字符串表示完整上传。
-	UploadFile(ctx context.Context, file *multipart.FileHeader, rangeHeader string) error
+	// UploadFile 上传单个文件到 targetDir 目录，支持断点续传。
+	// rangeHeader 用于指定上传范围，空字符串表示完整上传；conflict 指定目标已存在时的处理方式。
+	// 返回文件最终保存的路径。
+	UploadFile(ctx context.Context, targetDir string, file *multipart.FileHeader, rangeHeader string, conflict ConflictPolicy) (SaveResult, error)
 
 	// UploadFileChunk 上传文件分片。
-	// chunkInfo 包含分片的元数据信息。
-	UploadFileChunk(ctx context.Context, chunkInfo FileChunkInfo, file *multipart.FileHeader) error
+	// chunkInfo 包含分片的元数据信息，所有分片到达并合并后返回合并报告。
+	UploadFileChunk(ctx context.Context, chunkInfo FileChunkInfo, file *multipart.FileHeader) (*ChunkMergeReport, error)
 
-	// BatchUpload 批量上传多个文件。
-	// 返回成功数量、失败数量和错误信息列表。
-	BatchUpload(ctx context.Context, files []*multipart.FileHeader) (successCount, errorCount int, errors []string)
+	// PutFile 将原始请求体直接写入文件，不经过 multipart 缓冲。
+	// 不带 Content-Range 时原子替换整个文件；带 Content-Range 时按分段续传，最后一段到达后原子替换目标文件。
+	// 请求体的校验值和 If-Match/If-None-Match 条件不满足时不会修改目标文件。
+	PutFile(ctx context.Context, filePath string, data io.Reader, opts PutFileOptions) (PutFileResult, error)
+
+	// BatchUpload 批量上传多个文件，conflict 指定目标已存在时的处理方式。
+	// 返回成功保存（包括跳过）的文件的结果和错误信息列表。
+	BatchUpload(ctx context.Context, targetDir string, files []*multipart.FileHeader, conflict ConflictPolicy) (results []SaveResult, errors []string)
 
 	// DownloadFile 下载文件，支持断点续传。
 	// rangeHeader 用于指定下载范围，空字符串表示完整下载。
@@ -30,14 +37,22 @@ type FileService interface {
 	// chunkIndex 从0开始的分片索引，chunkSize 为分片大小（字节）。
 	DownloadFileChunk(ctx context.Context, c *gin.Context, filename string, chunkIndex, chunkSize int64) error
 
-	// ListFiles 列出指定路径下的所有文件。
-	// path 为空字符串时列出根目录。
-	ListFiles(ctx context.Context, path string) ([]FileMetadata, error)
+	// DownloadArchive 将多个文件或整个目录打包为 ZIP/TAR 归档流式写入 w。
+	// 路径校验失败时返回错误且不写入任何数据。
+	DownloadArchive(ctx context.Context, w io.Writer, paths []string, opts ArchiveOptions) error
+
+	// ListFiles 列出指定路径下的文件。
+	// opts.Path 为空字符串时列出根目录。
+	ListFiles(ctx context.Context, opts ListOptions) (FileList, error)
 
 	// GetFileMD5 获取文件的MD5校验值。
 	// 如果文件正在计算中，会等待计算完成。
 	GetFileMD5(ctx context.Context, filename string) (string, error)
 
+	// GetFileHashes 获取文件的多种摘要（键为算法名，值为十六进制摘要）。
+	// 支持 md5、sha1、sha256、blake3 和 crc32c，缓存中缺少的摘要只读取一遍文件同时计算。
+	GetFileHashes(ctx context.Context, filename string, algorithms []string) (map[string]string, error)
+
 	// GetFileMD5Progress 获取MD5计算的进度信息。
 	// 返回进度百分比（0-100）、是否完成、错误信息（如果有）。
 	GetFileMD5Progress(filename string) (progress float64, completed bool, errMsg string)
@@ -45,6 +60,97 @@ type FileService interface {
 	// CheckFileExists 检查文件是否存在。
 	// 文件不存在时返回错误。
 	CheckFileExists(ctx context.Context, filename string) error
+
+	// DeleteFile 删除文件。
+	DeleteFile(ctx context.Context, filename string) error
+
+	// RenameFile 在同一目录下重命名文件或目录。
+	RenameFile(ctx context.Context, filename, newName string) error
+
+	// MoveFile 将文件或目录移动到新路径。
+	MoveFile(ctx context.Context, srcPath, dstPath string) error
+
+	// CreateDirectory 创建目录，包括所有不存在的父目录。
+	CreateDirectory(ctx context.Context, dirPath string) error
+
+	// DeleteDirectory 删除目录，recursive 为 true 时同时删除其中的所有内容。
+	DeleteDirectory(ctx context.Context, dirPath string, recursive bool) error
+}
+
+// LFSService 定义Git LFS批量传输服务的接口。
+// 对象按SHA-256以内容寻址的方式保存在存储路径下。
+type LFSService interface {
+	// Batch 处理批量请求，为每个对象返回上传、下载或校验动作。
+	// baseURL 用于生成动作的绝对地址。
+	Batch(ctx context.Context, req LFSBatchRequest, baseURL string) (LFSBatchResponse, error)
+
+	// UploadObject 保存对象内容，并校验大小和SHA-256。
+	// size 小于0时表示长度未知，只校验SHA-256。
+	UploadObject(ctx context.Context, oid string, size int64, data io.Reader) error
+
+	// DownloadObject 将对象内容写入响应，支持断点续传。
+	DownloadObject(ctx context.Context, c *gin.Context, oid, rangeHeader string) error
+
+	// VerifyObject 确认对象已完整保存。
+	VerifyObject(ctx context.Context, obj LFSObject) error
+}
+
+// LockService 定义文件锁服务的接口。
+// 实现Git LFS锁定接口，用户身份通过 IdentityFromContext 从上下文获取。
+type LockService interface {
+	// CreateLock 为路径加锁，路径已被锁定时返回已有的锁和 ErrLockExists，未认证时返回 ErrUnauthenticated。
+	CreateLock(ctx context.Context, path, ref string) (Lock, error)
+
+	// ListLocks 按条件分页列出锁，返回锁列表和下一页游标。
+	ListLocks(ctx context.Context, filter LockFilter) ([]Lock, string, error)
+
+	// VerifyLocks 分页列出锁，并按是否属于当前用户分为 ours 和 theirs。
+	VerifyLocks(ctx context.Context, cursor string, limit int) (ours, theirs []Lock, nextCursor string, err error)
+
+	// Unlock 释放锁，未认证时返回 ErrUnauthenticated。
+	// 释放其他用户的锁需要 force，并且只有管理员可以强制释放，否则返回 ErrLockForbidden。
+	Unlock(ctx context.Context, id string, force bool) (Lock, error)
+
+	// CheckWriteAccess 检查当前用户是否可以写入路径。
+	// 路径被其他用户锁定时返回 ErrPathLocked，未认证的用户不能写入任何被锁定的路径。
+	CheckWriteAccess(ctx context.Context, path string) error
+}
+
+// UploadService 定义上传会话的业务逻辑接口。
+type UploadService interface {
+	// CreateSession 创建上传会话。
+	CreateSession(ctx context.Context, req UploadSessionRequest) (UploadStatus, error)
+
+	// GetSession 返回会话的已接收分片位图和连续偏移。
+	GetSession(ctx context.Context, id string) (UploadStatus, error)
+
+	// PutChunk 保存一个分片，分片可以按任意顺序上传，重复上传会覆盖之前的内容。
+	PutChunk(ctx context.Context, id string, index int, data io.Reader) (UploadStatus, error)
+
+	// Complete 合并所有分片并校验哈希，成功后删除会话并返回目标文件的元数据。
+	// 仍有分片缺失时返回 ErrUploadIncomplete。
+	Complete(ctx context.Context, id string) (FileMetadata, error)
+
+	// Abort 取消会话并删除已上传的分片。
+	Abort(ctx context.Context, id string) error
+}
+
+// TusService 定义 tus 1.0 可恢复上传协议的业务逻辑接口。
+type TusService interface {
+	// Create 创建上传，length 为总长度，metadata 为原始的 Upload-Metadata 头。
+	// 目标路径由元数据中的 filename（或 name）和可选的 dir 决定。
+	Create(ctx context.Context, length int64, metadata string) (TusUpload, error)
+
+	// Get 返回上传的当前状态。
+	Get(ctx context.Context, id string) (TusUpload, error)
+
+	// Write 从 offset 开始追加数据，返回更新后的上传。
+	// checksum 不为 nil 时校验本次请求体，不一致时丢弃本次数据并返回 ErrTusChecksumMismatch；
+	// 未校验的请求中断时，已收到的数据仍然保留。
+	Write(ctx context.Context, id string, offset int64, data io.Reader, checksum *TusChecksum) (TusUpload, error)
+
+	// Terminate 终止上传并删除已上传的数据。
+	Terminate(ctx context.Context, id string) error
 }
 
 // ChatService 定义聊天服务的接口。
@@ -70,3 +176,104 @@ type MetricsService interface {
 	// key 为指标名称，value 为指标值。
 	RecordMetric(key string, value interface{})
 }
+
+// JanitorService 定义后台清理服务的接口。
+// 定期删除被放弃的分片目录、临时文件和过期的上传会话。
+type JanitorService interface {
+	// Start 在后台按配置的间隔运行清理，直到 ctx 被取消。
+	Start(ctx context.Context)
+
+	// RunOnce 立即执行一次清理并返回本次的统计。
+	RunOnce(ctx context.Context) (CleanupStats, error)
+}
+
+// JobService 定义后台任务管理的接口。
+// 任务按优先级排队（同优先级先进先出），由固定数量的工作协程执行。
+type JobService interface {
+	// Start 启动工作协程，ctx 被取消时停止执行并取消所有未结束的任务。
+	// 启动前提交的任务会排队等待。
+	Start(ctx context.Context)
+
+	// Submit 提交任务。同类型同对象的任务尚未结束时不会重复提交，
+	// 而是返回已有任务，并在新优先级更高时提升其优先级。
+	Submit(req JobRequest) (Job, error)
+
+	// List 返回排队中、执行中和最近结束的任务，state 和 jobType 为空时不过滤。
+	List(state, jobType string) []Job
+
+	// Get 返回指定任务的状态。
+	Get(id string) (Job, error)
+
+	// Wait 等待任务结束并返回其最终状态；ctx 先结束时返回 ctx 的错误，任务继续执行。
+	Wait(ctx context.Context, id string) (Job, error)
+
+	// Cancel 取消排队中或执行中的任务。
+	Cancel(id string) error
+}
+
+// EventService 定义事件推送服务的接口。
+// 存储层和上传服务发布事件，SSE 和 WebSocket 连接按订阅条件接收。
+type EventService interface {
+	EventPublisher
+
+	// Subscribe 订阅符合 filter 的事件，返回事件通道和取消订阅的函数。
+	// 订阅者处理过慢时新事件会被丢弃，取消订阅后通道会被关闭。
+	Subscribe(filter EventFilter) (<-chan Event, func())
+}
+
+// ScrubService 定义定期巡检服务的接口。
+// 巡检作为后台任务执行，可以在任务列表中查看进度和取消。
+type ScrubService interface {
+	// Start 按配置的间隔安排巡检，直到 ctx 被取消。
+	// 距上次巡检已超过间隔（或从未巡检过）时立即开始一次。
+	Start(ctx context.Context)
+
+	// RunNow 立即提交一次巡检任务，已有未结束的巡检任务时返回该任务。
+	RunNow() (Job, error)
+
+	// Report 返回最近一次巡检的报告。
+	Report(ctx context.Context) (ScrubReport, error)
+}
+
+// StorageService 定义存储统计和空间限制相关的服务接口。
+type StorageService interface {
+	// DedupStats 返回去重存储的空间统计，未启用去重时返回 ErrDedupDisabled。
+	DedupStats(ctx context.Context) (DedupStats, error)
+
+	// Usage 返回存储空间的使用情况，目录用量来自最近一次统计，剩余空间实时查询。
+	Usage(ctx context.Context) (StorageUsage, error)
+
+	QuotaChecker
+
+	// Start 立即统计一次目录用量，之后定期重新统计，直到 ctx 被取消；两次统计之间记录的上传计入最近一次统计。
+	Start(ctx context.Context)
+}
+
+// VersionService 定义文件版本历史的服务接口。
+// 没有启用版本历史时所有方法返回 ErrVersioningDisabled。
+type VersionService interface {
+	// ListVersions 返回文件的历史版本，最新的在前。
+	ListVersions(ctx context.Context, filePath string) ([]FileVersion, error)
+
+	// DownloadVersion 下载文件的历史版本，支持 Range 和条件请求。
+	DownloadVersion(ctx context.Context, c *gin.Context, filePath string, version int, rangeHeader string) error
+
+	// RestoreVersion 用历史版本替换当前文件，当前内容先保存为新版本。
+	// 文件被其他用户锁定时返回 ErrPathLocked。
+	RestoreVersion(ctx context.Context, filePath string, version int) error
+}
+
+// TrashService 定义回收站服务的接口。
+type TrashService interface {
+	// Start 在后台按配置的间隔清除超过保留时间的条目，直到 ctx 被取消。
+	Start(ctx context.Context)
+
+	// List 返回回收站中的条目，最近删除的在前。
+	List(ctx context.Context) ([]TrashItem, error)
+
+	// Restore 恢复条目并返回恢复后的条目，原路径被其他用户锁定时返回 ErrPathLocked。
+	Restore(ctx context.Context, id string) (TrashItem, error)
+
+	// Purge 彻底删除条目。
+	Purge(ctx context.Context, id string) error
+}
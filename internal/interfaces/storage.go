@@ -2,44 +2,142 @@ package interfaces
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 存储操作相关错误。
+var (
+	// ErrInvalidPath 表示路径非法（路径遍历、绝对路径或内部目录）。
+	ErrInvalidPath = errors.New("invalid path")
+
+	// ErrDirectoryNotEmpty 表示非递归删除的目录不为空。
+	ErrDirectoryNotEmpty = errors.New("directory not empty")
+
+	// ErrInvalidCursor 表示分页游标无法解析。
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrChunkChecksumMismatch 表示分片内容与分片校验值不一致，客户端只需重传该分片。
+	ErrChunkChecksumMismatch = errors.New("chunk checksum mismatch")
+
+	// ErrChunkChecksumAlgorithm 表示分片校验算法不受支持（支持 md5、sha1、sha256、blake3 和 crc32c）。
+	ErrChunkChecksumAlgorithm = errors.New("unsupported chunk checksum algorithm")
+
+	// ErrUnsupportedHashAlgorithm 表示请求的摘要算法不受支持。
+	ErrUnsupportedHashAlgorithm = errors.New("unsupported hash algorithm")
+)
+
 // FileChunkInfo 表示文件分片的元数据信息。
 type FileChunkInfo struct {
-	FileName   string `json:"file_name"`   // 文件名
+	FileName   string `json:"file_name"`   // 文件名（可以包含子目录，例如 a/b/c.bin）
 	TotalSize  int64  `json:"total_size"`  // 文件总大小（字节）
 	ChunkIndex int    `json:"chunk_index"` // 分片索引（从0开始）
 	ChunkSize  int64  `json:"chunk_size"`  // 分片大小（字节）
 	TotalChunk int    `json:"total_chunk"` // 总分片数
-	MD5        string `json:"md5"`         // 分片的MD5值
+	MD5        string `json:"md5"`         // 整个文件的MD5值，合并后校验
+
+	ChunkHashAlgorithm string `json:"chunk_hash_algorithm,omitempty"` // 分片校验算法（md5、sha1、sha256、blake3 或 crc32c，默认 md5）
+	ChunkHash          string `json:"chunk_hash,omitempty"`           // 分片的校验值（十六进制），为空时不校验
+
+	Conflict ConflictPolicy `json:"conflict,omitempty"` // 目标文件已存在时的处理方式，为空时使用 DefaultConflictPolicy
+}
+
+// ChunkFailure 记录一个分片的校验失败情况。
+type ChunkFailure struct {
+	Index    int    `json:"index"`    // 分片索引
+	Attempts int    `json:"attempts"` // 校验失败的次数
+	Reason   string `json:"reason"`   // 最近一次失败的原因
+}
+
+// ChunkMergeReport 表示分片合并的结果报告。
+type ChunkMergeReport struct {
+	TotalChunks    int            `json:"total_chunks"`    // 总分片数
+	VerifiedChunks int            `json:"verified_chunks"` // 带校验值且合并时再次校验通过的分片数
+	FailedChunks   []ChunkFailure `json:"failed_chunks"`   // 上传或合并时校验失败过的分片
+	RetryChunks    []int          `json:"retry_chunks"`    // 合并时发现损坏、需要重传的分片，为空表示合并成功
+	Path           string         `json:"path"`            // 文件最终保存的相对路径
+	Conflict       ConflictPolicy `json:"conflict"`        // 实际使用的冲突策略
+	Skipped        bool           `json:"skipped"`         // 目标已存在且冲突策略为 skip，分片没有保存
+	Renamed        bool           `json:"renamed"`         // 目标已存在，按 rename 策略以新名称保存
+	Versioned      bool           `json:"versioned"`       // 覆盖了已有文件，旧内容已保存为历史版本
 }
 
 // FileMetadata 表示文件或目录的元数据信息。
 type FileMetadata struct {
-	Name     string         `json:"name"`              // 文件或目录名
-	Path     string         `json:"path"`              // 完整路径
+	Name     string         `json:"name"`               // 文件或目录名
+	Path     string         `json:"path"`               // 完整路径
 	Size     int64          `json:"size"`               // 文件大小（字节），目录为0
-	ModTime  time.Time      `json:"mod_time"`          // 修改时间
-	MD5      string         `json:"md5,omitempty"`     // MD5值（仅文件）
+	ModTime  time.Time      `json:"mod_time"`           // 修改时间
+	MD5      string         `json:"md5,omitempty"`      // MD5值（仅文件）
 	IsDir    bool           `json:"is_dir"`             // 是否为目录
 	Children []FileMetadata `json:"children,omitempty"` // 子项列表（仅目录）
+	Inode    uint64         `json:"-"`                  // inode 编号，不支持的平台为0，只用于生成实体标签
+}
+
+// ETag 返回文件的强实体标签，由修改时间、大小和 inode 组成，内容被修改或替换后随之改变。
+// 不使用MD5，同一文件的实体标签不会因为MD5是否已计算而变化。
+func (m FileMetadata) ETag() string {
+	return fmt.Sprintf("\"%x-%x-%x\"", m.ModTime.UnixNano(), m.Size, m.Inode)
+}
+
+// ETagListContains 判断逗号分隔的实体标签列表是否包含 etag。
+// weak 为 false 时使用强比较，弱标签（W/"..."）不会匹配（RFC 9110 8.8.3.2）。
+func ETagListContains(list, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ListOptions 表示目录列表的查询条件。
+type ListOptions struct {
+	Path   string // 要列出的目录（相对路径），空字符串表示根目录
+	Depth  int    // 列出的层级数，1 表示只列出当前目录，0 表示不限层级
+	Limit  int    // 当前目录每页返回的条目数，0 表示不分页
+	Cursor string // 分页游标（上一页返回的 NextCursor）
+	Sort   string // 排序字段：name、size、mtime
+	Order  string // 排序方向：asc、desc
+}
+
+// FileList 表示一页目录列表。
+type FileList struct {
+	Files      []FileMetadata `json:"files"`                 // 当前页的条目
+	NextCursor string         `json:"next_cursor,omitempty"` // 下一页游标，没有更多数据时为空
+	Total      int            `json:"total"`                 // 当前目录的条目总数
 }
 
 // Storage 定义文件存储的核心操作接口。
 // 支持多种存储实现（本地文件系统、云存储等），提供统一的存储抽象。
 type Storage interface {
-	// SaveFile 保存文件，支持断点续传。
-	// rangeHeader 用于指定保存范围，空字符串表示完整保存。
-	SaveFile(ctx context.Context, file *multipart.FileHeader, rangeHeader string) error
+	FileReader
+	FileWriter
+
+	// SaveFile 将文件保存到 targetDir 目录（空字符串表示根目录），支持断点续传。
+	// 不存在的父目录会自动创建，rangeHeader 用于指定保存范围，空字符串表示完整保存。
+	// 完整保存时写入临时文件后原子替换，目标已存在时按 conflict 处理；
+	// 续传（范围起点大于0）从起点继续写入已有文件，不受 conflict 影响。
+	SaveFile(ctx context.Context, targetDir string, file *multipart.FileHeader, rangeHeader string, conflict ConflictPolicy) (SaveResult, error)
 
-	// SaveFileChunk 保存文件分片。
-	// chunkInfo 包含分片的元数据信息。
-	SaveFileChunk(ctx context.Context, chunkInfo FileChunkInfo, file *multipart.FileHeader) error
+	// SaveFileChunk 保存文件分片，提供分片校验值时先校验再接收，校验失败返回 ErrChunkChecksumMismatch。
+	// 最后一个分片到达并合并后返回合并报告，否则报告为 nil；
+	// 合并时发现损坏的分片会被删除并返回 ErrChunkChecksumMismatch，报告的 RetryChunks 列出需要重传的分片。
+	// 目标已存在时按 chunkInfo.Conflict 处理：skip 和 fail 在接收分片前生效，rename 和 overwrite 在合并时生效。
+	SaveFileChunk(ctx context.Context, chunkInfo FileChunkInfo, file *multipart.FileHeader) (*ChunkMergeReport, error)
 
 	// DownloadFile 下载文件，支持断点续传。
 	// rangeHeader 用于指定下载范围，空字符串表示完整下载。
@@ -49,20 +147,49 @@ type Storage interface {
 	// chunkIndex 从0开始的分片索引，chunkSize 为分片大小（字节）。
 	DownloadFileChunk(ctx context.Context, c *gin.Context, filename string, chunkIndex, chunkSize int64) error
 
-	// ListFiles 列出所有文件和文件夹，支持递归遍历。
-	ListFiles(ctx context.Context) ([]FileMetadata, error)
+	// WriteArchive 将文件和目录（递归）打包后流式写入 w，不产生临时文件。
+	// 所有路径在写入任何数据之前校验，校验失败时 w 保持未写入状态。
+	// 归档内的条目名相对于所有路径的公共父目录。
+	WriteArchive(ctx context.Context, w io.Writer, paths []string, opts ArchiveOptions) error
+
+	// ListFiles 列出指定目录下的文件和文件夹，支持分页、排序和层级限制。
+	// 只为返回页中的文件计算MD5。
+	ListFiles(ctx context.Context, opts ListOptions) (FileList, error)
 
 	// CheckFileExists 检查文件是否存在。
 	// 文件不存在时返回错误。
 	CheckFileExists(ctx context.Context, filename string) error
 
+	// DeleteFile 删除文件，不能用于删除目录。
+	DeleteFile(ctx context.Context, filename string) error
+
+	// RenameFile 在同一目录下重命名文件或目录。
+	// newName 只能是名称，不能包含路径分隔符。
+	RenameFile(ctx context.Context, filename, newName string) error
+
+	// MoveFile 将文件或目录移动到新路径，目标已存在时返回错误。
+	MoveFile(ctx context.Context, srcPath, dstPath string) error
+
+	// CreateDirectory 创建目录，包括所有不存在的父目录。
+	CreateDirectory(ctx context.Context, dirPath string) error
+
+	// DeleteDirectory 删除目录。
+	// recursive 为 false 时目录必须为空，否则返回 ErrDirectoryNotEmpty。
+	DeleteDirectory(ctx context.Context, dirPath string, recursive bool) error
+
+	// StatFile 返回文件或目录的元数据，不计算MD5。
+	// 文件不存在时返回错误。
+	StatFile(ctx context.Context, filename string) (FileMetadata, error)
+
 	// GetFilePath 返回文件的完整路径。
 	GetFilePath(filename string) string
 }
 
-// MD5Calculator 定义MD5计算的接口。
-// 支持异步计算、进度追踪和缓存机制。
-type MD5Calculator interface {
+// FileHasher 定义文件摘要计算的接口。
+// 支持 md5、sha1、sha256、blake3 和 crc32c，一次读取文件同时计算多种摘要；
+// 结果与MD5一起缓存，文件被修改后自动失效。
+// 除 CalculateHashes 外，filePath 均为相对存储根目录的路径，与其他存储接口一样校验。
+type FileHasher interface {
 	// GetMD5 获取文件的MD5值，优先从缓存读取。
 	// 如果缓存不存在，会触发异步计算。
 	GetMD5(ctx context.Context, filePath string) (string, error)
@@ -71,9 +198,13 @@ type MD5Calculator interface {
 	// 返回进度百分比（0-100）、是否完成、错误信息（如果有）。
 	GetMD5Progress(filePath string) (progress float64, completed bool, errMsg string)
 
-	// CalculateMD5 计算文件的MD5值。
+	// GetHashes 获取文件的多种摘要（键为算法名，值为十六进制摘要），优先从缓存读取。
+	// 缓存中缺少的摘要只读取一遍文件同时计算。
+	GetHashes(ctx context.Context, filePath string, algorithms []string) (map[string]string, error)
+
+	// CalculateHashes 直接计算文件的多种摘要，不读写缓存。
 	// progressCallback 用于报告计算进度，可以为nil。
-	CalculateMD5(ctx context.Context, filePath string, progressCallback func(float64)) (string, error)
+	CalculateHashes(ctx context.Context, filePath string, algorithms []string, progressCallback func(float64)) (map[string]string, error)
 }
 
 // FileReader 定义文件读取操作的接口。
@@ -89,9 +220,22 @@ type FileReader interface {
 // FileWriter 定义文件写入操作的接口。
 type FileWriter interface {
 	// WriteFile 写入文件的全部内容。
+	// 数据先写入同目录下的临时文件，读取成功结束后再原子替换目标文件。
 	WriteFile(ctx context.Context, filePath string, data io.Reader) error
 
 	// WriteFileRange 写入文件的指定范围，支持断点续传。
 	// start 表示写入的起始位置（字节偏移）。
 	WriteFileRange(ctx context.Context, filePath string, start int64, data io.Reader) error
+
+	// PartialFileSize 返回目标文件的分段上传已接收的字节数，没有分段上传时返回0。
+	PartialFileSize(ctx context.Context, filePath string) (int64, error)
+
+	// WritePartialFile 从 start 处写入目标文件的分段上传数据，start 之后原有的数据被丢弃，返回已接收的字节数。
+	// 分段上传的数据保存在目标文件同目录下的隐藏文件中，写入失败（包括 data 在结束时返回的校验错误）时截断回 start。
+	// start 大于已接收的字节数时返回 ErrOffsetMismatch。
+	WritePartialFile(ctx context.Context, filePath string, start int64, data io.Reader) (int64, error)
+
+	// CommitPartialFile 在分段上传已接收 size 字节时同步到磁盘并原子替换目标文件，
+	// 字节数不一致时返回 ErrOffsetMismatch。
+	CommitPartialFile(ctx context.Context, filePath string, size int64) error
 }
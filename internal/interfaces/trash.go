@@ -0,0 +1,39 @@
+package interfaces
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTrashItemNotFound 表示回收站中没有指定的条目，或该条目已被清除。
+var ErrTrashItemNotFound = errors.New("trash item not found")
+
+// TrashItem 表示回收站中的一个文件或目录。
+type TrashItem struct {
+	ID        string    `json:"id"`         // 条目ID
+	Path      string    `json:"path"`       // 删除前的路径；恢复后为实际恢复到的路径
+	IsDir     bool      `json:"is_dir"`     // 是否为目录
+	Size      int64     `json:"size"`       // 大小（字节），目录为其中所有文件之和
+	DeletedAt time.Time `json:"deleted_at"` // 删除时间
+}
+
+// Trasher 定义回收站的接口。
+// 删除的文件和目录移入回收站，可以恢复或彻底清除，超过保留时间的条目自动清除。
+type Trasher interface {
+	// ListTrash 返回回收站中的条目，最近删除的在前。
+	ListTrash(ctx context.Context) ([]TrashItem, error)
+
+	// GetTrashItem 返回回收站中的一个条目。
+	GetTrashItem(ctx context.Context, id string) (TrashItem, error)
+
+	// RestoreTrash 将条目恢复到原路径并返回恢复后的条目。
+	// 原路径已被占用时恢复为 "名称 (1).扩展名" 这样的新名称，缺少的父目录会重新创建。
+	RestoreTrash(ctx context.Context, id string) (TrashItem, error)
+
+	// PurgeTrash 彻底删除回收站中的条目。
+	PurgeTrash(ctx context.Context, id string) error
+
+	// EmptyTrash 彻底删除超过 maxAge 的条目，返回删除的条目数和释放的字节数。
+	EmptyTrash(ctx context.Context, maxAge time.Duration) (CleanupStats, error)
+}
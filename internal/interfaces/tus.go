@@ -0,0 +1,72 @@
+package interfaces
+
+import (
+	"errors"
+	"time"
+)
+
+// tus 协议相关错误。
+var (
+	// ErrTusUploadNotFound 表示上传不存在、已终止或已过期。
+	ErrTusUploadNotFound = errors.New("tus upload not found")
+
+	// ErrTusInvalidMetadata 表示 Upload-Metadata 头或 Upload-Length 非法。
+	ErrTusInvalidMetadata = errors.New("invalid upload metadata")
+
+	// ErrTusOffsetMismatch 表示请求的 Upload-Offset 与服务端记录的偏移不一致。
+	ErrTusOffsetMismatch = errors.New("upload offset mismatch")
+
+	// ErrTusSizeExceeded 表示写入的数据超过了 Upload-Length。
+	ErrTusSizeExceeded = errors.New("upload exceeds declared length")
+
+	// ErrTusChecksumMismatch 表示请求体与 Upload-Checksum 不一致，本次写入被丢弃。
+	ErrTusChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrTusChecksumAlgorithm 表示不支持的校验算法。
+	ErrTusChecksumAlgorithm = errors.New("unsupported checksum algorithm")
+
+	// ErrTusUploadLocked 表示同一上传正在被另一个请求写入。
+	ErrTusUploadLocked = errors.New("upload is locked by another request")
+)
+
+// TusChecksumAlgorithms 列出 Upload-Checksum 支持的校验算法。
+var TusChecksumAlgorithms = []string{"md5", "sha1", "sha256"}
+
+// TusUpload 表示一个 tus 上传。
+// 数据写入内部数据目录，写满 Length 字节后转存到目标路径。
+type TusUpload struct {
+	ID        string            `json:"id"`                 // 上传ID
+	Length    int64             `json:"length"`             // 声明的总长度（Upload-Length）
+	Offset    int64             `json:"offset"`             // 已确认接收的字节数（Upload-Offset）
+	Metadata  string            `json:"metadata,omitempty"` // 原始的 Upload-Metadata 头
+	Values    map[string]string `json:"values,omitempty"`   // 解码后的元数据
+	Path      string            `json:"path"`               // 完成后文件保存的相对路径
+	Owner     string            `json:"owner"`              // 创建上传的用户
+	Completed bool              `json:"completed"`          // 是否已转存到目标路径
+	CreatedAt time.Time         `json:"created_at"`         // 创建时间
+	ExpiresAt time.Time         `json:"expires_at"`         // 过期时间，每次写入后顺延
+}
+
+// TusChecksum 表示 Upload-Checksum 头中的校验值。
+type TusChecksum struct {
+	Algorithm string // 算法名称：md5、sha1 或 sha256
+	Sum       []byte // 期望的摘要
+}
+
+// TusStore 定义 tus 上传元数据的持久化存储接口。
+type TusStore interface {
+	// Create 保存新上传。
+	Create(upload TusUpload) error
+
+	// Get 根据ID获取上传，不存在时返回 ErrTusUploadNotFound。
+	Get(id string) (TusUpload, error)
+
+	// Update 保存上传的最新状态，不存在时返回 ErrTusUploadNotFound。
+	Update(upload TusUpload) error
+
+	// List 返回所有上传，按创建时间排序。
+	List() []TusUpload
+
+	// Delete 删除上传及其数据，不存在时返回 ErrTusUploadNotFound。
+	Delete(id string) error
+}
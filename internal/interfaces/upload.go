@@ -0,0 +1,81 @@
+package interfaces
+
+import (
+	"errors"
+	"time"
+)
+
+// 上传会话相关错误。
+var (
+	// ErrUploadNotFound 表示上传会话不存在或已结束。
+	ErrUploadNotFound = errors.New("upload session not found")
+
+	// ErrUploadInvalid 表示创建会话的参数非法（大小、分片大小或校验算法）。
+	ErrUploadInvalid = errors.New("invalid upload session parameters")
+
+	// ErrChunkOutOfRange 表示分片序号超出会话的分片范围，或分片上传的分片数、分片序号和大小不合法。
+	ErrChunkOutOfRange = errors.New("chunk index out of range")
+
+	// ErrChunkSizeMismatch 表示分片大小与会话约定的大小不一致。
+	ErrChunkSizeMismatch = errors.New("chunk size mismatch")
+
+	// ErrUploadIncomplete 表示仍有分片未上传，无法完成会话。
+	ErrUploadIncomplete = errors.New("upload is incomplete")
+
+	// ErrUploadBusy 表示会话正在被另一个请求合并。
+	ErrUploadBusy = errors.New("upload session is being completed")
+
+	// ErrUploadHashMismatch 表示合并后的文件与期望的哈希不一致。
+	ErrUploadHashMismatch = errors.New("uploaded file hash mismatch")
+)
+
+// UploadSession 表示一个服务端上传会话。
+// 分片可以按任意顺序、并行上传，全部到达后再合并为目标文件。
+type UploadSession struct {
+	ID            string    `json:"id"`                       // 会话ID
+	Path          string    `json:"path"`                     // 目标文件的相对路径
+	Size          int64     `json:"size"`                     // 文件总大小（字节）
+	ChunkSize     int64     `json:"chunk_size"`               // 分片大小（字节），最后一个分片可以更小
+	TotalChunks   int       `json:"total_chunks"`             // 分片总数
+	HashAlgorithm string    `json:"hash_algorithm,omitempty"` // 期望哈希的算法（md5 或 sha256）
+	Hash          string    `json:"hash,omitempty"`           // 期望的文件哈希（十六进制），为空时不校验
+	Received      string    `json:"received"`                 // 已接收分片位图，第 i 个字符为 1 表示分片 i 已接收
+	Owner         string    `json:"owner"`                    // 创建会话的用户
+	CreatedAt     time.Time `json:"created_at"`               // 创建时间
+	UpdatedAt     time.Time `json:"updated_at"`               // 最近一次接收分片的时间
+}
+
+// UploadSessionRequest 表示创建上传会话的参数。
+type UploadSessionRequest struct {
+	Path          string `json:"path"`                     // 目标文件的相对路径
+	Size          int64  `json:"size"`                     // 文件总大小（字节）
+	ChunkSize     int64  `json:"chunk_size,omitempty"`     // 分片大小（字节），0表示使用默认值
+	HashAlgorithm string `json:"hash_algorithm,omitempty"` // md5 或 sha256，默认 md5
+	Hash          string `json:"hash,omitempty"`           // 期望的文件哈希（十六进制）
+}
+
+// UploadStatus 表示上传会话的进度。
+type UploadStatus struct {
+	UploadSession
+	ReceivedChunks int   `json:"received_chunks"` // 已接收的分片数
+	Offset         int64 `json:"offset"`          // 从文件开头连续接收的字节数
+}
+
+// UploadStore 定义上传会话元数据的持久化存储接口。
+// 会话在服务重启后仍然有效。
+type UploadStore interface {
+	// Create 保存新会话。
+	Create(session UploadSession) error
+
+	// Get 根据ID获取会话，不存在时返回 ErrUploadNotFound。
+	Get(id string) (UploadSession, error)
+
+	// MarkChunk 将分片标记为已接收并返回更新后的会话。
+	MarkChunk(id string, index int) (UploadSession, error)
+
+	// List 返回所有会话，按创建时间排序。
+	List() []UploadSession
+
+	// Delete 删除会话及其分片数据，不存在时返回 ErrUploadNotFound。
+	Delete(id string) error
+}
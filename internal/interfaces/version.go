@@ -0,0 +1,50 @@
+package interfaces
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 版本历史相关错误。
+var (
+	// ErrVersioningDisabled 表示没有启用版本历史。
+	ErrVersioningDisabled = errors.New("versioning is not enabled")
+
+	// ErrVersionNotFound 表示文件没有指定的版本，或该版本已被清理。
+	ErrVersionNotFound = errors.New("version not found")
+)
+
+// FileVersion 表示文件被覆盖前保存的一个历史版本。
+type FileVersion struct {
+	Version   int       `json:"version"`          // 版本号，每个文件从1开始递增，不会重复使用
+	Size      int64     `json:"size"`             // 文件大小（字节）
+	MD5       string    `json:"md5,omitempty"`    // MD5值，保存时已知才有
+	SHA256    string    `json:"sha256,omitempty"` // SHA-256值，保存时已知才有
+	ModTime   time.Time `json:"mod_time"`         // 该版本内容的修改时间
+	CreatedAt time.Time `json:"created_at"`       // 被覆盖（保存为版本）的时间
+}
+
+// Versioner 定义文件版本历史的接口。
+// 文件被覆盖前的内容保存为版本，按保留策略（保留最近的 N 个、保留 D 天）清理。
+type Versioner interface {
+	// ListVersions 返回文件的历史版本，最新的在前。
+	// 文件和历史版本都不存在时返回 os.ErrNotExist。
+	ListVersions(ctx context.Context, filePath string) ([]FileVersion, error)
+
+	// DownloadVersion 下载文件的历史版本，支持 Range 和条件请求。
+	DownloadVersion(ctx context.Context, c *gin.Context, filePath string, version int, rangeHeader string) error
+
+	// RestoreVersion 用历史版本替换当前文件，当前内容先保存为新版本，因此恢复也可以撤销。
+	RestoreVersion(ctx context.Context, filePath string, version int) error
+
+	VersionPruner
+}
+
+// VersionPruner 定义按保留策略清理历史版本的接口，由后台清理定期调用。
+type VersionPruner interface {
+	// PruneVersions 删除超过保留时间的版本，返回删除的版本数和释放的字节数。
+	PruneVersions(ctx context.Context) (CleanupStats, error)
+}
@@ -7,6 +7,8 @@ import (
 	"sync"
 	"time"
 
+	"lfs/internal/interfaces"
+
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
@@ -18,14 +20,25 @@ var upgrader = websocket.Upgrader{
 }
 
 // ChatMessage 表示一条聊天消息。
+// 客户端发送 subscribe 消息（可带 paths 和 events 过滤条件）后开始接收 event 消息，发送 unsubscribe 停止接收。
 type ChatMessage struct {
-	Type      string `json:"type"`      // 消息类型：message、join、leave
-	IP        string `json:"ip"`        // 客户端IP地址
-	Nickname  string `json:"nickname"`  // 用户昵称
-	Message   string `json:"message"`   // 消息内容
-	Timestamp string `json:"timestamp"` // 时间戳
+	Type      string            `json:"type"`             // 消息类型：message、join、leave、event；客户端还可以发送 subscribe、unsubscribe
+	IP        string            `json:"ip"`               // 客户端IP地址
+	Nickname  string            `json:"nickname"`         // 用户昵称
+	Message   string            `json:"message"`          // 消息内容
+	Timestamp string            `json:"timestamp"`        // 时间戳
+	Event     *interfaces.Event `json:"event,omitempty"`  // 推送的事件，仅 event
+	Paths     []string          `json:"paths,omitempty"`  // 订阅的路径，仅 subscribe
+	Events    []string          `json:"events,omitempty"` // 订阅的事件类型，仅 subscribe
 }
 
+// 事件订阅相关的消息类型。
+const (
+	chatMessageEvent       = "event"
+	chatMessageSubscribe   = "subscribe"
+	chatMessageUnsubscribe = "unsubscribe"
+)
+
 // Client 表示一个WebSocket客户端连接。
 type Client struct {
 	conn     *websocket.Conn
@@ -33,6 +46,7 @@ type Client struct {
 	nickname string
 	send     chan ChatMessage
 	hub      *ChatHub
+	filter   *interfaces.EventFilter // 事件订阅条件，为空表示未订阅；由 hub.mutex 保护
 }
 
 // ChatHub 管理所有WebSocket客户端连接和消息广播。
@@ -98,6 +112,11 @@ func (h *ChatHub) Run() {
 			}()
 
 		case message := <-h.broadcast:
+			if message.Type == chatMessageEvent {
+				h.deliverEvent(message)
+				continue
+			}
+
 			h.mutex.RLock()
 			for client := range h.clients {
 				select {
@@ -112,6 +131,23 @@ func (h *ChatHub) Run() {
 	}
 }
 
+// deliverEvent 将事件发送给订阅条件匹配的客户端。
+// 客户端处理不过来时丢弃事件，不断开连接。
+func (h *ChatHub) deliverEvent(message ChatMessage) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for client := range h.clients {
+		if client.filter == nil || !client.filter.Matches(*message.Event) {
+			continue
+		}
+		select {
+		case client.send <- message:
+		default:
+		}
+	}
+}
+
 // ChatService 实现聊天服务的业务逻辑。
 // 它管理WebSocket连接、消息广播和客户端状态。
 type ChatService struct {
@@ -119,10 +155,23 @@ type ChatService struct {
 }
 
 // NewChatService 创建并返回一个新的聊天服务实例。
-// 会自动启动hub的消息处理goroutine。
-func NewChatService() *ChatService {
+// 会自动启动hub的消息处理goroutine；events 不为空时把其中的事件转发给订阅了的客户端。
+func NewChatService(events interfaces.EventService) *ChatService {
 	hub := NewChatHub()
 	go hub.Run()
+
+	if events != nil {
+		ch, _ := events.Subscribe(interfaces.EventFilter{})
+		go func() {
+			for event := range ch {
+				hub.broadcast <- ChatMessage{
+					Type:      chatMessageEvent,
+					Event:     &event,
+					Timestamp: event.Time.Format("2006-01-02 15:04:05"),
+				}
+			}
+		}()
+	}
 	return &ChatService{hub: hub}
 }
 
@@ -197,6 +246,19 @@ func (c *Client) readPump() {
 			continue
 		}
 
+		switch msg.Type {
+		case chatMessageSubscribe:
+			c.hub.mutex.Lock()
+			c.filter = &interfaces.EventFilter{Types: msg.Events, Paths: msg.Paths}
+			c.hub.mutex.Unlock()
+			continue
+		case chatMessageUnsubscribe:
+			c.hub.mutex.Lock()
+			c.filter = nil
+			c.hub.mutex.Unlock()
+			continue
+		}
+
 		msg.Type = "message"
 		msg.IP = c.ip
 		msg.Nickname = c.nickname
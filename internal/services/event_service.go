@@ -0,0 +1,86 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"lfs/internal/interfaces"
+)
+
+// eventBufferSize is how many undelivered events a subscriber may have before new ones are dropped.
+const eventBufferSize = 256
+
+// eventSubscriber is one SSE or WebSocket client.
+type eventSubscriber struct {
+	filter interfaces.EventFilter
+	ch     chan interfaces.Event
+}
+
+// EventService fans out hash, upload and file change events to subscribers.
+// Publishing never blocks: a subscriber that falls behind misses events instead
+// of slowing down uploads or hashing.
+type EventService struct {
+	mutex       sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+	seq         uint64
+}
+
+// NewEventService creates and returns a new event service instance.
+func NewEventService() *EventService {
+	return &EventService{
+		subscribers: make(map[*eventSubscriber]struct{}),
+	}
+}
+
+// Publish assigns an ID to the event and delivers it to matching subscribers.
+func (s *EventService) Publish(event interfaces.Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	// Assign the ID and deliver under one lock so subscribers see IDs in order
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.seq++
+	event.ID = s.seq
+	for sub := range s.subscribers {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// Subscriber is too slow, drop the event
+		}
+	}
+}
+
+// Subscribe registers a subscriber for events matching filter.
+// The returned function unsubscribes and closes the channel.
+func (s *EventService) Subscribe(filter interfaces.EventFilter) (<-chan interfaces.Event, func()) {
+	sub := &eventSubscriber{
+		filter: filter,
+		ch:     make(chan interfaces.Event, eventBufferSize),
+	}
+
+	s.mutex.Lock()
+	s.subscribers[sub] = struct{}{}
+	s.mutex.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			s.mutex.Lock()
+			delete(s.subscribers, sub)
+			s.mutex.Unlock()
+			close(sub.ch)
+		})
+	}
+}
+
+// publishEvent publishes an event when a publisher is configured.
+func publishEvent(events interfaces.EventPublisher, event interfaces.Event) {
+	if events != nil {
+		events.Publish(event)
+	}
+}
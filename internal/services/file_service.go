@@ -2,8 +2,13 @@ package services
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
+	"io"
 	"mime/multipart"
+	"os"
+	"path"
 	"strings"
 	"sync"
 
@@ -13,55 +18,220 @@ import (
 )
 
 // FileService implements file service business logic.
-// It encapsulates file storage and MD5 calculation implementations, providing a unified business interface.
+// It encapsulates file storage and hashing implementations, providing a unified business interface.
 type FileService struct {
 	storage     interfaces.Storage
-	md5Calc     interfaces.MD5Calculator
+	hasher      interfaces.FileHasher
+	locks       interfaces.LockService
+	quota       interfaces.QuotaChecker
 	storagePath string
+	putting     sync.Map // cleaned target path -> struct{}, serializes PUT requests per file
 }
 
 // NewFileService creates and returns a new file service instance.
-// storage is used for file storage operations, md5Calc is used for MD5 calculation, storagePath is the storage path.
-func NewFileService(storage interfaces.Storage, md5Calc interfaces.MD5Calculator, storagePath string) *FileService {
+// storage is used for file storage operations, hasher computes MD5 and other digests,
+// locks rejects writes to paths locked by other users, quota rejects uploads that would
+// exceed a quota or the minimum free space, storagePath is the storage path.
+func NewFileService(storage interfaces.Storage, hasher interfaces.FileHasher, locks interfaces.LockService,
+	quota interfaces.QuotaChecker, storagePath string) *FileService {
 	return &FileService{
 		storage:     storage,
-		md5Calc:     md5Calc,
+		hasher:      hasher,
+		locks:       locks,
+		quota:       quota,
 		storagePath: storagePath,
 	}
 }
 
-// UploadFile uploads a file.
-func (s *FileService) UploadFile(ctx context.Context, file *multipart.FileHeader, rangeHeader string) error {
-	return s.storage.SaveFile(ctx, file, rangeHeader)
+// UploadFile uploads a file into targetDir, handling an existing target according to conflict.
+// The file's size is reserved against the quotas while it is saved.
+func (s *FileService) UploadFile(ctx context.Context, targetDir string, file *multipart.FileHeader, rangeHeader string, conflict interfaces.ConflictPolicy) (interfaces.SaveResult, error) {
+	release, err := s.reserveUpload(ctx, path.Join(targetDir, file.Filename), file.Size)
+	if err != nil {
+		return interfaces.SaveResult{}, err
+	}
+	return s.saveUpload(ctx, targetDir, file, rangeHeader, conflict, release)
+}
+
+// reserveUpload checks write access to filePath and reserves size bytes for writing it.
+func (s *FileService) reserveUpload(ctx context.Context, filePath string, size int64) (func(int64), error) {
+	if err := s.locks.CheckWriteAccess(ctx, filePath); err != nil {
+		return nil, err
+	}
+	return s.quota.ReserveUpload(ctx, filePath, size)
+}
+
+// saveUpload saves a multipart file whose space has been reserved and releases the
+// reservation with the bytes that were stored.
+func (s *FileService) saveUpload(ctx context.Context, targetDir string, file *multipart.FileHeader, rangeHeader string,
+	conflict interfaces.ConflictPolicy, release func(int64)) (interfaces.SaveResult, error) {
+	result, err := s.storage.SaveFile(ctx, targetDir, file, rangeHeader, conflict)
+	var written int64
+	if err == nil && !result.Skipped {
+		written = file.Size
+	}
+	release(written)
+	return result, err
+}
+
+// UploadFileChunk uploads a file chunk, returning the merge report once the last chunk has arrived.
+// Every chunk reserves the declared size of the whole file, which the merge needs on top of
+// the chunks; chunks of the same file sent in parallel share the reservation.
+func (s *FileService) UploadFileChunk(ctx context.Context, chunkInfo interfaces.FileChunkInfo, file *multipart.FileHeader) (*interfaces.ChunkMergeReport, error) {
+	release, err := s.reserveUpload(ctx, chunkInfo.FileName, chunkInfo.TotalSize)
+	if err != nil {
+		return nil, err
+	}
+	report, err := s.storage.SaveFileChunk(ctx, chunkInfo, file)
+	var written int64
+	if err == nil && report != nil && !report.Skipped {
+		written = chunkInfo.TotalSize
+	}
+	release(written)
+	return report, err
+}
+
+// PutFile writes a raw request body to a file. Without a range the file is replaced
+// atomically; ranged requests append segments to a hidden partial file that replaces
+// the target once the last byte has arrived. Nothing is kept from a request whose
+// length or digests do not match, and preconditions are evaluated against the target.
+func (s *FileService) PutFile(ctx context.Context, filePath string, data io.Reader, opts interfaces.PutFileOptions) (interfaces.PutFileResult, error) {
+	result := interfaces.PutFileResult{Total: opts.Total}
+	if err := s.locks.CheckWriteAccess(ctx, filePath); err != nil {
+		return result, err
+	}
+
+	// Requests for the same file are serialized so that preconditions still hold when the target is replaced
+	key := path.Clean("/" + filePath)
+	if _, busy := s.putting.LoadOrStore(key, struct{}{}); busy {
+		return result, interfaces.ErrUploadBusy
+	}
+	defer s.putting.Delete(key)
+
+	current, exists, err := s.statTarget(ctx, filePath)
+	if err != nil {
+		return result, err
+	}
+	if err := checkPreconditions(current, exists, opts.IfMatch, opts.IfNoneMatch); err != nil {
+		return result, err
+	}
+	result.Created = !exists
+
+	if !opts.Ranged {
+		release, err := s.quota.ReserveUpload(ctx, filePath, opts.Length)
+		if err != nil {
+			return result, err
+		}
+		body, err := digestReader(data, opts.Length, opts.Digests)
+		if err != nil {
+			release(0)
+			return result, err
+		}
+		if err := s.storage.WriteFile(ctx, filePath, body); err != nil {
+			release(0)
+			return result, err
+		}
+		result, err = s.completePut(ctx, filePath, result)
+		release(result.Offset)
+		return result, err
+	}
+
+	result.Offset, err = s.storage.PartialFileSize(ctx, filePath)
+	if err != nil || opts.Start < 0 {
+		return result, err
+	}
+	// Segments may be resent, but never leave a gap
+	if opts.Start > result.Offset {
+		return result, interfaces.ErrOffsetMismatch
+	}
+	// The bytes received so far are already on disk; the rest is reserved while this segment is written
+	// and the growth of the partial file is recorded, committing it moves no data
+	release, err := s.quota.ReserveUpload(ctx, filePath, opts.Total-result.Offset)
+	if err != nil {
+		return result, err
+	}
+	body, err := digestReader(data, opts.End-opts.Start+1, opts.Digests)
+	if err != nil {
+		release(0)
+		return result, err
+	}
+	received := result.Offset
+	result.Offset, err = s.storage.WritePartialFile(ctx, filePath, opts.Start, body)
+	release(max(result.Offset-received, 0))
+	if err != nil || result.Offset < opts.Total {
+		return result, err
+	}
+
+	if err := s.storage.CommitPartialFile(ctx, filePath, opts.Total); err != nil {
+		return result, err
+	}
+	return s.completePut(ctx, filePath, result)
 }
 
-// UploadFileChunk uploads a file chunk.
-func (s *FileService) UploadFileChunk(ctx context.Context, chunkInfo interfaces.FileChunkInfo, file *multipart.FileHeader) error {
-	return s.storage.SaveFileChunk(ctx, chunkInfo, file)
+// statTarget returns the metadata of a PUT target and whether it exists. Directories are rejected.
+func (s *FileService) statTarget(ctx context.Context, filePath string) (interfaces.FileMetadata, bool, error) {
+	info, err := s.storage.StatFile(ctx, filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return interfaces.FileMetadata{}, false, nil
+	}
+	if err != nil {
+		return interfaces.FileMetadata{}, false, err
+	}
+	if info.IsDir {
+		return interfaces.FileMetadata{}, false, interfaces.ErrInvalidPath
+	}
+	return info, true, nil
+}
+
+// completePut fills in the result of a PUT whose content has replaced the target.
+func (s *FileService) completePut(ctx context.Context, filePath string, result interfaces.PutFileResult) (interfaces.PutFileResult, error) {
+	info, err := s.storage.StatFile(ctx, filePath)
+	if err != nil {
+		return result, err
+	}
+	result.Offset = info.Size
+	result.Total = info.Size
+	result.Complete = true
+	result.File = &info
+	return result, nil
 }
 
 // BatchUpload performs batch upload (reuses single file upload implementation, supports concurrent processing).
-func (s *FileService) BatchUpload(ctx context.Context, files []*multipart.FileHeader) (successCount, errorCount int, errors []string) {
+// Space for every file is reserved in order before any is saved, so the files are checked
+// against the quotas together; files that no longer fit are reported as errors.
+func (s *FileService) BatchUpload(ctx context.Context, targetDir string, files []*multipart.FileHeader, conflict interfaces.ConflictPolicy) (results []interfaces.SaveResult, errors []string) {
 	if len(files) == 0 {
-		return 0, 0, nil
+		return nil, nil
 	}
 
 	// Single file: directly call single file upload
 	if len(files) == 1 {
-		if err := s.storage.SaveFile(ctx, files[0], ""); err != nil {
-			return 0, 1, []string{err.Error()}
+		result, err := s.UploadFile(ctx, targetDir, files[0], "", conflict)
+		if err != nil {
+			return []interfaces.SaveResult{}, []string{err.Error()}
 		}
-		return 1, 0, nil
+		return []interfaces.SaveResult{result}, []string{}
 	}
 
 	// Multiple files: concurrent processing
 	type uploadResult struct {
-		err error
+		result interfaces.SaveResult
+		err    error
 	}
 
 	resultChan := make(chan uploadResult, len(files))
 	var wg sync.WaitGroup
 
+	releases := make([]func(int64), len(files))
+	for i, file := range files {
+		release, err := s.reserveUpload(ctx, path.Join(targetDir, file.Filename), file.Size)
+		if err != nil {
+			resultChan <- uploadResult{err: err}
+			continue
+		}
+		releases[i] = release
+	}
+
 	// Limit concurrency to avoid resource exhaustion
 	maxConcurrent := 10
 	if maxConcurrent > len(files) {
@@ -70,16 +240,19 @@ func (s *FileService) BatchUpload(ctx context.Context, files []*multipart.FileHe
 
 	semaphore := make(chan struct{}, maxConcurrent)
 
-	for _, file := range files {
+	for i, file := range files {
+		if releases[i] == nil {
+			continue
+		}
 		wg.Add(1)
-		go func(f *multipart.FileHeader) {
+		go func(f *multipart.FileHeader, release func(int64)) {
 			defer wg.Done()
 			semaphore <- struct{}{}        // Acquire semaphore
 			defer func() { <-semaphore }() // Release semaphore
 
-			err := s.storage.SaveFile(ctx, f, "")
-			resultChan <- uploadResult{err: err}
-		}(file)
+			result, err := s.saveUpload(ctx, targetDir, f, "", conflict, release)
+			resultChan <- uploadResult{result: result, err: err}
+		}(file, releases[i])
 	}
 
 	go func() {
@@ -87,17 +260,17 @@ func (s *FileService) BatchUpload(ctx context.Context, files []*multipart.FileHe
 		close(resultChan)
 	}()
 
+	results = make([]interfaces.SaveResult, 0, len(files))
 	errorList := make([]string, 0)
-	for result := range resultChan {
-		if result.err != nil {
-			errorCount++
-			errorList = append(errorList, result.err.Error())
+	for r := range resultChan {
+		if r.err != nil {
+			errorList = append(errorList, r.err.Error())
 		} else {
-			successCount++
+			results = append(results, r.result)
 		}
 	}
 
-	return successCount, errorCount, errorList
+	return results, errorList
 }
 
 // DownloadFile downloads a file.
@@ -110,31 +283,117 @@ func (s *FileService) DownloadFileChunk(ctx context.Context, c *gin.Context, fil
 	return s.storage.DownloadFileChunk(ctx, c, filename, chunkIndex, chunkSize)
 }
 
-// ListFiles lists files.
-func (s *FileService) ListFiles(ctx context.Context, path string) ([]interfaces.FileMetadata, error) {
-	// Security check: prevent path traversal attacks
-	if path != "" && strings.Contains(path, "..") {
-		return nil, errors.New("invalid path")
-	}
+// DownloadArchive streams the given files and directories as a single archive.
+func (s *FileService) DownloadArchive(ctx context.Context, w io.Writer, paths []string, opts interfaces.ArchiveOptions) error {
+	return s.storage.WriteArchive(ctx, w, paths, opts)
+}
 
-	// If a path is specified, storage path needs to be adjusted
-	// This is simplified; actual implementation should support subdirectories
-	return s.storage.ListFiles(ctx)
+// ListFiles lists one page of the requested directory.
+// The path is validated by the storage layer like every other storage entry point.
+func (s *FileService) ListFiles(ctx context.Context, opts interfaces.ListOptions) (interfaces.FileList, error) {
+	return s.storage.ListFiles(ctx, opts)
 }
 
 // GetFileMD5 gets the MD5 hash of a file.
 func (s *FileService) GetFileMD5(ctx context.Context, filename string) (string, error) {
-	filePath := s.storage.GetFilePath(filename)
-	return s.md5Calc.GetMD5(ctx, filePath)
+	return s.hasher.GetMD5(ctx, filename)
+}
+
+// GetFileHashes gets several digests of a file, computing the uncached ones in a single pass.
+func (s *FileService) GetFileHashes(ctx context.Context, filename string, algorithms []string) (map[string]string, error) {
+	return s.hasher.GetHashes(ctx, filename, algorithms)
 }
 
 // GetFileMD5Progress gets the MD5 calculation progress.
 func (s *FileService) GetFileMD5Progress(filename string) (float64, bool, string) {
-	filePath := s.storage.GetFilePath(filename)
-	return s.md5Calc.GetMD5Progress(filePath)
+	return s.hasher.GetMD5Progress(filename)
 }
 
 // CheckFileExists checks if a file exists.
 func (s *FileService) CheckFileExists(ctx context.Context, filename string) error {
 	return s.storage.CheckFileExists(ctx, filename)
 }
+
+// DeleteFile deletes a file unless it is locked by another user.
+func (s *FileService) DeleteFile(ctx context.Context, filename string) error {
+	if err := s.locks.CheckWriteAccess(ctx, filename); err != nil {
+		return err
+	}
+	return s.storage.DeleteFile(ctx, filename)
+}
+
+// RenameFile renames a file or directory unless it is locked by another user.
+func (s *FileService) RenameFile(ctx context.Context, filename, newName string) error {
+	if err := s.locks.CheckWriteAccess(ctx, filename); err != nil {
+		return err
+	}
+	if err := s.locks.CheckWriteAccess(ctx, path.Join(path.Dir(filename), newName)); err != nil {
+		return err
+	}
+	return s.storage.RenameFile(ctx, filename, newName)
+}
+
+// MoveFile moves a file or directory unless the source or destination is locked by another user.
+func (s *FileService) MoveFile(ctx context.Context, srcPath, dstPath string) error {
+	if err := s.locks.CheckWriteAccess(ctx, srcPath); err != nil {
+		return err
+	}
+	if err := s.locks.CheckWriteAccess(ctx, dstPath); err != nil {
+		return err
+	}
+	return s.storage.MoveFile(ctx, srcPath, dstPath)
+}
+
+// CreateDirectory creates a directory.
+func (s *FileService) CreateDirectory(ctx context.Context, dirPath string) error {
+	return s.storage.CreateDirectory(ctx, dirPath)
+}
+
+// DeleteDirectory deletes a directory unless anything inside it is locked by another user.
+func (s *FileService) DeleteDirectory(ctx context.Context, dirPath string, recursive bool) error {
+	if err := s.locks.CheckWriteAccess(ctx, dirPath); err != nil {
+		return err
+	}
+	return s.storage.DeleteDirectory(ctx, dirPath, recursive)
+}
+
+// checkPreconditions evaluates If-Match and If-None-Match against the current target.
+// If-Match uses the strong comparison and If-None-Match the weak one (RFC 9110 13.1).
+func checkPreconditions(current interfaces.FileMetadata, exists bool, ifMatch, ifNoneMatch string) error {
+	if ifMatch != "" {
+		if !exists || (strings.TrimSpace(ifMatch) != "*" && !interfaces.ETagListContains(ifMatch, current.ETag(), false)) {
+			return interfaces.ErrPreconditionFailed
+		}
+	}
+	if ifNoneMatch != "" && exists {
+		if strings.TrimSpace(ifNoneMatch) == "*" || interfaces.ETagListContains(ifNoneMatch, current.ETag(), true) {
+			return interfaces.ErrPreconditionFailed
+		}
+	}
+	return nil
+}
+
+// digestReader wraps a request body so that reading it fails unless it has exactly
+// size bytes (size < 0 skips the check) and matches every digest.
+func digestReader(data io.Reader, size int64, digests []interfaces.ContentDigest) (io.Reader, error) {
+	var r io.Reader = &verifyingReader{
+		r:       data,
+		hash:    md5.New(),
+		size:    size,
+		sizeErr: interfaces.ErrContentLengthMismatch,
+	}
+	for _, digest := range digests {
+		h, err := newUploadHash(digest.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+		r = &verifyingReader{
+			r:        r,
+			hash:     h,
+			expected: hex.EncodeToString(digest.Sum),
+			size:     -1,
+			hashErr:  interfaces.ErrDigestMismatch,
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"lfs/internal/interfaces"
+)
+
+// janitorMetricKey is the MetricsService key the janitor reports under.
+const janitorMetricKey = "janitor"
+
+// JanitorService periodically removes abandoned upload state: stale chunk
+// directories and temporary files, upload sessions that have not received a
+// chunk within maxAge, and expired tus uploads. It also prunes expired file versions.
+type JanitorService struct {
+	cleaner  interfaces.StaleFileCleaner
+	versions interfaces.VersionPruner
+	uploads  interfaces.UploadStore
+	tus      interfaces.TusStore
+	metrics  interfaces.MetricsService
+	interval time.Duration
+	maxAge   time.Duration
+
+	mutex   sync.Mutex // serializes runs
+	runs    int64
+	errors  int64
+	totals  interfaces.CleanupStats
+	lastRun time.Time
+}
+
+// NewJanitorService creates and returns a new janitor service instance.
+// interval is the time between runs, maxAge is how long upload state may stay
+// untouched before it is considered abandoned. versions is nil when versioning is disabled.
+func NewJanitorService(cleaner interfaces.StaleFileCleaner, versions interfaces.VersionPruner, uploads interfaces.UploadStore,
+	tus interfaces.TusStore, metrics interfaces.MetricsService, interval, maxAge time.Duration) *JanitorService {
+	return &JanitorService{
+		cleaner:  cleaner,
+		versions: versions,
+		uploads:  uploads,
+		tus:      tus,
+		metrics:  metrics,
+		interval: interval,
+		maxAge:   maxAge,
+	}
+}
+
+// Start runs the janitor once immediately and then every interval until ctx is cancelled.
+func (s *JanitorService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Janitor run failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce performs a single cleanup pass and records the result in the metrics service.
+func (s *JanitorService) RunOnce(ctx context.Context) (interfaces.CleanupStats, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var stats interfaces.CleanupStats
+	now := time.Now()
+	cutoff := now.Add(-s.maxAge)
+
+	for _, session := range s.uploads.List() {
+		if session.UpdatedAt.Before(cutoff) && s.uploads.Delete(session.ID) == nil {
+			stats.UploadSessions++
+		}
+	}
+	for _, upload := range s.tus.List() {
+		if now.After(upload.ExpiresAt) && s.tus.Delete(upload.ID) == nil {
+			stats.TusUploads++
+		}
+	}
+
+	// Session data was removed with the sessions above; the cleaner handles what has no owner
+	cleaned, err := s.cleaner.CleanStaleFiles(ctx, s.maxAge)
+	stats.Add(cleaned)
+	if s.versions != nil && err == nil {
+		var pruned interfaces.CleanupStats
+		pruned, err = s.versions.PruneVersions(ctx)
+		stats.Add(pruned)
+	}
+
+	s.runs++
+	if err != nil {
+		s.errors++
+	}
+	s.totals.Add(stats)
+	s.lastRun = now
+	s.recordMetrics(stats, err)
+	return stats, err
+}
+
+// recordMetrics publishes the last run and running totals. Must be called with s.mutex held.
+func (s *JanitorService) recordMetrics(last interfaces.CleanupStats, err error) {
+	lastError := ""
+	if err != nil {
+		lastError = err.Error()
+	}
+	s.metrics.RecordMetric(janitorMetricKey, map[string]interface{}{
+		"interval":   s.interval.String(),
+		"max_age":    s.maxAge.String(),
+		"runs":       s.runs,
+		"errors":     s.errors,
+		"last_run":   s.lastRun.UTC().Format(time.RFC3339),
+		"last_error": lastError,
+		"last":       last,
+		"total":      s.totals,
+	})
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"lfs/internal/interfaces"
+)
+
+// maxFinishedJobs is how many finished jobs are kept for GET /jobs.
+const maxFinishedJobs = 200
+
+// job is the internal state of a submitted job.
+type job struct {
+	interfaces.Job
+	run    interfaces.JobFunc
+	seq    uint64             // submission order, breaks priority ties
+	index  int                // position in the queue, -1 when not queued
+	cancel context.CancelFunc // set while running
+	done   chan struct{}      // closed when the job finishes
+}
+
+// jobQueue is a priority queue of jobs; higher priority first, then submission order.
+type jobQueue []*job
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].Priority != q[j].Priority {
+		return q[i].Priority > q[j].Priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q jobQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *jobQueue) Push(x interface{}) {
+	j := x.(*job)
+	j.index = len(*q)
+	*q = append(*q, j)
+}
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	j := old[len(old)-1]
+	old[len(old)-1] = nil
+	j.index = -1
+	*q = old[:len(old)-1]
+	return j
+}
+
+// JobService runs background jobs such as hashing on a fixed pool of workers.
+// Jobs wait in a priority queue and can be listed and cancelled while queued or running.
+type JobService struct {
+	workers int
+
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	queue    jobQueue
+	jobs     map[string]*job
+	active   map[string]*job // type + target -> unfinished job
+	finished []string        // IDs of finished jobs, oldest first
+	seq      uint64
+	ctx      context.Context // set by Start
+}
+
+// NewJobService creates and returns a new job service instance.
+// workers is the number of jobs run at the same time.
+func NewJobService(workers int) *JobService {
+	if workers < 1 {
+		workers = 1
+	}
+	s := &JobService{
+		workers: workers,
+		jobs:    make(map[string]*job),
+		active:  make(map[string]*job),
+	}
+	s.cond = sync.NewCond(&s.mutex)
+	return s
+}
+
+// Start launches the workers. When ctx is cancelled running jobs are cancelled
+// and the workers exit; queued jobs are marked as cancelled.
+func (s *JobService) Start(ctx context.Context) {
+	s.mutex.Lock()
+	s.ctx = ctx
+	s.mutex.Unlock()
+
+	for i := 0; i < s.workers; i++ {
+		go s.work(ctx)
+	}
+	go func() {
+		<-ctx.Done()
+		s.mutex.Lock()
+		for s.queue.Len() > 0 {
+			s.finishLocked(heap.Pop(&s.queue).(*job), interfaces.JobCanceled, ctx.Err())
+		}
+		s.cond.Broadcast()
+		s.mutex.Unlock()
+	}()
+}
+
+// Submit queues a job. An unfinished job with the same type and target is
+// returned instead, with its priority raised if the new one is higher.
+func (s *JobService) Submit(req interfaces.JobRequest) (interfaces.Job, error) {
+	if req.Run == nil || req.Type == "" {
+		return interfaces.Job{}, errors.New("invalid job request")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := req.Type + "\x00" + req.Target
+	if existing, ok := s.active[key]; ok {
+		if req.Priority > existing.Priority {
+			existing.Priority = req.Priority
+			if existing.index >= 0 {
+				heap.Fix(&s.queue, existing.index)
+			}
+		}
+		return existing.Job, nil
+	}
+
+	id, err := newRandomID()
+	if err != nil {
+		return interfaces.Job{}, err
+	}
+	s.seq++
+	j := &job{
+		Job: interfaces.Job{
+			ID:        id,
+			Type:      req.Type,
+			Target:    req.Target,
+			Priority:  req.Priority,
+			State:     interfaces.JobQueued,
+			CreatedAt: time.Now(),
+		},
+		run:  req.Run,
+		seq:  s.seq,
+		done: make(chan struct{}),
+	}
+	s.jobs[id] = j
+	s.active[key] = j
+	heap.Push(&s.queue, j)
+	s.cond.Signal()
+	return j.Job, nil
+}
+
+// List returns queued, running and recently finished jobs, newest first.
+// Empty state or jobType means no filtering.
+func (s *JobService) List(state, jobType string) []interfaces.Job {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]interfaces.Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		if (state == "" || j.State == state) && (jobType == "" || j.Type == jobType) {
+			result = append(result, j.Job)
+		}
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].CreatedAt.After(result[b].CreatedAt)
+	})
+	return result
+}
+
+// Get returns the state of a job.
+func (s *JobService) Get(id string) (interfaces.Job, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return interfaces.Job{}, interfaces.ErrJobNotFound
+	}
+	return j.Job, nil
+}
+
+// Wait blocks until a job finishes and returns its final state.
+// When ctx ends first its error is returned and the job keeps running.
+func (s *JobService) Wait(ctx context.Context, id string) (interfaces.Job, error) {
+	s.mutex.Lock()
+	j, ok := s.jobs[id]
+	s.mutex.Unlock()
+	if !ok {
+		return interfaces.Job{}, interfaces.ErrJobNotFound
+	}
+
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+		return interfaces.Job{}, ctx.Err()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return j.Job, nil
+}
+
+// Cancel cancels a queued or running job. Running jobs stop once their
+// function notices the cancelled context.
+func (s *JobService) Cancel(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return interfaces.ErrJobNotFound
+	}
+	switch {
+	case j.Finished():
+		return interfaces.ErrJobFinished
+	case j.index >= 0:
+		heap.Remove(&s.queue, j.index)
+		s.finishLocked(j, interfaces.JobCanceled, context.Canceled)
+	case j.cancel != nil:
+		j.cancel()
+	}
+	return nil
+}
+
+// work runs queued jobs until ctx is cancelled.
+func (s *JobService) work(ctx context.Context) {
+	for {
+		s.mutex.Lock()
+		for s.queue.Len() == 0 && ctx.Err() == nil {
+			s.cond.Wait()
+		}
+		if ctx.Err() != nil {
+			s.mutex.Unlock()
+			return
+		}
+		j := heap.Pop(&s.queue).(*job)
+		jobCtx, cancel := context.WithCancel(ctx)
+		now := time.Now()
+		j.State = interfaces.JobRunning
+		j.StartedAt = &now
+		j.cancel = cancel
+		s.mutex.Unlock()
+
+		err := j.run(jobCtx, func(progress float64) {
+			s.mutex.Lock()
+			j.Progress = progress
+			s.mutex.Unlock()
+		})
+		canceled := jobCtx.Err() != nil
+		cancel()
+
+		s.mutex.Lock()
+		switch {
+		case canceled && (err == nil || errors.Is(err, context.Canceled)):
+			s.finishLocked(j, interfaces.JobCanceled, context.Canceled)
+		case err != nil:
+			s.finishLocked(j, interfaces.JobFailed, err)
+		default:
+			j.Progress = 1
+			s.finishLocked(j, interfaces.JobSucceeded, nil)
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// finishLocked records the final state of a job and trims the history.
+// The caller must hold the mutex.
+func (s *JobService) finishLocked(j *job, state string, err error) {
+	now := time.Now()
+	j.State = state
+	j.FinishedAt = &now
+	j.cancel = nil
+	if err != nil {
+		j.Error = err.Error()
+	}
+	close(j.done)
+	key := j.Type + "\x00" + j.Target
+	if s.active[key] == j {
+		delete(s.active, key)
+	}
+
+	s.finished = append(s.finished, j.ID)
+	for len(s.finished) > maxFinishedJobs {
+		delete(s.jobs, s.finished[0])
+		s.finished = s.finished[1:]
+	}
+}
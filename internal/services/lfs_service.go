@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lfsObjectsDir is the object directory relative to the LFS storage root.
+const lfsObjectsDir = "objects"
+
+// lfsOIDPattern matches a lowercase hex-encoded SHA-256 object ID.
+var lfsOIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// LFSService implements the Git LFS v1 Batch API on top of the storage layer.
+// Objects are stored content-addressed as objects/ab/cd/<oid> in a storage rooted at
+// the internal data directory, so they are neither listed nor reachable via file routes.
+type LFSService struct {
+	storage interfaces.Storage
+}
+
+// NewLFSService creates and returns a new Git LFS service instance.
+func NewLFSService(storage interfaces.Storage) *LFSService {
+	return &LFSService{
+		storage: storage,
+	}
+}
+
+// Batch handles a batch request and returns the actions the client should perform.
+func (s *LFSService) Batch(ctx context.Context, req interfaces.LFSBatchRequest, baseURL string) (interfaces.LFSBatchResponse, error) {
+	if req.Operation != "upload" && req.Operation != "download" {
+		return interfaces.LFSBatchResponse{}, errors.New("unsupported operation: " + req.Operation)
+	}
+	if req.HashAlgo != "" && req.HashAlgo != "sha256" {
+		return interfaces.LFSBatchResponse{}, errors.New("unsupported hash algorithm: " + req.HashAlgo)
+	}
+
+	results := make([]interfaces.LFSObjectResult, 0, len(req.Objects))
+	for _, obj := range req.Objects {
+		result := interfaces.LFSObjectResult{OID: obj.OID, Size: obj.Size}
+
+		if !lfsOIDPattern.MatchString(obj.OID) || obj.Size < 0 {
+			result.Error = &interfaces.LFSObjectError{Code: http.StatusUnprocessableEntity, Message: interfaces.ErrLFSInvalidOID.Error()}
+			results = append(results, result)
+			continue
+		}
+
+		href := baseURL + "/objects/" + obj.OID
+		exists := s.hasObject(ctx, obj)
+
+		switch req.Operation {
+		case "upload":
+			// Objects already present need no action
+			if !exists {
+				result.Actions = map[string]*interfaces.LFSAction{
+					"upload": {Href: href},
+					"verify": {Href: baseURL + "/objects/verify"},
+				}
+			}
+		case "download":
+			if exists {
+				result.Actions = map[string]*interfaces.LFSAction{
+					"download": {Href: href},
+				}
+			} else {
+				result.Error = &interfaces.LFSObjectError{Code: http.StatusNotFound, Message: interfaces.ErrLFSObjectNotFound.Error()}
+			}
+		}
+		results = append(results, result)
+	}
+
+	return interfaces.LFSBatchResponse{
+		Transfer: "basic",
+		Objects:  results,
+		HashAlgo: "sha256",
+	}, nil
+}
+
+// UploadObject stores an object, rejecting content whose size or SHA-256 does not match.
+func (s *LFSService) UploadObject(ctx context.Context, oid string, size int64, data io.Reader) error {
+	if !lfsOIDPattern.MatchString(oid) {
+		return interfaces.ErrLFSInvalidOID
+	}
+
+	// Content-addressed objects are immutable, so an existing copy is final
+	if s.hasObject(ctx, interfaces.LFSObject{OID: oid, Size: size}) {
+		return nil
+	}
+
+	return s.storage.WriteFile(ctx, lfsObjectPath(oid), &verifyingReader{
+		r:        data,
+		hash:     sha256.New(),
+		expected: oid,
+		size:     size,
+		sizeErr:  interfaces.ErrLFSSizeMismatch,
+		hashErr:  interfaces.ErrLFSHashMismatch,
+	})
+}
+
+// DownloadObject streams an object to the response.
+func (s *LFSService) DownloadObject(ctx context.Context, c *gin.Context, oid, rangeHeader string) error {
+	if !lfsOIDPattern.MatchString(oid) {
+		return interfaces.ErrLFSInvalidOID
+	}
+	if !s.hasObject(ctx, interfaces.LFSObject{OID: oid, Size: -1}) {
+		return interfaces.ErrLFSObjectNotFound
+	}
+	return s.storage.DownloadFile(ctx, c, lfsObjectPath(oid), rangeHeader)
+}
+
+// VerifyObject confirms that an object has been stored with the expected size.
+func (s *LFSService) VerifyObject(ctx context.Context, obj interfaces.LFSObject) error {
+	if !lfsOIDPattern.MatchString(obj.OID) {
+		return interfaces.ErrLFSInvalidOID
+	}
+	if !s.hasObject(ctx, obj) {
+		return interfaces.ErrLFSObjectNotFound
+	}
+	return nil
+}
+
+// hasObject reports whether an object exists; a negative size skips the size check.
+func (s *LFSService) hasObject(ctx context.Context, obj interfaces.LFSObject) bool {
+	info, err := s.storage.StatFile(ctx, lfsObjectPath(obj.OID))
+	if err != nil || info.IsDir {
+		return false
+	}
+	return obj.Size < 0 || info.Size == obj.Size
+}
+
+// lfsObjectPath returns the storage-relative path of an object.
+func lfsObjectPath(oid string) string {
+	return path.Join(lfsObjectsDir, oid[0:2], oid[2:4], oid)
+}
+
+// verifyingReader hashes data as it is read and fails at EOF if the content
+// does not match the expected digest and size, so partial or corrupt uploads
+// are never committed by the storage layer.
+type verifyingReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected string // empty to skip the digest check
+	size     int64  // negative if unknown
+	read     int64
+	sizeErr  error // returned on size mismatch
+	hashErr  error // returned on digest mismatch
+}
+
+// Read implements io.Reader.
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	if n > 0 {
+		v.hash.Write(p[:n])
+		v.read += int64(n)
+		if v.size >= 0 && v.read > v.size {
+			return n, v.sizeErr
+		}
+	}
+
+	if err == io.EOF {
+		if v.size >= 0 && v.read != v.size {
+			return n, v.sizeErr
+		}
+		if v.expected != "" && hex.EncodeToString(v.hash.Sum(nil)) != v.expected {
+			return n, v.hashErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"path"
+	"strings"
+	"time"
+
+	"lfs/internal/interfaces"
+)
+
+// Lock pagination limits.
+const (
+	defaultLockLimit = 100
+	maxLockLimit     = 1000
+)
+
+// LockService implements the Git LFS locking API on top of a persistent lock store.
+// The identity of each request is taken from the request context.
+type LockService struct {
+	store interfaces.LockStore
+}
+
+// NewLockService creates and returns a new lock service instance.
+func NewLockService(store interfaces.LockStore) *LockService {
+	return &LockService{
+		store: store,
+	}
+}
+
+// CreateLock locks a path for the current user.
+func (s *LockService) CreateLock(ctx context.Context, lockPath, ref string) (interfaces.Lock, error) {
+	owner := interfaces.OwnerFromContext(ctx)
+	if owner == "" {
+		return interfaces.Lock{}, interfaces.ErrUnauthenticated
+	}
+
+	lockPath, err := normalizeLockPath(lockPath)
+	if err != nil {
+		return interfaces.Lock{}, err
+	}
+
+	id, err := newRandomID()
+	if err != nil {
+		return interfaces.Lock{}, err
+	}
+
+	return s.store.Create(interfaces.Lock{
+		ID:       id,
+		Path:     lockPath,
+		LockedAt: time.Now().UTC(),
+		Owner:    interfaces.LockOwner{Name: owner},
+		Ref:      ref,
+	})
+}
+
+// ListLocks lists locks matching the filter, one page at a time.
+func (s *LockService) ListLocks(ctx context.Context, filter interfaces.LockFilter) ([]interfaces.Lock, string, error) {
+	if filter.Path != "" {
+		p, err := normalizeLockPath(filter.Path)
+		if err != nil {
+			return nil, "", err
+		}
+		filter.Path = p
+	}
+
+	var matched []interfaces.Lock
+	for _, lock := range s.store.List() {
+		if filter.Path != "" && lock.Path != filter.Path {
+			continue
+		}
+		if filter.ID != "" && lock.ID != filter.ID {
+			continue
+		}
+		matched = append(matched, lock)
+	}
+
+	page, next := paginateLocks(matched, filter.Cursor, filter.Limit)
+	return page, next, nil
+}
+
+// VerifyLocks lists locks split into those owned by the current user and those owned by others.
+func (s *LockService) VerifyLocks(ctx context.Context, cursor string, limit int) ([]interfaces.Lock, []interfaces.Lock, string, error) {
+	owner := interfaces.OwnerFromContext(ctx)
+	page, next := paginateLocks(s.store.List(), cursor, limit)
+
+	ours := make([]interfaces.Lock, 0)
+	theirs := make([]interfaces.Lock, 0)
+	for _, lock := range page {
+		if owner != "" && lock.Owner.Name == owner {
+			ours = append(ours, lock)
+		} else {
+			theirs = append(theirs, lock)
+		}
+	}
+	return ours, theirs, next, nil
+}
+
+// Unlock releases a lock; releasing another user's lock requires force and an administrator.
+func (s *LockService) Unlock(ctx context.Context, id string, force bool) (interfaces.Lock, error) {
+	identity := interfaces.IdentityFromContext(ctx)
+	if identity.Name == "" {
+		return interfaces.Lock{}, interfaces.ErrUnauthenticated
+	}
+
+	lock, err := s.store.Get(id)
+	if err != nil {
+		return interfaces.Lock{}, err
+	}
+
+	if lock.Owner.Name != identity.Name {
+		if !force {
+			return lock, interfaces.ErrLockNotOwner
+		}
+		if !identity.Admin {
+			return lock, interfaces.ErrLockForbidden
+		}
+	}
+
+	if err := s.store.Delete(id); err != nil {
+		return interfaces.Lock{}, err
+	}
+	return lock, nil
+}
+
+// CheckWriteAccess returns ErrPathLocked if another user holds a lock on the path,
+// or on any path below it when the path is a directory. Unauthenticated requests
+// are blocked by every lock.
+func (s *LockService) CheckWriteAccess(ctx context.Context, filePath string) error {
+	filePath, err := normalizeLockPath(filePath)
+	if err != nil {
+		return err
+	}
+
+	owner := interfaces.OwnerFromContext(ctx)
+	for _, lock := range s.store.List() {
+		if owner != "" && lock.Owner.Name == owner {
+			continue
+		}
+		if lock.Path == filePath || strings.HasPrefix(lock.Path, filePath+"/") {
+			return interfaces.ErrPathLocked
+		}
+	}
+	return nil
+}
+
+// normalizeLockPath converts a path into the slash-separated, root-relative form used as lock key.
+func normalizeLockPath(p string) (string, error) {
+	p = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(p, "\\", "/")), "/")
+	if p == "" {
+		return "", interfaces.ErrInvalidPath
+	}
+	return p, nil
+}
+
+// paginateLocks returns the page starting at cursor and the cursor of the next page.
+// The cursor is the ID of the first lock on the page.
+func paginateLocks(locks []interfaces.Lock, cursor string, limit int) ([]interfaces.Lock, string) {
+	if limit <= 0 {
+		limit = defaultLockLimit
+	}
+	if limit > maxLockLimit {
+		limit = maxLockLimit
+	}
+
+	start := 0
+	if cursor != "" {
+		for i, lock := range locks {
+			if lock.ID == cursor {
+				start = i
+				break
+			}
+		}
+	}
+
+	end := start + limit
+	if end >= len(locks) {
+		return locks[start:], ""
+	}
+	return locks[start:end], locks[end].ID
+}
+
+// newRandomID generates a random hex-encoded ID for locks and upload sessions.
+func newRandomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
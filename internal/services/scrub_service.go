@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"lfs/internal/interfaces"
+	"lfs/pkg/hashing"
+)
+
+// scrubMetricKey is the MetricsService key the scrubber reports under.
+const scrubMetricKey = "scrub"
+
+// ScrubService periodically re-reads stored files and compares them with the
+// hash index to catch silent corruption. Each scrub runs as a background job,
+// so it can be watched and cancelled through the job API.
+type ScrubService struct {
+	scrubber interfaces.Scrubber
+	jobs     interfaces.JobService
+	metrics  interfaces.MetricsService
+	events   interfaces.EventPublisher
+	interval time.Duration
+	rate     int64 // bytes per second
+
+	mutex     sync.Mutex // guards the counters below
+	runs      int64
+	corrupted int64
+}
+
+// NewScrubService creates and returns a new scrub service instance.
+// interval is the time between scrubs, bytesPerSecond limits how fast files are read.
+func NewScrubService(scrubber interfaces.Scrubber, jobs interfaces.JobService, metrics interfaces.MetricsService,
+	events interfaces.EventPublisher, interval time.Duration, bytesPerSecond int64) *ScrubService {
+	return &ScrubService{
+		scrubber: scrubber,
+		jobs:     jobs,
+		metrics:  metrics,
+		events:   events,
+		interval: interval,
+		rate:     bytesPerSecond,
+	}
+}
+
+// Start schedules a scrub every interval, counted from the end of the last saved
+// report, until ctx is cancelled.
+func (s *ScrubService) Start(ctx context.Context) {
+	go func() {
+		var delay time.Duration
+		if last, err := s.scrubber.LastScrubReport(ctx); err == nil {
+			delay = time.Until(last.FinishedAt.Add(s.interval))
+		}
+		if delay < 0 {
+			delay = 0
+		}
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			if _, err := s.RunNow(); err != nil {
+				log.Printf("Failed to schedule scrub: %v", err)
+			}
+			timer.Reset(s.interval)
+		}
+	}()
+}
+
+// RunNow submits a scrub job, or returns the one that has not finished yet.
+func (s *ScrubService) RunNow() (interfaces.Job, error) {
+	return s.jobs.Submit(interfaces.JobRequest{
+		Type:     interfaces.JobTypeScrub,
+		Priority: interfaces.JobPriorityLow,
+		Run:      s.run,
+	})
+}
+
+// Report returns the most recent scrub report.
+func (s *ScrubService) Report(ctx context.Context) (interfaces.ScrubReport, error) {
+	return s.scrubber.LastScrubReport(ctx)
+}
+
+// run performs one scrub and reports what it found.
+func (s *ScrubService) run(ctx context.Context, progress func(float64)) error {
+	report, err := s.scrubber.Scrub(ctx, s.rate, progress)
+
+	for _, issue := range report.Issues {
+		log.Printf("Scrub found %s file %s", issue.Kind, issue.Path)
+		event := interfaces.Event{
+			Type:   interfaces.EventFileCorrupted,
+			Path:   issue.Path,
+			Action: issue.Kind,
+			Error:  issue.Error,
+		}
+		if issue.Algorithm == hashing.MD5 {
+			event.MD5 = issue.Actual
+		}
+		publishEvent(s.events, event)
+	}
+
+	s.mutex.Lock()
+	s.runs++
+	s.corrupted += int64(len(report.Issues))
+	s.recordMetrics(report)
+	s.mutex.Unlock()
+	return err
+}
+
+// recordMetrics publishes the last report and running totals. Must be called with s.mutex held.
+func (s *ScrubService) recordMetrics(last interfaces.ScrubReport) {
+	counts := map[string]int{
+		interfaces.ScrubMismatch:   0,
+		interfaces.ScrubMissing:    0,
+		interfaces.ScrubUnreadable: 0,
+	}
+	for _, issue := range last.Issues {
+		counts[issue.Kind]++
+	}
+	s.metrics.RecordMetric(scrubMetricKey, map[string]interface{}{
+		"interval":         s.interval.String(),
+		"bytes_per_second": s.rate,
+		"runs":             s.runs,
+		"issues_total":     s.corrupted,
+		"last_run":         last.FinishedAt.Format(time.RFC3339),
+		"last_completed":   last.Completed,
+		"last_error":       last.Error,
+		"files_checked":    last.FilesChecked,
+		"bytes_checked":    last.BytesChecked,
+		"mismatches":       counts[interfaces.ScrubMismatch],
+		"missing":          counts[interfaces.ScrubMissing],
+		"unreadable":       counts[interfaces.ScrubUnreadable],
+	})
+}
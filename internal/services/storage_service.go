@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lfs/internal/interfaces"
+)
+
+// QuotaLimits are the storage limits checked before uploads are accepted.
+type QuotaLimits struct {
+	Total       int64            // Maximum bytes stored in total, including internal data; 0 for no limit
+	Directories map[string]int64 // Maximum bytes per top-level directory
+	MinFree     int64            // Free space the filesystem must keep after an upload; 0 for no limit
+}
+
+// StorageService reports storage-wide statistics and checks uploads against the
+// quotas and the minimum free space.
+type StorageService struct {
+	dedup    interfaces.Deduplicator
+	scanner  interfaces.UsageScanner
+	uploads  interfaces.UploadStore
+	tus      interfaces.TusStore
+	limits   QuotaLimits
+	interval time.Duration
+
+	mutex    sync.Mutex               // guards usage and reserved
+	usage    *interfaces.StorageUsage // latest scan plus the uploads recorded since, nil before the first scan
+	reserved map[string]*reservation  // cleaned target path -> space held by the uploads in progress
+}
+
+// reservation is the space held for the uploads in progress to one path.
+type reservation struct {
+	size int64 // largest size requested by the uploads
+	refs int   // number of uploads holding the reservation
+}
+
+// NewStorageService creates and returns a new storage service instance.
+// dedup is nil when the storage backend does not deduplicate. scanner measures
+// directory usage every interval; uploads completed in between are recorded as they
+// finish. Open upload sessions and tus uploads, listed from uploads and tus, count
+// against the quotas with their declared size until they complete.
+func NewStorageService(dedup interfaces.Deduplicator, scanner interfaces.UsageScanner, uploads interfaces.UploadStore,
+	tus interfaces.TusStore, limits QuotaLimits, interval time.Duration) *StorageService {
+	return &StorageService{
+		dedup:    dedup,
+		scanner:  scanner,
+		uploads:  uploads,
+		tus:      tus,
+		limits:   limits,
+		interval: interval,
+		reserved: make(map[string]*reservation),
+	}
+}
+
+// DedupStats returns logical versus physical bytes of the deduplicating backend.
+func (s *StorageService) DedupStats(ctx context.Context) (interfaces.DedupStats, error) {
+	if s.dedup == nil {
+		return interfaces.DedupStats{}, interfaces.ErrDedupDisabled
+	}
+	return s.dedup.DedupStats(ctx)
+}
+
+// Start scans usage once immediately and then every interval until ctx is cancelled.
+// Uploads recorded in between are added to the latest scan, so that quotas see
+// them before the next scan.
+func (s *StorageService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.scan(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to scan storage usage: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// scan measures the storage and replaces the cached usage.
+func (s *StorageService) scan(ctx context.Context) (interfaces.StorageUsage, error) {
+	usage, err := s.scanner.ScanUsage(ctx)
+	if err != nil {
+		return usage, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.usage = &usage
+	return copyUsage(usage), nil
+}
+
+// current returns the cached usage, scanning the storage if it has not been scanned yet.
+func (s *StorageService) current(ctx context.Context) (interfaces.StorageUsage, error) {
+	s.mutex.Lock()
+	if s.usage != nil {
+		defer s.mutex.Unlock()
+		return copyUsage(*s.usage), nil
+	}
+	s.mutex.Unlock()
+	return s.scan(ctx)
+}
+
+// copyUsage returns a copy of usage that does not share its directory list.
+func copyUsage(usage interfaces.StorageUsage) interfaces.StorageUsage {
+	dirs := make([]interfaces.DirectoryUsage, len(usage.Directories))
+	copy(dirs, usage.Directories)
+	usage.Directories = dirs
+	return usage
+}
+
+// RecordUpload adds size bytes written to filePath to the cached usage until the next scan.
+func (s *StorageService) RecordUpload(filePath string, size int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.recordLocked(filePath, size)
+}
+
+// recordLocked adds a completed upload to the cached usage. Overwritten files are
+// counted twice until the next scan, which errs on the side of rejecting uploads.
+// The caller must hold the mutex.
+func (s *StorageService) recordLocked(filePath string, size int64) {
+	if s.usage == nil || size <= 0 {
+		return
+	}
+
+	s.usage.UsedBytes += size
+	dir := topLevelDir(filePath)
+	if dir == "" {
+		s.usage.RootFilesBytes += size
+		return
+	}
+	for i := range s.usage.Directories {
+		if s.usage.Directories[i].Path == dir {
+			s.usage.Directories[i].UsedBytes += size
+			return
+		}
+	}
+	s.usage.Directories = append(s.usage.Directories, interfaces.DirectoryUsage{Path: dir, UsedBytes: size})
+	sort.Slice(s.usage.Directories, func(i, j int) bool {
+		return s.usage.Directories[i].Path < s.usage.Directories[j].Path
+	})
+}
+
+// pendingLocked returns the bytes held by accepted uploads that have not finished, in
+// total and by top-level directory ("" for the root): the reservations of uploads in
+// progress and the declared size of open upload sessions and tus uploads. Data those
+// uploads have already stored is counted again by the scans, which errs on the side of
+// rejecting uploads. The caller must hold the mutex.
+func (s *StorageService) pendingLocked() (int64, map[string]int64) {
+	var total int64
+	dirs := make(map[string]int64)
+	add := func(filePath string, size int64) {
+		total += size
+		dirs[topLevelDir(filePath)] += size
+	}
+
+	for key, r := range s.reserved {
+		add(key, r.size)
+	}
+	if s.uploads != nil {
+		for _, session := range s.uploads.List() {
+			add(session.Path, session.Size)
+		}
+	}
+	if s.tus != nil {
+		now := time.Now()
+		for _, upload := range s.tus.List() {
+			if !upload.Completed && now.Before(upload.ExpiresAt) {
+				add(upload.Path, upload.Length)
+			}
+		}
+	}
+	return total, dirs
+}
+
+// topLevelDir returns the top-level directory containing filePath, or "" for files in the root.
+func topLevelDir(filePath string) string {
+	p := strings.TrimPrefix(path.Clean("/"+filePath), "/")
+	if i := strings.Index(p, "/"); i > 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// CheckUpload rejects writing size bytes to filePath when the filesystem would
+// drop below the minimum free space or a quota would be exceeded. Free space is
+// queried on every call; quotas are checked against the cached usage. Uploads that
+// have been accepted but not finished count as used in both checks.
+func (s *StorageService) CheckUpload(ctx context.Context, filePath string, size int64) error {
+	_, err := s.reserve(ctx, filePath, size, false)
+	return err
+}
+
+// ReserveUpload checks an upload like CheckUpload and holds size bytes for it until
+// release is called, so that concurrent uploads are checked against each other.
+// release records the bytes actually written until the next scan.
+func (s *StorageService) ReserveUpload(ctx context.Context, filePath string, size int64) (func(written int64), error) {
+	return s.reserve(ctx, filePath, size, true)
+}
+
+// reserve checks an upload of size bytes to filePath and, when hold is set, reserves
+// the space. Uploads to the same path share one reservation of the largest size.
+func (s *StorageService) reserve(ctx context.Context, filePath string, size int64, hold bool) (func(written int64), error) {
+	size = max(size, 0)
+	key := strings.TrimPrefix(path.Clean("/"+filePath), "/")
+	dir := topLevelDir(filePath)
+	dirQuota := s.limits.Directories[dir]
+	quotas := s.limits.Total > 0 || (dir != "" && dirQuota > 0)
+
+	// The first scan and the free space query run without holding the mutex
+	if quotas {
+		if _, err := s.current(ctx); err != nil {
+			return nil, err
+		}
+	}
+	available, spaceErr := int64(0), error(nil)
+	if s.limits.MinFree > 0 {
+		_, available, spaceErr = s.scanner.DiskSpace()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Only growth beyond an existing reservation for the same path needs space
+	need := size
+	r := s.reserved[key]
+	if r != nil {
+		need = max(size-r.size, 0)
+	}
+	pending, pendingDirs := s.pendingLocked()
+
+	// Platforms without a free space query are not checked
+	if s.limits.MinFree > 0 && spaceErr == nil && available-pending-need < s.limits.MinFree {
+		return nil, fmt.Errorf("%d bytes requested, %d bytes available, %d bytes reserved and %d bytes must stay free: %w",
+			size, available, pending, s.limits.MinFree, interfaces.ErrInsufficientSpace)
+	}
+	if quotas {
+		usage := s.usage
+		if s.limits.Total > 0 && usage.UsedBytes+pending+need > s.limits.Total {
+			return nil, fmt.Errorf("%d of %d bytes used, %d bytes reserved, %d bytes requested: %w",
+				usage.UsedBytes, s.limits.Total, pending, size, interfaces.ErrQuotaExceeded)
+		}
+		if dir != "" && dirQuota > 0 {
+			var used int64
+			for _, d := range usage.Directories {
+				if d.Path == dir {
+					used = d.UsedBytes
+				}
+			}
+			if used+pendingDirs[dir]+need > dirQuota {
+				return nil, fmt.Errorf("%s: %d of %d bytes used, %d bytes reserved, %d bytes requested: %w",
+					dir, used, dirQuota, pendingDirs[dir], size, interfaces.ErrQuotaExceeded)
+			}
+		}
+	}
+	if !hold {
+		return nil, nil
+	}
+
+	if r == nil {
+		r = &reservation{}
+		s.reserved[key] = r
+	}
+	r.size = max(r.size, size)
+	r.refs++
+
+	var once sync.Once
+	return func(written int64) {
+		once.Do(func() {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			if r.refs--; r.refs == 0 {
+				delete(s.reserved, key)
+			}
+			s.recordLocked(key, written)
+		})
+	}, nil
+}
+
+// Usage returns the cached directory usage together with the current free space
+// and the bytes that can still be written overall and to each top-level directory.
+func (s *StorageService) Usage(ctx context.Context) (interfaces.StorageUsage, error) {
+	usage, err := s.current(ctx)
+	if err != nil {
+		return usage, err
+	}
+	total, available, err := s.scanner.DiskSpace()
+	if err != nil {
+		return usage, err
+	}
+	usage.DiskTotalBytes = total
+	usage.DiskFreeBytes = available
+	usage.MinFreeBytes = s.limits.MinFree
+	usage.QuotaBytes = s.limits.Total
+
+	s.mutex.Lock()
+	pending, pendingDirs := s.pendingLocked()
+	s.mutex.Unlock()
+	usage.ReservedBytes = pending
+
+	usage.FreeBytes = available - s.limits.MinFree - pending
+	if s.limits.Total > 0 {
+		usage.FreeBytes = min(usage.FreeBytes, s.limits.Total-usage.UsedBytes-pending)
+	}
+	usage.FreeBytes = max(usage.FreeBytes, 0)
+
+	// Directories with a quota or a pending upload are listed even before anything is stored in them
+	listDir := func(dir string) {
+		for _, d := range usage.Directories {
+			if d.Path == dir {
+				return
+			}
+		}
+		usage.Directories = append(usage.Directories, interfaces.DirectoryUsage{Path: dir})
+	}
+	for dir := range s.limits.Directories {
+		listDir(dir)
+	}
+	for dir := range pendingDirs {
+		if dir != "" {
+			listDir(dir)
+		}
+	}
+	sort.Slice(usage.Directories, func(i, j int) bool {
+		return usage.Directories[i].Path < usage.Directories[j].Path
+	})
+
+	for i := range usage.Directories {
+		d := &usage.Directories[i]
+		d.ReservedBytes = pendingDirs[d.Path]
+		d.FreeBytes = usage.FreeBytes
+		if quota := s.limits.Directories[d.Path]; quota > 0 {
+			d.QuotaBytes = quota
+			d.FreeBytes = max(min(d.FreeBytes, quota-d.UsedBytes-d.ReservedBytes), 0)
+		}
+	}
+	return usage, nil
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"lfs/internal/interfaces"
+)
+
+// trashMetricKey is the MetricsService key the trash reports under.
+const trashMetricKey = "trash"
+
+// TrashService manages deleted files: listing, restoring and purging trash items,
+// and emptying items older than maxAge in the background.
+type TrashService struct {
+	trash    interfaces.Trasher
+	locks    interfaces.LockService
+	metrics  interfaces.MetricsService
+	interval time.Duration
+	maxAge   time.Duration
+
+	mutex  sync.Mutex // guards the totals below
+	runs   int64
+	totals interfaces.CleanupStats
+}
+
+// NewTrashService creates and returns a new trash service instance.
+// interval is the time between purges, maxAge is how long deleted items are kept.
+func NewTrashService(trash interfaces.Trasher, locks interfaces.LockService, metrics interfaces.MetricsService,
+	interval, maxAge time.Duration) *TrashService {
+	return &TrashService{
+		trash:    trash,
+		locks:    locks,
+		metrics:  metrics,
+		interval: interval,
+		maxAge:   maxAge,
+	}
+}
+
+// Start empties expired items once immediately and then every interval until ctx is cancelled.
+func (s *TrashService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.purgeExpired(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to empty trash: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// purgeExpired deletes items older than maxAge and records the result.
+func (s *TrashService) purgeExpired(ctx context.Context) error {
+	stats, err := s.trash.EmptyTrash(ctx, s.maxAge)
+	if stats.TrashItems > 0 {
+		log.Printf("Emptied %d trash items (%d bytes)", stats.TrashItems, stats.FreedBytes)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.runs++
+	s.totals.Add(stats)
+	s.metrics.RecordMetric(trashMetricKey, map[string]interface{}{
+		"max_age":     s.maxAge.String(),
+		"runs":        s.runs,
+		"purged":      s.totals.TrashItems,
+		"freed_bytes": s.totals.FreedBytes,
+	})
+	return err
+}
+
+// List returns the items in the trash, most recently deleted first.
+func (s *TrashService) List(ctx context.Context) ([]interfaces.TrashItem, error) {
+	return s.trash.ListTrash(ctx)
+}
+
+// Restore moves an item back to where it was deleted from, unless that path is
+// locked by another user. A taken path is resolved by restoring under a numbered name.
+func (s *TrashService) Restore(ctx context.Context, id string) (interfaces.TrashItem, error) {
+	item, err := s.trash.GetTrashItem(ctx, id)
+	if err != nil {
+		return item, err
+	}
+	if err := s.locks.CheckWriteAccess(ctx, item.Path); err != nil {
+		return item, err
+	}
+	return s.trash.RestoreTrash(ctx, id)
+}
+
+// Purge permanently deletes an item.
+func (s *TrashService) Purge(ctx context.Context, id string) error {
+	return s.trash.PurgeTrash(ctx, id)
+}
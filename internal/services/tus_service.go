@@ -0,0 +1,328 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"lfs/internal/interfaces"
+)
+
+// tusDataDir is the tus directory relative to the internal storage root.
+const tusDataDir = "tus"
+
+// tusUploadExpiry is how long an upload stays resumable after its last write.
+const tusUploadExpiry = 24 * time.Hour
+
+// TusService implements the tus 1.0 resumable upload protocol.
+// Data is appended to a file in the internal storage and copied into the file
+// storage once Upload-Length bytes have been received.
+type TusService struct {
+	store   interfaces.TusStore
+	files   interfaces.Storage
+	chunks  interfaces.Storage
+	locks   interfaces.LockService
+	quota   interfaces.QuotaChecker
+	events  interfaces.EventPublisher
+	writing sync.Map // upload ID -> struct{}
+}
+
+// NewTusService creates and returns a new tus service instance.
+// files is the user-visible storage, chunks is rooted at the internal data directory,
+// locks rejects uploads to paths locked by other users, quota rejects uploads whose
+// declared size would exceed a quota or the minimum free space and records the completed
+// files, events receives upload progress.
+func NewTusService(store interfaces.TusStore, files, chunks interfaces.Storage, locks interfaces.LockService,
+	quota interfaces.QuotaChecker, events interfaces.EventPublisher) *TusService {
+	return &TusService{
+		store:  store,
+		files:  files,
+		chunks: chunks,
+		locks:  locks,
+		quota:  quota,
+		events: events,
+	}
+}
+
+// Create creates an upload whose target path is taken from the filename (or name)
+// and optional dir metadata; uploads without a name are stored under their ID.
+func (s *TusService) Create(ctx context.Context, length int64, metadata string) (interfaces.TusUpload, error) {
+	if length < 0 {
+		return interfaces.TusUpload{}, interfaces.ErrTusInvalidMetadata
+	}
+	values, err := parseTusMetadata(metadata)
+	if err != nil {
+		return interfaces.TusUpload{}, err
+	}
+
+	id, err := newRandomID()
+	if err != nil {
+		return interfaces.TusUpload{}, err
+	}
+
+	name := values["filename"]
+	if name == "" {
+		name = values["name"]
+	}
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		name = id
+	}
+	target := strings.TrimPrefix(path.Join("/", values["dir"], name), "/")
+
+	// The target must be a valid path that is not a directory
+	if info, err := s.files.StatFile(ctx, target); err == nil && info.IsDir {
+		return interfaces.TusUpload{}, interfaces.ErrInvalidPath
+	} else if errors.Is(err, interfaces.ErrInvalidPath) {
+		return interfaces.TusUpload{}, err
+	}
+	if err := s.locks.CheckWriteAccess(ctx, target); err != nil {
+		return interfaces.TusUpload{}, err
+	}
+	if err := s.quota.CheckUpload(ctx, target, length); err != nil {
+		return interfaces.TusUpload{}, err
+	}
+
+	if err := s.chunks.WriteFile(ctx, tusDataPath(id), strings.NewReader("")); err != nil {
+		return interfaces.TusUpload{}, err
+	}
+
+	now := time.Now().UTC()
+	upload := interfaces.TusUpload{
+		ID:        id,
+		Length:    length,
+		Metadata:  metadata,
+		Values:    values,
+		Path:      target,
+		Owner:     interfaces.OwnerFromContext(ctx),
+		CreatedAt: now,
+		ExpiresAt: now.Add(tusUploadExpiry),
+	}
+	if err := s.store.Create(upload); err != nil {
+		return interfaces.TusUpload{}, err
+	}
+
+	// Empty uploads are complete as soon as they are created
+	if length == 0 {
+		if err := s.finish(ctx, &upload); err != nil {
+			return upload, err
+		}
+		if err := s.store.Update(upload); err != nil {
+			return upload, err
+		}
+	}
+	return upload, nil
+}
+
+// Get returns the current state of an upload.
+func (s *TusService) Get(ctx context.Context, id string) (interfaces.TusUpload, error) {
+	return s.getUpload(id)
+}
+
+// Write appends data at offset. Bytes received before an interruption are kept
+// unless a checksum was requested, in which case the whole request is discarded.
+func (s *TusService) Write(ctx context.Context, id string, offset int64, data io.Reader, checksum *interfaces.TusChecksum) (interfaces.TusUpload, error) {
+	if _, busy := s.writing.LoadOrStore(id, struct{}{}); busy {
+		return interfaces.TusUpload{}, interfaces.ErrTusUploadLocked
+	}
+	defer s.writing.Delete(id)
+
+	upload, err := s.getUpload(id)
+	if err != nil {
+		return interfaces.TusUpload{}, err
+	}
+	if offset != upload.Offset || upload.Completed {
+		return upload, interfaces.ErrTusOffsetMismatch
+	}
+
+	var h hash.Hash
+	if checksum != nil {
+		if h, err = newTusHash(checksum.Algorithm); err != nil {
+			return upload, err
+		}
+	}
+
+	body := &tusBodyReader{r: data, remaining: upload.Length - upload.Offset, hash: h}
+	writeErr := s.chunks.WriteFileRange(ctx, tusDataPath(id), offset, body)
+	if writeErr != nil {
+		// Keep what arrived before the client went away, as long as it needs no verification
+		interrupted := ctx.Err() != nil || (body.readErr != nil && errors.Is(writeErr, body.readErr))
+		if checksum != nil || !interrupted || errors.Is(writeErr, interfaces.ErrTusSizeExceeded) {
+			return upload, writeErr
+		}
+	}
+	if checksum != nil && !bytes.Equal(h.Sum(nil), checksum.Sum) {
+		return upload, interfaces.ErrTusChecksumMismatch
+	}
+
+	upload.Offset += body.n
+	upload.ExpiresAt = time.Now().UTC().Add(tusUploadExpiry)
+	if upload.Length > 0 {
+		publishEvent(s.events, interfaces.Event{
+			Type:     interfaces.EventUploadProgress,
+			Path:     upload.Path,
+			UploadID: upload.ID,
+			Progress: float64(upload.Offset) / float64(upload.Length),
+			Bytes:    upload.Offset,
+			Total:    upload.Length,
+		})
+	}
+	if writeErr == nil && upload.Offset == upload.Length {
+		if err := s.finish(ctx, &upload); err != nil {
+			s.store.Update(upload)
+			return upload, err
+		}
+	}
+	if err := s.store.Update(upload); err != nil {
+		return upload, err
+	}
+	return upload, writeErr
+}
+
+// Terminate deletes an upload and its data.
+func (s *TusService) Terminate(ctx context.Context, id string) error {
+	if _, busy := s.writing.LoadOrStore(id, struct{}{}); busy {
+		return interfaces.ErrTusUploadLocked
+	}
+	defer s.writing.Delete(id)
+
+	if _, err := s.getUpload(id); err != nil {
+		return err
+	}
+	return s.store.Delete(id)
+}
+
+// finish copies a fully received upload to its target path and drops its data.
+// The upload record is kept until it expires so that clients resuming after a
+// lost response still see the final offset.
+func (s *TusService) finish(ctx context.Context, upload *interfaces.TusUpload) error {
+	ctx = interfaces.WithOwner(ctx, upload.Owner)
+	if err := s.locks.CheckWriteAccess(ctx, upload.Path); err != nil {
+		return err
+	}
+
+	data, err := s.chunks.ReadFile(ctx, tusDataPath(upload.ID))
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	if err := s.files.WriteFile(ctx, upload.Path, io.LimitReader(data, upload.Length)); err != nil {
+		return err
+	}
+	s.quota.RecordUpload(upload.Path, upload.Length)
+
+	upload.Completed = true
+	publishEvent(s.events, interfaces.Event{
+		Type:     interfaces.EventUploadComplete,
+		Path:     upload.Path,
+		UploadID: upload.ID,
+		Progress: 1,
+		Bytes:    upload.Length,
+		Total:    upload.Length,
+	})
+	return s.chunks.DeleteFile(ctx, tusDataPath(upload.ID))
+}
+
+// getUpload looks up an upload, removing it if it has expired.
+func (s *TusService) getUpload(id string) (interfaces.TusUpload, error) {
+	if !uploadIDPattern.MatchString(id) {
+		return interfaces.TusUpload{}, interfaces.ErrTusUploadNotFound
+	}
+
+	upload, err := s.store.Get(id)
+	if err != nil {
+		return interfaces.TusUpload{}, err
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		s.store.Delete(id)
+		return interfaces.TusUpload{}, interfaces.ErrTusUploadNotFound
+	}
+	return upload, nil
+}
+
+// tusDataPath returns the internal storage path of an upload's data.
+func tusDataPath(id string) string {
+	return path.Join(tusDataDir, id+".bin")
+}
+
+// parseTusMetadata decodes an Upload-Metadata header: comma-separated pairs of
+// a key and an optional base64-encoded value.
+func parseTusMetadata(header string) (map[string]string, error) {
+	values := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return values, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, interfaces.ErrTusInvalidMetadata
+		}
+
+		value := ""
+		if len(parts) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, interfaces.ErrTusInvalidMetadata
+			}
+			value = string(decoded)
+		}
+		values[parts[0]] = value
+	}
+	return values, nil
+}
+
+// newTusHash returns a hash for a checksum algorithm listed in interfaces.TusChecksumAlgorithms.
+func newTusHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	default:
+		return nil, interfaces.ErrTusChecksumAlgorithm
+	}
+}
+
+// tusBodyReader counts and optionally hashes a request body, failing once more
+// bytes arrive than the upload has left. Read errors are recorded so callers can
+// tell an interrupted client from a storage failure.
+type tusBodyReader struct {
+	r         io.Reader
+	remaining int64
+	hash      hash.Hash // nil if no checksum was requested
+	n         int64
+	readErr   error
+}
+
+// Read implements io.Reader.
+func (t *tusBodyReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if int64(n) > t.remaining-t.n {
+		n = int(t.remaining - t.n)
+		err = interfaces.ErrTusSizeExceeded
+	}
+	if n > 0 {
+		if t.hash != nil {
+			t.hash.Write(p[:n])
+		}
+		t.n += int64(n)
+	}
+	if err != nil && err != io.EOF {
+		t.readErr = err
+	}
+	return n, err
+}
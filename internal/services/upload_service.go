@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"lfs/internal/interfaces"
+	"lfs/pkg/hashing"
+)
+
+// uploadChunksDir is the session directory relative to the internal storage root.
+const uploadChunksDir = "uploads"
+
+// Upload session limits.
+const (
+	defaultUploadChunkSize = 5 * 1024 * 1024
+	maxUploadChunkSize     = 512 * 1024 * 1024
+	maxUploadChunks        = 100000
+)
+
+// uploadIDPattern matches the IDs generated by newRandomID.
+var uploadIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// UploadService implements server-side upload sessions for chunked uploads.
+// Chunks are written to the internal storage and merged into the file storage
+// only once every chunk has arrived, in whatever order they were sent.
+type UploadService struct {
+	store      interfaces.UploadStore
+	files      interfaces.Storage
+	chunks     interfaces.Storage
+	locks      interfaces.LockService
+	quota      interfaces.QuotaChecker
+	events     interfaces.EventPublisher
+	completing sync.Map // session ID -> struct{}
+}
+
+// NewUploadService creates and returns a new upload service instance.
+// files is the user-visible storage, chunks is rooted at the internal data directory,
+// locks rejects uploads to paths locked by other users, quota rejects uploads whose
+// declared size would exceed a quota or the minimum free space and records the merged
+// files, events receives upload progress.
+func NewUploadService(store interfaces.UploadStore, files, chunks interfaces.Storage, locks interfaces.LockService,
+	quota interfaces.QuotaChecker, events interfaces.EventPublisher) *UploadService {
+	return &UploadService{
+		store:  store,
+		files:  files,
+		chunks: chunks,
+		locks:  locks,
+		quota:  quota,
+		events: events,
+	}
+}
+
+// CreateSession validates the request and creates a new upload session.
+func (s *UploadService) CreateSession(ctx context.Context, req interfaces.UploadSessionRequest) (interfaces.UploadStatus, error) {
+	if req.Size < 0 || req.ChunkSize < 0 || req.ChunkSize > maxUploadChunkSize {
+		return interfaces.UploadStatus{}, interfaces.ErrUploadInvalid
+	}
+	if req.ChunkSize == 0 {
+		req.ChunkSize = defaultUploadChunkSize
+	}
+
+	algorithm := strings.ToLower(req.HashAlgorithm)
+	if algorithm == "" {
+		algorithm = "md5"
+	}
+	if _, err := newUploadHash(algorithm); err != nil {
+		return interfaces.UploadStatus{}, err
+	}
+
+	totalChunks := (req.Size + req.ChunkSize - 1) / req.ChunkSize
+	if totalChunks > maxUploadChunks {
+		return interfaces.UploadStatus{}, interfaces.ErrUploadInvalid
+	}
+
+	// The target must be a valid path that is not a directory
+	if info, err := s.files.StatFile(ctx, req.Path); err == nil && info.IsDir {
+		return interfaces.UploadStatus{}, interfaces.ErrInvalidPath
+	} else if errors.Is(err, interfaces.ErrInvalidPath) {
+		return interfaces.UploadStatus{}, err
+	}
+	if err := s.locks.CheckWriteAccess(ctx, req.Path); err != nil {
+		return interfaces.UploadStatus{}, err
+	}
+	if err := s.quota.CheckUpload(ctx, req.Path, req.Size); err != nil {
+		return interfaces.UploadStatus{}, err
+	}
+
+	id, err := newRandomID()
+	if err != nil {
+		return interfaces.UploadStatus{}, err
+	}
+
+	now := time.Now().UTC()
+	session := interfaces.UploadSession{
+		ID:            id,
+		Path:          strings.TrimPrefix(path.Clean("/"+req.Path), "/"),
+		Size:          req.Size,
+		ChunkSize:     req.ChunkSize,
+		TotalChunks:   int(totalChunks),
+		HashAlgorithm: algorithm,
+		Hash:          strings.ToLower(req.Hash),
+		Received:      strings.Repeat("0", int(totalChunks)),
+		Owner:         interfaces.OwnerFromContext(ctx),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.store.Create(session); err != nil {
+		return interfaces.UploadStatus{}, err
+	}
+	return uploadStatus(session), nil
+}
+
+// GetSession returns the session with its received chunk bitmap and contiguous offset.
+func (s *UploadService) GetSession(ctx context.Context, id string) (interfaces.UploadStatus, error) {
+	session, err := s.getSession(id)
+	if err != nil {
+		return interfaces.UploadStatus{}, err
+	}
+	return uploadStatus(session), nil
+}
+
+// PutChunk stores one chunk, replacing any earlier copy of the same chunk.
+func (s *UploadService) PutChunk(ctx context.Context, id string, index int, data io.Reader) (interfaces.UploadStatus, error) {
+	session, err := s.getSession(id)
+	if err != nil {
+		return interfaces.UploadStatus{}, err
+	}
+	if index < 0 || index >= session.TotalChunks {
+		return interfaces.UploadStatus{}, interfaces.ErrChunkOutOfRange
+	}
+
+	// Every chunk except the last one must be exactly ChunkSize bytes
+	size := session.ChunkSize
+	if remaining := session.Size - int64(index)*session.ChunkSize; remaining < size {
+		size = remaining
+	}
+
+	err = s.chunks.WriteFile(ctx, uploadChunkPath(id, index), &verifyingReader{
+		r:       data,
+		hash:    md5.New(),
+		size:    size,
+		sizeErr: interfaces.ErrChunkSizeMismatch,
+	})
+	if err != nil {
+		return interfaces.UploadStatus{}, err
+	}
+
+	session, err = s.store.MarkChunk(id, index)
+	if err != nil {
+		return interfaces.UploadStatus{}, err
+	}
+
+	status := uploadStatus(session)
+	received := int64(status.ReceivedChunks) * session.ChunkSize
+	if received > session.Size {
+		received = session.Size
+	}
+	publishEvent(s.events, interfaces.Event{
+		Type:     interfaces.EventUploadProgress,
+		Path:     session.Path,
+		UploadID: id,
+		Progress: float64(status.ReceivedChunks) / float64(session.TotalChunks),
+		Bytes:    received,
+		Total:    session.Size,
+	})
+	return status, nil
+}
+
+// Complete merges all chunks into the target file, verifies the expected hash and
+// removes the session. The target is replaced atomically, so a failed merge leaves
+// any existing file untouched and the session can be retried.
+func (s *UploadService) Complete(ctx context.Context, id string) (interfaces.FileMetadata, error) {
+	session, err := s.getSession(id)
+	if err != nil {
+		return interfaces.FileMetadata{}, err
+	}
+	if strings.Contains(session.Received, "0") {
+		return interfaces.FileMetadata{}, interfaces.ErrUploadIncomplete
+	}
+	if err := s.locks.CheckWriteAccess(ctx, session.Path); err != nil {
+		return interfaces.FileMetadata{}, err
+	}
+
+	if _, busy := s.completing.LoadOrStore(id, struct{}{}); busy {
+		return interfaces.FileMetadata{}, interfaces.ErrUploadBusy
+	}
+	defer s.completing.Delete(id)
+
+	h, err := newUploadHash(session.HashAlgorithm)
+	if err != nil {
+		return interfaces.FileMetadata{}, err
+	}
+
+	// Stream the chunks in order into the target without holding them all open
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < session.TotalChunks; i++ {
+			rc, err := s.chunks.ReadFile(ctx, uploadChunkPath(id, i))
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			_, err = io.Copy(pw, rc)
+			rc.Close()
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	err = s.files.WriteFile(ctx, session.Path, &verifyingReader{
+		r:        pr,
+		hash:     h,
+		expected: session.Hash,
+		size:     session.Size,
+		sizeErr:  interfaces.ErrChunkSizeMismatch,
+		hashErr:  interfaces.ErrUploadHashMismatch,
+	})
+	pr.CloseWithError(err)
+	if err != nil {
+		return interfaces.FileMetadata{}, err
+	}
+	s.quota.RecordUpload(session.Path, session.Size)
+
+	if err := s.store.Delete(id); err != nil {
+		return interfaces.FileMetadata{}, err
+	}
+	publishEvent(s.events, interfaces.Event{
+		Type:     interfaces.EventUploadComplete,
+		Path:     session.Path,
+		UploadID: id,
+		Progress: 1,
+		Bytes:    session.Size,
+		Total:    session.Size,
+	})
+	return s.files.StatFile(ctx, session.Path)
+}
+
+// Abort cancels a session and deletes its chunks.
+func (s *UploadService) Abort(ctx context.Context, id string) error {
+	if _, err := s.getSession(id); err != nil {
+		return err
+	}
+	if _, busy := s.completing.Load(id); busy {
+		return interfaces.ErrUploadBusy
+	}
+	return s.store.Delete(id)
+}
+
+// getSession looks up a session, rejecting malformed IDs before they reach the store.
+func (s *UploadService) getSession(id string) (interfaces.UploadSession, error) {
+	if !uploadIDPattern.MatchString(id) {
+		return interfaces.UploadSession{}, interfaces.ErrUploadNotFound
+	}
+	return s.store.Get(id)
+}
+
+// uploadStatus computes the progress of a session.
+func uploadStatus(session interfaces.UploadSession) interfaces.UploadStatus {
+	status := interfaces.UploadStatus{
+		UploadSession:  session,
+		ReceivedChunks: strings.Count(session.Received, "1"),
+	}
+
+	contiguous := strings.Index(session.Received, "0")
+	if contiguous < 0 {
+		status.Offset = session.Size
+	} else {
+		status.Offset = int64(contiguous) * session.ChunkSize
+	}
+	return status
+}
+
+// uploadChunkPath returns the internal storage path of a chunk.
+func uploadChunkPath(id string, index int) string {
+	return path.Join(uploadChunksDir, id, fmt.Sprintf("%d.chunk", index))
+}
+
+// newUploadHash returns a hash for a supported algorithm name.
+func newUploadHash(algorithm string) (hash.Hash, error) {
+	h, err := hashing.New(algorithm)
+	if err != nil {
+		return nil, interfaces.ErrUploadInvalid
+	}
+	return h, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+
+	"lfs/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VersionService exposes the version history kept by a versioned storage.
+type VersionService struct {
+	versions interfaces.Versioner
+	locks    interfaces.LockService
+}
+
+// NewVersionService creates and returns a new version service instance.
+// versions is nil when versioning is disabled.
+func NewVersionService(versions interfaces.Versioner, locks interfaces.LockService) *VersionService {
+	return &VersionService{
+		versions: versions,
+		locks:    locks,
+	}
+}
+
+// ListVersions returns the versions of a file, newest first.
+func (s *VersionService) ListVersions(ctx context.Context, filePath string) ([]interfaces.FileVersion, error) {
+	if s.versions == nil {
+		return nil, interfaces.ErrVersioningDisabled
+	}
+	return s.versions.ListVersions(ctx, filePath)
+}
+
+// DownloadVersion sends a version of a file.
+func (s *VersionService) DownloadVersion(ctx context.Context, c *gin.Context, filePath string, version int, rangeHeader string) error {
+	if s.versions == nil {
+		return interfaces.ErrVersioningDisabled
+	}
+	return s.versions.DownloadVersion(ctx, c, filePath, version, rangeHeader)
+}
+
+// RestoreVersion replaces a file with one of its versions, honouring locks like any other write.
+func (s *VersionService) RestoreVersion(ctx context.Context, filePath string, version int) error {
+	if s.versions == nil {
+		return interfaces.ErrVersioningDisabled
+	}
+	if err := s.locks.CheckWriteAccess(ctx, filePath); err != nil {
+		return err
+	}
+	return s.versions.RestoreVersion(ctx, filePath, version)
+}
@@ -2,11 +2,16 @@ package storage
 
 import (
 	"context"
+	"io"
 	"mime/multipart"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"lfs/internal/interfaces"
+	"lfs/pkg/hashing"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,34 +21,140 @@ import (
 type StorageAdapter struct {
 	storagePath string
 	md5Cache    interfaces.MD5Cache
+	hashOnWrite bool
+	useTrash    bool
+	events      interfaces.EventPublisher
 }
 
 // NewStorageAdapter creates and returns a new storage adapter instance.
 // storagePath is the file storage path, md5Cache is used for MD5 value caching.
-func NewStorageAdapter(storagePath string, md5Cache interfaces.MD5Cache) *StorageAdapter {
+// When hashOnWrite is set, SaveFile and WriteFile hash data as it is written and store the
+// digests in md5Cache, so freshly written files are never read again just to list or verify them.
+// When useTrash is set, deleted files and directories are moved to the trash instead of
+// being removed. File changes and chunk upload progress are published to events, which may be nil.
+func NewStorageAdapter(storagePath string, md5Cache interfaces.MD5Cache, hashOnWrite, useTrash bool, events interfaces.EventPublisher) *StorageAdapter {
 	return &StorageAdapter{
 		storagePath: storagePath,
 		md5Cache:    md5Cache,
+		hashOnWrite: hashOnWrite,
+		useTrash:    useTrash,
+		events:      events,
 	}
 }
 
-// SaveFile saves a file with resumable transfer support.
-func (a *StorageAdapter) SaveFile(ctx context.Context, file *multipart.FileHeader, rangeHeader string) error {
-	return SaveFileWithTimeout(ctx, a.storagePath, file, rangeHeader)
+// publish sends an event when an event publisher is configured.
+func (a *StorageAdapter) publish(event interfaces.Event) {
+	if a.events != nil {
+		a.events.Publish(event)
+	}
+}
+
+// publishFileChange publishes a file.changed event for a relative path.
+func (a *StorageAdapter) publishFileChange(action, filename string, isDir bool) {
+	a.publish(interfaces.Event{
+		Type:   interfaces.EventFileChanged,
+		Path:   cleanRelPath(filename),
+		Action: action,
+		IsDir:  isDir,
+	})
+}
+
+// existsAction returns the file.changed action for writing to filename:
+// modified when it already exists, created otherwise.
+func (a *StorageAdapter) existsAction(filename string) string {
+	if a.events == nil {
+		return ""
+	}
+	if _, err := os.Stat(GetFilePath(a.storagePath, filename)); err == nil {
+		return interfaces.FileModified
+	}
+	return interfaces.FileCreated
+}
+
+// cleanRelPath normalizes a relative path: slash separated, no leading or trailing slash.
+func cleanRelPath(p string) string {
+	return strings.Trim(path.Clean("/"+filepath.ToSlash(p)), "/")
+}
+
+// SaveFile saves a file into targetDir with resumable transfer support.
+// New uploads are written to a temporary file and renamed into place, with
+// their digests computed while writing when hash-on-write is enabled; an existing
+// target is handled by conflict.
+func (a *StorageAdapter) SaveFile(ctx context.Context, targetDir string, file *multipart.FileHeader, rangeHeader string, conflict interfaces.ConflictPolicy) (interfaces.SaveResult, error) {
+	filename := path.Join(targetDir, file.Filename)
+	action := a.existsAction(filename)
+	result, err := SaveFileWithTimeout(ctx, a.storagePath, targetDir, file, rangeHeader, conflict, a.hashOnWrite)
+	if err != nil || result.Skipped {
+		return result, err
+	}
+	if result.Path != cleanRelPath(filename) {
+		action = interfaces.FileCreated
+	}
+	a.publish(interfaces.Event{
+		Type:  interfaces.EventUploadComplete,
+		Path:  result.Path,
+		Bytes: file.Size,
+		Total: file.Size,
+	})
+	a.publishFileChange(action, result.Path, false)
+	return result, nil
 }
 
-// SaveFileChunk saves a file chunk.
-func (a *StorageAdapter) SaveFileChunk(ctx context.Context, chunkInfo interfaces.FileChunkInfo, file *multipart.FileHeader) error {
+// SaveFileChunk saves a file chunk, verifying its checksum when one is given.
+// Once the last chunk has arrived the merged file replaces the target atomically
+// and its digests are cached.
+func (a *StorageAdapter) SaveFileChunk(ctx context.Context, chunkInfo interfaces.FileChunkInfo, file *multipart.FileHeader) (*interfaces.ChunkMergeReport, error) {
 	// Convert to internal type
 	internalChunkInfo := FileChunkInfo{
-		FileName:   chunkInfo.FileName,
-		TotalSize:  chunkInfo.TotalSize,
-		ChunkIndex: chunkInfo.ChunkIndex,
-		ChunkSize:  chunkInfo.ChunkSize,
-		TotalChunk: chunkInfo.TotalChunk,
-		MD5:        chunkInfo.MD5,
+		FileName:           chunkInfo.FileName,
+		TotalSize:          chunkInfo.TotalSize,
+		ChunkIndex:         chunkInfo.ChunkIndex,
+		ChunkSize:          chunkInfo.ChunkSize,
+		TotalChunk:         chunkInfo.TotalChunk,
+		MD5:                chunkInfo.MD5,
+		ChunkHashAlgorithm: chunkInfo.ChunkHashAlgorithm,
+		ChunkHash:          chunkInfo.ChunkHash,
+		Conflict:           chunkInfo.Conflict,
+	}
+	action := a.existsAction(chunkInfo.FileName)
+	report, err := SaveFileChunk(a.storagePath, internalChunkInfo, file)
+	if err != nil || a.events == nil || (report != nil && report.Skipped) {
+		return report, err
 	}
-	return SaveFileChunk(a.storagePath, internalChunkInfo, file)
+
+	if report == nil {
+		// Chunks may arrive out of order, so progress counts the chunks received so far
+		received := receivedChunks(a.storagePath, chunkInfo.FileName, chunkInfo.TotalChunk)
+		bytes := int64(received) * chunkInfo.ChunkSize
+		if bytes > chunkInfo.TotalSize {
+			bytes = chunkInfo.TotalSize
+		}
+		progress := 0.0
+		if chunkInfo.TotalChunk > 0 {
+			progress = float64(received) / float64(chunkInfo.TotalChunk)
+		}
+		a.publish(interfaces.Event{
+			Type:     interfaces.EventUploadProgress,
+			Path:     cleanRelPath(chunkInfo.FileName),
+			Progress: progress,
+			Bytes:    bytes,
+			Total:    chunkInfo.TotalSize,
+		})
+		return nil, nil
+	}
+
+	a.publish(interfaces.Event{
+		Type:     interfaces.EventUploadComplete,
+		Path:     report.Path,
+		Progress: 1,
+		Bytes:    chunkInfo.TotalSize,
+		Total:    chunkInfo.TotalSize,
+	})
+	if report.Path != cleanRelPath(chunkInfo.FileName) {
+		action = interfaces.FileCreated
+	}
+	a.publishFileChange(action, report.Path, false)
+	return report, nil
 }
 
 // DownloadFile downloads a file with resumable transfer support.
@@ -56,15 +167,30 @@ func (a *StorageAdapter) DownloadFileChunk(ctx context.Context, c *gin.Context,
 	return DownloadFileChunk(c, a.storagePath, filename, chunkIndex, chunkSize)
 }
 
-// ListFiles lists all files and directories with recursive traversal support.
-func (a *StorageAdapter) ListFiles(ctx context.Context) ([]interfaces.FileMetadata, error) {
-	files, err := ListFiles(a.storagePath)
+// WriteArchive streams files and directories as a ZIP or TAR archive.
+func (a *StorageAdapter) WriteArchive(ctx context.Context, w io.Writer, paths []string, opts interfaces.ArchiveOptions) error {
+	return WriteArchive(ctx, a.storagePath, w, paths, ArchiveOptions{
+		Format:      opts.Format,
+		Compression: opts.Compression,
+	})
+}
+
+// ListFiles lists one page of a directory, expanding subdirectories up to opts.Depth levels.
+func (a *StorageAdapter) ListFiles(ctx context.Context, opts interfaces.ListOptions) (interfaces.FileList, error) {
+	page, err := ListFiles(a.storagePath, ListOptions{
+		Path:   opts.Path,
+		Depth:  opts.Depth,
+		Limit:  opts.Limit,
+		Cursor: opts.Cursor,
+		Sort:   opts.Sort,
+		Order:  opts.Order,
+	})
 	if err != nil {
-		return nil, err
+		return interfaces.FileList{}, err
 	}
 	// Convert to interface type
-	result := make([]interfaces.FileMetadata, len(files))
-	for i, f := range files {
+	result := make([]interfaces.FileMetadata, len(page.Files))
+	for i, f := range page.Files {
 		result[i] = interfaces.FileMetadata{
 			Name:     f.Name,
 			Path:     f.Path,
@@ -75,7 +201,11 @@ func (a *StorageAdapter) ListFiles(ctx context.Context) ([]interfaces.FileMetada
 			Children: convertChildren(f.Children),
 		}
 	}
-	return result, nil
+	return interfaces.FileList{
+		Files:      result,
+		NextCursor: page.NextCursor,
+		Total:      page.Total,
+	}, nil
 }
 
 // convertChildren converts internal file metadata list to interface type.
@@ -103,43 +233,279 @@ func (a *StorageAdapter) CheckFileExists(ctx context.Context, filename string) e
 	return CheckFileExists(a.storagePath, filename)
 }
 
+// DeleteFile deletes a file, or moves it to the trash, and drops its MD5 cache entry.
+func (a *StorageAdapter) DeleteFile(ctx context.Context, filename string) error {
+	var err error
+	if a.useTrash {
+		_, err = TrashFile(a.storagePath, filename)
+	} else {
+		err = DeleteFile(a.storagePath, filename)
+	}
+	if err != nil {
+		return err
+	}
+	a.publishFileChange(interfaces.FileDeleted, filename, false)
+	return a.md5Cache.Invalidate(GetFilePath(a.storagePath, filename))
+}
+
+// RenameFile renames a file or directory in place and migrates its MD5 cache entries.
+func (a *StorageAdapter) RenameFile(ctx context.Context, filename, newName string) error {
+	if err := RenameFile(a.storagePath, filename, newName); err != nil {
+		return err
+	}
+	newPath := filepath.Join(filepath.Dir(filename), newName)
+	a.publishMove(filename, newPath)
+	return a.md5Cache.Rename(GetFilePath(a.storagePath, filename), GetFilePath(a.storagePath, newPath))
+}
+
+// MoveFile moves a file or directory and migrates its MD5 cache entries.
+func (a *StorageAdapter) MoveFile(ctx context.Context, srcPath, dstPath string) error {
+	if err := MoveFile(a.storagePath, srcPath, dstPath); err != nil {
+		return err
+	}
+	a.publishMove(srcPath, dstPath)
+	return a.md5Cache.Rename(GetFilePath(a.storagePath, srcPath), GetFilePath(a.storagePath, dstPath))
+}
+
+// publishMove publishes a file.changed event for a rename or move.
+func (a *StorageAdapter) publishMove(oldPath, newPath string) {
+	if a.events == nil {
+		return
+	}
+	info, err := os.Stat(GetFilePath(a.storagePath, newPath))
+	a.publish(interfaces.Event{
+		Type:    interfaces.EventFileChanged,
+		Path:    cleanRelPath(newPath),
+		OldPath: cleanRelPath(oldPath),
+		Action:  interfaces.FileMoved,
+		IsDir:   err == nil && info.IsDir(),
+	})
+}
+
+// CreateDirectory creates a directory and any missing parents.
+func (a *StorageAdapter) CreateDirectory(ctx context.Context, dirPath string) error {
+	if err := CreateDirectory(a.storagePath, dirPath); err != nil {
+		return err
+	}
+	a.publishFileChange(interfaces.FileCreated, dirPath, true)
+	return nil
+}
+
+// DeleteDirectory deletes a directory, or moves it to the trash, and drops the MD5
+// cache entries of its files.
+func (a *StorageAdapter) DeleteDirectory(ctx context.Context, dirPath string, recursive bool) error {
+	var err error
+	if a.useTrash {
+		_, err = TrashDirectory(a.storagePath, dirPath, recursive)
+	} else {
+		err = DeleteDirectory(a.storagePath, dirPath, recursive)
+	}
+	if err != nil {
+		return err
+	}
+	a.publishFileChange(interfaces.FileDeleted, dirPath, true)
+	return a.md5Cache.Invalidate(GetFilePath(a.storagePath, dirPath))
+}
+
+// ListTrash returns the items in the trash, most recently deleted first.
+func (a *StorageAdapter) ListTrash(ctx context.Context) ([]interfaces.TrashItem, error) {
+	return ListTrash(a.storagePath)
+}
+
+// GetTrashItem returns one item in the trash.
+func (a *StorageAdapter) GetTrashItem(ctx context.Context, id string) (interfaces.TrashItem, error) {
+	return GetTrashItem(a.storagePath, id)
+}
+
+// RestoreTrash moves a trash item back to its original path, or next to it under a
+// numbered name when the path is taken. The restored files are hashed again on demand.
+func (a *StorageAdapter) RestoreTrash(ctx context.Context, id string) (interfaces.TrashItem, error) {
+	item, err := RestoreTrash(a.storagePath, id)
+	if err != nil {
+		return item, err
+	}
+	a.publishFileChange(interfaces.FileCreated, item.Path, item.IsDir)
+	return item, nil
+}
+
+// PurgeTrash permanently deletes a trash item.
+func (a *StorageAdapter) PurgeTrash(ctx context.Context, id string) error {
+	return PurgeTrash(a.storagePath, id)
+}
+
+// EmptyTrash permanently deletes trash items deleted more than maxAge ago.
+func (a *StorageAdapter) EmptyTrash(ctx context.Context, maxAge time.Duration) (interfaces.CleanupStats, error) {
+	return EmptyTrash(ctx, a.storagePath, maxAge)
+}
+
+// ScanUsage measures every top-level directory, the files in the root and the
+// internal data directory.
+func (a *StorageAdapter) ScanUsage(ctx context.Context) (interfaces.StorageUsage, error) {
+	return ScanUsage(ctx, a.storagePath)
+}
+
+// DiskSpace returns the total and available bytes of the filesystem holding the storage path.
+func (a *StorageAdapter) DiskSpace() (total, available int64, err error) {
+	return DiskSpace(a.storagePath)
+}
+
+// StatFile returns the metadata of a file or directory.
+// MD5 is only set when it has already been calculated.
+func (a *StorageAdapter) StatFile(ctx context.Context, filename string) (interfaces.FileMetadata, error) {
+	f, err := StatFile(a.storagePath, filename)
+	if err != nil {
+		return interfaces.FileMetadata{}, err
+	}
+	return interfaces.FileMetadata{
+		Name:    f.Name,
+		Path:    f.Path,
+		Size:    f.Size,
+		ModTime: f.ModTime,
+		MD5:     f.MD5,
+		IsDir:   f.IsDir,
+		Inode:   f.Inode,
+	}, nil
+}
+
+// ReadFile opens a file for streaming reads.
+func (a *StorageAdapter) ReadFile(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	return OpenFile(a.storagePath, filePath)
+}
+
+// ReadFileRange opens a file for reading the inclusive byte range [start, end].
+func (a *StorageAdapter) ReadFileRange(ctx context.Context, filePath string, start, end int64) (io.ReadCloser, error) {
+	return OpenFileRange(a.storagePath, filePath, start, end)
+}
+
+// WriteFile atomically replaces a file with the content of data.
+// The cached MD5 of the previous content is replaced by the digests computed while
+// writing, or dropped when hash-on-write is disabled.
+func (a *StorageAdapter) WriteFile(ctx context.Context, filePath string, data io.Reader) error {
+	action := a.existsAction(filePath)
+	if !a.hashOnWrite {
+		if err := WriteFileStream(ctx, a.storagePath, filePath, data); err != nil {
+			return err
+		}
+		a.publishFileChange(action, filePath, false)
+		return a.md5Cache.Invalidate(GetFilePath(a.storagePath, filePath))
+	}
+
+	hasher := newWriteHasher()
+	if err := WriteFileStream(ctx, a.storagePath, filePath, io.TeeReader(data, hasher)); err != nil {
+		return err
+	}
+	a.publishFileChange(action, filePath, false)
+	return a.md5Cache.SetHashes(GetFilePath(a.storagePath, filePath), hasher.Sums())
+}
+
+// WriteFileRange writes data into a file starting at the given offset.
+// The cached MD5 of the previous content is dropped.
+func (a *StorageAdapter) WriteFileRange(ctx context.Context, filePath string, start int64, data io.Reader) error {
+	action := a.existsAction(filePath)
+	if err := WriteFileStreamAt(ctx, a.storagePath, filePath, start, data); err != nil {
+		return err
+	}
+	a.publishFileChange(action, filePath, false)
+	return a.md5Cache.Invalidate(GetFilePath(a.storagePath, filePath))
+}
+
+// PartialFileSize returns how many bytes of a ranged upload have been received.
+func (a *StorageAdapter) PartialFileSize(ctx context.Context, filePath string) (int64, error) {
+	return PartialFileSize(a.storagePath, filePath)
+}
+
+// WritePartialFile writes one segment of a ranged upload next to the target file.
+func (a *StorageAdapter) WritePartialFile(ctx context.Context, filePath string, start int64, data io.Reader) (int64, error) {
+	return WritePartialFile(ctx, a.storagePath, filePath, start, data)
+}
+
+// CommitPartialFile atomically replaces the target with a completed ranged upload.
+// The cached MD5 of the previous content is replaced by the digests of the upload,
+// computed before it is committed, or dropped when hash-on-write is disabled.
+func (a *StorageAdapter) CommitPartialFile(ctx context.Context, filePath string, size int64) error {
+	action := a.existsAction(filePath)
+	sums, err := CommitPartialFile(ctx, a.storagePath, filePath, size, a.hashOnWrite)
+	if err != nil {
+		return err
+	}
+	a.publishFileChange(action, filePath, false)
+	if sums == nil {
+		return a.md5Cache.Invalidate(GetFilePath(a.storagePath, filePath))
+	}
+	return a.md5Cache.SetHashes(GetFilePath(a.storagePath, filePath), sums)
+}
+
+// CleanStaleFiles removes chunk directories, temporary files and orphaned upload data
+// that have not been modified for maxAge.
+func (a *StorageAdapter) CleanStaleFiles(ctx context.Context, maxAge time.Duration) (interfaces.CleanupStats, error) {
+	result, err := CleanStaleFiles(ctx, a.storagePath, maxAge)
+	return interfaces.CleanupStats{
+		ChunkDirs:  result.ChunkDirs,
+		TempFiles:  result.TempFiles,
+		FreedBytes: result.FreedBytes,
+	}, err
+}
+
+// Scrub re-reads every file at no more than bytesPerSecond and compares its digests
+// with the hash index; files without valid digests are hashed and recorded.
+func (a *StorageAdapter) Scrub(ctx context.Context, bytesPerSecond int64, progress func(float64)) (interfaces.ScrubReport, error) {
+	return ScrubFiles(ctx, a.storagePath, bytesPerSecond, progress)
+}
+
+// LastScrubReport returns the report saved by the most recent scrub.
+func (a *StorageAdapter) LastScrubReport(ctx context.Context) (interfaces.ScrubReport, error) {
+	return LastScrubReport(a.storagePath)
+}
+
 // GetFilePath returns the full path of a file.
 func (a *StorageAdapter) GetFilePath(filename string) string {
 	return GetFilePath(a.storagePath, filename)
 }
 
-// MD5CalculatorAdapter implements the MD5Calculator interface, providing MD5 calculation functionality.
-// It delegates interface calls to the underlying MD5 calculation implementation.
-type MD5CalculatorAdapter struct {
+// FileHasherAdapter implements the FileHasher interface, providing MD5 and multi-algorithm hashing.
+// It delegates interface calls to the underlying hashing implementation.
+type FileHasherAdapter struct {
 	storagePath string
 	md5Cache    interfaces.MD5Cache
 }
 
-// NewMD5CalculatorAdapter creates and returns a new MD5 calculator adapter instance.
+// NewFileHasherAdapter creates and returns a new file hasher adapter instance.
 // storagePath is the file storage path, md5Cache is used for MD5 value caching.
-func NewMD5CalculatorAdapter(storagePath string, md5Cache interfaces.MD5Cache) *MD5CalculatorAdapter {
-	return &MD5CalculatorAdapter{
+func NewFileHasherAdapter(storagePath string, md5Cache interfaces.MD5Cache) *FileHasherAdapter {
+	return &FileHasherAdapter{
 		storagePath: storagePath,
 		md5Cache:    md5Cache,
 	}
 }
 
 // GetMD5 gets the MD5 value of a file, prioritizing cache reads.
-func (a *MD5CalculatorAdapter) GetMD5(ctx context.Context, filePath string) (string, error) {
-	// filePath may be a full path or relative path
-	if !strings.HasPrefix(filePath, a.storagePath) {
-		filePath = GetFilePath(a.storagePath, filePath)
-	}
-	return GetFileMD5(a.storagePath, filepath.Base(filePath))
+// filePath is relative to the storage root.
+func (a *FileHasherAdapter) GetMD5(ctx context.Context, filePath string) (string, error) {
+	return GetFileMD5(ctx, a.storagePath, filePath)
 }
 
 // GetMD5Progress gets the MD5 calculation progress information.
-func (a *MD5CalculatorAdapter) GetMD5Progress(filePath string) (float64, bool, string) {
-	return GetMD5Progress(filePath)
+// filePath is relative to the storage root; invalid paths report the error.
+func (a *FileHasherAdapter) GetMD5Progress(filePath string) (float64, bool, string) {
+	fullPath, _, err := ResolvePath(a.storagePath, filePath)
+	if err != nil {
+		return 0, false, err.Error()
+	}
+	return GetMD5Progress(fullPath)
+}
+
+// GetHashes gets several digests of a file, computing the uncached ones in a single pass.
+// filePath is relative to the storage root.
+func (a *FileHasherAdapter) GetHashes(ctx context.Context, filePath string, algorithms []string) (map[string]string, error) {
+	return GetFileHashes(ctx, a.storagePath, filePath, algorithms)
 }
 
-// CalculateMD5 calculates the MD5 value of a file.
+// CalculateHashes calculates several digests of a file without using the cache.
 // progressCallback is used to report calculation progress, can be nil.
-func (a *MD5CalculatorAdapter) CalculateMD5(ctx context.Context, filePath string, progressCallback func(float64)) (string, error) {
-	return calculateFileMD5WithProgress(filePath, progressCallback)
+func (a *FileHasherAdapter) CalculateHashes(ctx context.Context, filePath string, algorithms []string, progressCallback func(float64)) (map[string]string, error) {
+	algorithms, err := hashing.Normalize(algorithms)
+	if err != nil {
+		return nil, err
+	}
+	return calculateFileHashes(ctx, filePath, algorithms, progressCallback)
 }
@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"lfs/internal/interfaces"
+)
+
+// compressedExtensions 已压缩的文件格式，auto 模式下这些文件在 ZIP 中仅存储
+var compressedExtensions = map[string]bool{
+	".zip": true, ".gz": true, ".tgz": true, ".bz2": true, ".xz": true, ".zst": true,
+	".7z": true, ".rar": true, ".lz4": true, ".jar": true, ".apk": true,
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".heic": true,
+	".mp3": true, ".aac": true, ".ogg": true, ".flac": true, ".m4a": true, ".opus": true,
+	".mp4": true, ".mkv": true, ".mov": true, ".avi": true, ".webm": true, ".m4v": true,
+	".docx": true, ".xlsx": true, ".pptx": true, ".odt": true, ".pdf": true,
+}
+
+// ArchiveOptions 归档选项
+type ArchiveOptions struct {
+	Format      string // 归档格式：zip、tar 或 tar.gz
+	Compression string // ZIP 成员的压缩方式：auto、store 或 deflate
+}
+
+// archiveEntry 归档中的一个条目
+type archiveEntry struct {
+	fullPath string      // 文件系统中的完整路径
+	name     string      // 归档中的条目名（以 / 分隔）
+	info     os.FileInfo // 条目的文件信息
+}
+
+// archiveWriter 抽象 ZIP 与 TAR 的条目写入
+type archiveWriter interface {
+	writeEntry(ctx context.Context, entry archiveEntry) error
+	Close() error
+}
+
+// WriteArchive 将指定的文件和目录（递归）打包后写入 w
+// 所有路径都在写入数据之前解析和校验，归档内的条目名相对于所有路径的公共父目录
+func WriteArchive(ctx context.Context, storagePath string, w io.Writer, paths []string, opts ArchiveOptions) error {
+	if len(paths) == 0 {
+		return interfaces.ErrInvalidPath
+	}
+
+	aw, err := newArchiveWriter(w, opts)
+	if err != nil {
+		return err
+	}
+
+	roots := make([]archiveEntry, 0, len(paths))
+	rels := make([]string, 0, len(paths))
+	for _, p := range paths {
+		fullPath, rel, err := ResolvePath(storagePath, p)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			return err
+		}
+		roots = append(roots, archiveEntry{fullPath: fullPath, name: rel, info: info})
+		rels = append(rels, rel)
+	}
+
+	base := commonParent(rels)
+	written := make(map[string]bool)
+
+	for _, root := range roots {
+		err := walkArchiveEntries(root.fullPath, root.name, root.info, func(entry archiveEntry) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			entry.name = strings.TrimPrefix(strings.TrimPrefix(entry.name, base), "/")
+			// 根目录本身以及重复请求的路径不再写入
+			if entry.name == "" || written[entry.name] {
+				return nil
+			}
+			written[entry.name] = true
+
+			return aw.writeEntry(ctx, entry)
+		})
+		// 出错时不写入归档结尾，客户端收到的是不完整的归档而不是看似完整的文件
+		if err != nil {
+			return err
+		}
+	}
+
+	return aw.Close()
+}
+
+// newArchiveWriter 根据选项创建归档写入器
+func newArchiveWriter(w io.Writer, opts ArchiveOptions) (archiveWriter, error) {
+	switch opts.Compression {
+	case "", interfaces.ArchiveCompressionAuto, interfaces.ArchiveCompressionStore, interfaces.ArchiveCompressionDeflate:
+	default:
+		return nil, interfaces.ErrUnsupportedArchiveFormat
+	}
+
+	switch opts.Format {
+	case interfaces.ArchiveFormatZip:
+		return &zipArchiveWriter{zw: zip.NewWriter(w), compression: opts.Compression}, nil
+	case interfaces.ArchiveFormatTar:
+		return &tarArchiveWriter{tw: tar.NewWriter(w)}, nil
+	case interfaces.ArchiveFormatTarGz:
+		gz := gzip.NewWriter(w)
+		return &tarArchiveWriter{tw: tar.NewWriter(gz), gz: gz}, nil
+	default:
+		return nil, interfaces.ErrUnsupportedArchiveFormat
+	}
+}
+
+// walkArchiveEntries 遍历文件或目录下的所有条目
+// 符号链接会被跳过，以免归档中包含存储路径之外的内容
+func walkArchiveEntries(fullPath, rel string, info os.FileInfo, fn func(archiveEntry) error) error {
+	if !info.IsDir() {
+		return fn(archiveEntry{fullPath: fullPath, name: rel, info: info})
+	}
+
+	return filepath.WalkDir(fullPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+
+		sub, err := filepath.Rel(fullPath, p)
+		if err != nil {
+			return err
+		}
+		name := rel
+		if sub != "." {
+			name = path.Join(rel, filepath.ToSlash(sub))
+		}
+
+		// 存储根目录下的内部数据目录不对外暴露
+		if d.IsDir() && name == InternalDirName {
+			return filepath.SkipDir
+		}
+
+		entryInfo, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !entryInfo.IsDir() && !entryInfo.Mode().IsRegular() {
+			return nil
+		}
+		return fn(archiveEntry{fullPath: p, name: name, info: entryInfo})
+	})
+}
+
+// commonParent 返回多个相对路径的公共父目录（根目录为空字符串）
+func commonParent(rels []string) string {
+	parent := func(rel string) string {
+		dir := path.Dir(rel)
+		if dir == "." {
+			return ""
+		}
+		return dir
+	}
+
+	base := parent(rels[0])
+	for _, rel := range rels[1:] {
+		for base != "" && rel != base && !strings.HasPrefix(rel, base+"/") {
+			base = parent(base)
+		}
+		// 请求的路径本身就是公共父目录时，归档中需要保留它的名称
+		if rel == base {
+			base = parent(base)
+		}
+	}
+	return base
+}
+
+// zipArchiveWriter ZIP 格式的归档写入器，超过 4GB 的成员自动使用 ZIP64
+type zipArchiveWriter struct {
+	zw          *zip.Writer
+	compression string
+}
+
+func (a *zipArchiveWriter) writeEntry(ctx context.Context, entry archiveEntry) error {
+	header, err := zip.FileInfoHeader(entry.info)
+	if err != nil {
+		return err
+	}
+	header.Name = entry.name
+	header.Modified = entry.info.ModTime()
+
+	if entry.info.IsDir() {
+		header.Name += "/"
+		header.Method = zip.Store
+		_, err := a.zw.CreateHeader(header)
+		return err
+	}
+
+	header.Method = zip.Deflate
+	switch a.compression {
+	case interfaces.ArchiveCompressionStore:
+		header.Method = zip.Store
+	case "", interfaces.ArchiveCompressionAuto:
+		if compressedExtensions[strings.ToLower(path.Ext(entry.name))] {
+			header.Method = zip.Store
+		}
+	}
+
+	dst, err := a.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	return copyArchiveFile(ctx, dst, entry)
+}
+
+func (a *zipArchiveWriter) Close() error {
+	return a.zw.Close()
+}
+
+// tarArchiveWriter TAR 格式的归档写入器，可选 gzip 压缩
+type tarArchiveWriter struct {
+	tw *tar.Writer
+	gz *gzip.Writer
+}
+
+func (a *tarArchiveWriter) writeEntry(ctx context.Context, entry archiveEntry) error {
+	header, err := tar.FileInfoHeader(entry.info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = entry.name
+	// 不暴露服务器上的用户和组
+	header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+	if entry.info.IsDir() {
+		header.Name += "/"
+	}
+	// PAX 格式支持超过 8GB 的成员和长文件名
+	header.Format = tar.FormatPAX
+
+	if err := a.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if entry.info.IsDir() {
+		return nil
+	}
+	return copyArchiveFile(ctx, a.tw, entry)
+}
+
+func (a *tarArchiveWriter) Close() error {
+	err := a.tw.Close()
+	if a.gz != nil {
+		if gzErr := a.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	return err
+}
+
+// copyArchiveFile 将文件内容写入归档条目
+// 只写入遍历时的文件大小，写入过程中文件变短时返回错误
+func copyArchiveFile(ctx context.Context, dst io.Writer, entry archiveEntry) error {
+	f, err := os.Open(entry.fullPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	size := entry.info.Size()
+	counter := &countingWriter{w: dst}
+	if err := copyWithCancel(ctx, counter, io.LimitReader(f, size), size); err != nil {
+		return err
+	}
+	if counter.n != size {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"hash"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"lfs/internal/interfaces"
+	"lfs/pkg/hashing"
+)
+
+// chunkSumSuffix 分片校验值文件的后缀，内容为 "<算法> <十六进制校验值>"
+const chunkSumSuffix = ".sum"
+
+// chunkFailuresFile 分片目录中记录校验失败的文件名
+const chunkFailuresFile = "failures.json"
+
+// chunkFailureMutex 串行化对校验失败记录的读写，同一文件的分片可能并行上传
+var chunkFailureMutex sync.Mutex
+
+// newChunkHash 根据算法名创建分片校验使用的哈希，空算法名表示 md5
+func newChunkHash(algorithm string) (hash.Hash, error) {
+	if algorithm == "" {
+		algorithm = hashing.MD5
+	}
+	h, err := hashing.New(algorithm)
+	if err != nil {
+		return nil, interfaces.ErrChunkChecksumAlgorithm
+	}
+	return h, nil
+}
+
+// readChunkChecksum 读取分片保存的校验值，没有校验值时返回空字符串
+func readChunkChecksum(chunkPath string) (algorithm, sum string) {
+	data, err := os.ReadFile(chunkPath + chunkSumSuffix)
+	if err != nil {
+		return "", ""
+	}
+	parts := strings.Fields(string(data))
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// recordChunkFailure 记录一次分片校验失败，合并报告中会列出
+func recordChunkFailure(chunkDir string, index int, reason string) {
+	chunkFailureMutex.Lock()
+	defer chunkFailureMutex.Unlock()
+
+	path := filepath.Join(chunkDir, chunkFailuresFile)
+	failures := make(map[string]interfaces.ChunkFailure)
+	readJSONFile(path, &failures)
+
+	key := strconv.Itoa(index)
+	failure := failures[key]
+	failure.Index = index
+	failure.Attempts++
+	failure.Reason = reason
+	failures[key] = failure
+	writeJSONFile(path, failures)
+}
+
+// readChunkFailures 返回分片目录中记录的校验失败，按分片索引排序
+func readChunkFailures(chunkDir string) []interfaces.ChunkFailure {
+	chunkFailureMutex.Lock()
+	defer chunkFailureMutex.Unlock()
+
+	failures := make(map[string]interfaces.ChunkFailure)
+	readJSONFile(filepath.Join(chunkDir, chunkFailuresFile), &failures)
+
+	result := make([]interfaces.ChunkFailure, 0, len(failures))
+	for _, failure := range failures {
+		result = append(result, failure)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Index < result[j].Index
+	})
+	return result
+}
@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// legacyChunkPattern 旧版分片文件名：<文件名>_<序号>，写入中的分片带 .part 后缀
+var legacyChunkPattern = regexp.MustCompile(`^(.+)_\d+(\.part)?$`)
+
+// CleanupResult 一次清理的统计
+type CleanupResult struct {
+	ChunkDirs  int   // 删除的分片目录数
+	TempFiles  int   // 删除的临时文件和残留数据数
+	FreedBytes int64 // 释放的字节数
+}
+
+// isTempFileName 判断是否为 WriteFileStream 写入过程中的临时文件（.<文件名>.tmp-<随机串>）
+func isTempFileName(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, ".tmp-")
+}
+
+// CleanStaleFiles 删除超过 maxAge 未修改的残留文件：
+//   - 中断写入留下的临时文件
+//   - 内部数据目录下的分片目录，以及旧版本留在存储根目录 chunks 下的分片目录
+//   - 没有元数据的上传会话目录和 tus 数据文件
+func CleanStaleFiles(ctx context.Context, storagePath string, maxAge time.Duration) (CleanupResult, error) {
+	var result CleanupResult
+	cutoff := time.Now().Add(-maxAge)
+	internalDir := filepath.Join(storagePath, InternalDirName)
+
+	if err := cleanTempFiles(ctx, storagePath, internalDir, cutoff, &result); err != nil {
+		return result, err
+	}
+	if err := cleanChunkTree(ctx, filepath.Join(internalDir, ChunksDirName), cutoff, false, &result); err != nil {
+		return result, err
+	}
+	// 旧版本把分片放在用户可见的 chunks 目录下，只有完全由分片文件组成时才视为分片目录
+	if err := cleanChunkTree(ctx, filepath.Join(storagePath, ChunksDirName), cutoff, true, &result); err != nil {
+		return result, err
+	}
+	if err := cleanOrphanedUploads(internalDir, cutoff, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// cleanTempFiles 删除过期的临时文件，内部数据目录下还包括元数据临时文件和写入中的分片
+func cleanTempFiles(ctx context.Context, storagePath, internalDir string, cutoff time.Time, result *CleanupResult) error {
+	err := filepath.WalkDir(storagePath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 遍历过程中被删除的文件忽略即可
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		// 回收站中是用户删除的文件，只能按回收站的保留时间清除
+		if d.IsDir() && p == trashDir(storagePath) {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+
+		name := d.Name()
+		internal := isSameOrChildPath(p, internalDir)
+		if !isTempFileName(name) && !(internal && (strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, ".part"))) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(p); err == nil {
+			result.TempFiles++
+			result.FreedBytes += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// cleanChunkTree 删除分片根目录下过期的分片目录（最新分片的修改时间早于 cutoff），并清理空目录
+// legacyOnly 为 true 时，只有整个目录树都由分片文件组成才会处理
+func cleanChunkTree(ctx context.Context, root string, cutoff time.Time, legacyOnly bool, result *CleanupResult) error {
+	if _, err := os.Stat(root); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if legacyOnly && !isLegacyChunkTree(root) {
+		return nil
+	}
+
+	// 收集每个目录中分片的最新修改时间和总大小
+	type chunkDirInfo struct {
+		newest time.Time
+		size   int64
+	}
+	dirs := make(map[string]*chunkDirInfo)
+	var allDirs []string
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if d.IsDir() {
+			allDirs = append(allDirs, p)
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		dir := filepath.Dir(p)
+		if dirs[dir] == nil {
+			dirs[dir] = &chunkDirInfo{}
+		}
+		if info.ModTime().After(dirs[dir].newest) {
+			dirs[dir].newest = info.ModTime()
+		}
+		dirs[dir].size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for dir, info := range dirs {
+		if info.newest.After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		result.ChunkDirs++
+		result.FreedBytes += info.size
+	}
+
+	// 从最深的目录开始删除空目录，刚创建的目录可能即将写入分片，保留
+	// 旧版目录不会再有新分片写入，清空后连同根目录一起删除
+	for i := len(allDirs) - 1; i >= 0; i-- {
+		dir := allDirs[i]
+		if !legacyOnly {
+			if dir == root {
+				continue
+			}
+			if info, err := os.Stat(dir); err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+		}
+		os.Remove(dir) // 非空目录删除失败，忽略
+	}
+	return nil
+}
+
+// isLegacyChunkTree 判断目录树是否只包含旧版分片文件（<目录名>_<序号>）且至少有一个分片
+func isLegacyChunkTree(root string) bool {
+	found, valid := false, true
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			valid = false
+			return fs.SkipAll
+		}
+		if d.IsDir() {
+			return nil
+		}
+		m := legacyChunkPattern.FindStringSubmatch(d.Name())
+		if m == nil || m[1] != filepath.Base(filepath.Dir(p)) {
+			valid = false
+			return fs.SkipAll
+		}
+		found = true
+		return nil
+	})
+	return found && valid
+}
+
+// cleanOrphanedUploads 删除没有元数据的过期上传数据：uploads 下缺少 session.json 的目录和 tus 下缺少 .info 的数据文件
+func cleanOrphanedUploads(internalDir string, cutoff time.Time, result *CleanupResult) error {
+	uploadsDir := filepath.Join(internalDir, UploadsDirName)
+	entries, err := os.ReadDir(uploadsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		dir := filepath.Join(uploadsDir, entry.Name())
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, uploadSessionFile)); err == nil {
+			continue
+		}
+		size, newest := dirUsage(dir)
+		if newest.After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err == nil {
+			result.TempFiles++
+			result.FreedBytes += size
+		}
+	}
+
+	tusDir := filepath.Join(internalDir, TusDirName)
+	entries, err = os.ReadDir(tusDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".bin" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(tusDir, strings.TrimSuffix(name, ".bin")+".info")); err == nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(tusDir, name)); err == nil {
+			result.TempFiles++
+			result.FreedBytes += info.Size()
+		}
+	}
+	return nil
+}
+
+// dirUsage 返回目录下所有文件的总大小和最新修改时间（包括目录本身）
+func dirUsage(dir string) (int64, time.Time) {
+	var size int64
+	var newest time.Time
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		if !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, newest
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"lfs/internal/interfaces"
+)
+
+// fileETag 返回文件的强实体标签，与 StatFile 返回的元数据的 ETag 一致，不受MD5缓存状态影响
+func fileETag(info os.FileInfo) string {
+	return interfaces.FileMetadata{
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		Inode:   fileInode(info),
+	}.ETag()
+}
+
+// setValidators 设置响应的 ETag 和 Last-Modified
+func setValidators(header http.Header, etag string, modTime time.Time) {
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+}
+
+// checkConditional 按 RFC 9110 13.2.2 的顺序评估 GET/HEAD 请求的条件请求头
+// 返回 0 表示应正常响应，否则返回 304（未修改）或 412（前置条件失败）
+// If-None-Match 存在时忽略 If-Modified-Since，If-Match 存在时忽略 If-Unmodified-Since
+func checkConditional(r *http.Request, etag string, modTime time.Time) int {
+	// HTTP 日期只精确到秒
+	modTime = modTime.Truncate(time.Second)
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if strings.TrimSpace(ifMatch) != "*" && !interfaces.ETagListContains(ifMatch, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since := r.Header.Get("If-Unmodified-Since"); since != "" {
+		if t, err := http.ParseTime(since); err == nil && modTime.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if strings.TrimSpace(ifNoneMatch) == "*" || interfaces.ETagListContains(ifNoneMatch, etag, true) {
+			return http.StatusNotModified
+		}
+	} else if since := r.Header.Get("If-Modified-Since"); since != "" {
+		if t, err := http.ParseTime(since); err == nil && !modTime.After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
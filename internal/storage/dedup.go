@@ -0,0 +1,445 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"log"
+	"mime/multipart"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"lfs/internal/interfaces"
+	"lfs/pkg/hashing"
+)
+
+const (
+	// DedupDirName 去重存储在内部数据目录下的目录名
+	DedupDirName = "dedup"
+
+	// dedupIndexFile 路径到对象哈希的索引文件名
+	dedupIndexFile = "index.json"
+
+	// dedupIndexVersion 索引文件格式版本
+	dedupIndexVersion = 1
+
+	// dedupIndexSaveDelay 索引变更后延迟写盘的时间
+	dedupIndexSaveDelay = 2 * time.Second
+)
+
+// dedupIndex 索引文件内容，键为相对存储根目录的路径（使用 / 分隔），值为内容的 SHA-256
+type dedupIndex struct {
+	Version int               `json:"version"`
+	Paths   map[string]string `json:"paths"`
+}
+
+// DedupStorage implements the Storage interface on top of a StorageAdapter,
+// storing identical content only once.
+//
+// Every completed file is hard linked to a blob under .lfs/dedup/objects/ab/cd/<sha256>;
+// when a blob with the same content already exists, the file is replaced by a link to
+// it. A path to hash index keeps a reference count per blob, so deleting one name
+// never removes content still used by another, and the blob goes away with its last
+// name. Paths are copied out of their blob before any in-place modification. Because
+// names share an inode, they also share its modification time.
+type DedupStorage struct {
+	*StorageAdapter
+
+	objectsDir string
+	indexPath  string
+
+	mutex     sync.Mutex
+	paths     map[string]string // relative path -> sha256
+	refs      map[string]int    // sha256 -> number of paths
+	sizes     map[string]int64  // sha256 -> blob size
+	saveTimer *time.Timer
+}
+
+// NewDedupStorage creates a deduplicating storage on top of base and loads its index.
+// Entries whose file was removed or changed outside the server are dropped, and
+// blobs no longer referenced by any path are deleted.
+func NewDedupStorage(base *StorageAdapter) (*DedupStorage, error) {
+	dedupDir := filepath.Join(base.storagePath, InternalDirName, DedupDirName)
+	d := &DedupStorage{
+		StorageAdapter: base,
+		objectsDir:     filepath.Join(dedupDir, "objects"),
+		indexPath:      filepath.Join(dedupDir, dedupIndexFile),
+		paths:          make(map[string]string),
+		refs:           make(map[string]int),
+		sizes:          make(map[string]int64),
+	}
+	if err := os.MkdirAll(d.objectsDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	var index dedupIndex
+	if err := readJSONFile(d.indexPath, &index); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	changed := false
+	for rel, sum := range index.Paths {
+		info, err := os.Stat(d.fullPath(rel))
+		if err != nil {
+			changed = true
+			continue
+		}
+		blob, err := os.Stat(d.objectPath(sum))
+		if err != nil || !os.SameFile(info, blob) {
+			changed = true
+			continue
+		}
+		d.paths[rel] = sum
+		d.refs[sum]++
+		d.sizes[sum] = blob.Size()
+	}
+
+	// Blobs left behind by a crash between linking and saving the index
+	err := filepath.WalkDir(d.objectsDir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if d.refs[entry.Name()] == 0 {
+			os.Remove(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if changed {
+		d.mutex.Lock()
+		d.scheduleSaveLocked()
+		d.mutex.Unlock()
+	}
+	return d, nil
+}
+
+// fullPath returns the absolute path of a relative path.
+func (d *DedupStorage) fullPath(rel string) string {
+	return filepath.Join(d.storagePath, filepath.FromSlash(rel))
+}
+
+// objectPath returns the blob path of a SHA-256 digest: objects/ab/cd/<hash>.
+func (d *DedupStorage) objectPath(sum string) string {
+	return filepath.Join(d.objectsDir, sum[0:2], sum[2:4], sum)
+}
+
+// SaveFile saves an uploaded file and deduplicates it. Resumed uploads write into
+// the existing file in place, so a shared file is copied out of its blob first.
+func (d *DedupStorage) SaveFile(ctx context.Context, targetDir string, file *multipart.FileHeader, rangeHeader string, conflict interfaces.ConflictPolicy) (interfaces.SaveResult, error) {
+	if start, err := parseRangeStart(rangeHeader); err == nil && start > 0 {
+		if err := d.unshare(cleanRelPath(path.Join(targetDir, file.Filename))); err != nil {
+			return interfaces.SaveResult{}, err
+		}
+	}
+	result, err := d.StorageAdapter.SaveFile(ctx, targetDir, file, rangeHeader, conflict)
+	if err != nil || result.Skipped {
+		return result, err
+	}
+	return result, d.ingest(ctx, result.Path)
+}
+
+// SaveFileChunk saves a chunk and deduplicates the merged file once the last chunk arrives.
+func (d *DedupStorage) SaveFileChunk(ctx context.Context, chunkInfo interfaces.FileChunkInfo, file *multipart.FileHeader) (*interfaces.ChunkMergeReport, error) {
+	report, err := d.StorageAdapter.SaveFileChunk(ctx, chunkInfo, file)
+	if err != nil || report == nil || report.Skipped {
+		return report, err
+	}
+	return report, d.ingest(ctx, report.Path)
+}
+
+// WriteFile atomically replaces a file and deduplicates the new content.
+func (d *DedupStorage) WriteFile(ctx context.Context, filePath string, data io.Reader) error {
+	if err := d.StorageAdapter.WriteFile(ctx, filePath, data); err != nil {
+		return err
+	}
+	return d.ingest(ctx, cleanRelPath(filePath))
+}
+
+// WriteFileRange writes into a file in place, so a shared file is copied out of its blob first.
+// The file is not deduplicated again until it is replaced as a whole.
+func (d *DedupStorage) WriteFileRange(ctx context.Context, filePath string, start int64, data io.Reader) error {
+	if err := d.unshare(cleanRelPath(filePath)); err != nil {
+		return err
+	}
+	return d.StorageAdapter.WriteFileRange(ctx, filePath, start, data)
+}
+
+// CommitPartialFile replaces the target with a completed ranged upload and deduplicates it.
+func (d *DedupStorage) CommitPartialFile(ctx context.Context, filePath string, size int64) error {
+	if err := d.StorageAdapter.CommitPartialFile(ctx, filePath, size); err != nil {
+		return err
+	}
+	return d.ingest(ctx, cleanRelPath(filePath))
+}
+
+// DeleteFile deletes a file and drops its reference; the blob is removed with its last name.
+func (d *DedupStorage) DeleteFile(ctx context.Context, filename string) error {
+	if err := d.StorageAdapter.DeleteFile(ctx, filename); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.releaseTreeLocked(cleanRelPath(filename))
+	return nil
+}
+
+// RenameFile renames a file or directory in place and moves its index entries.
+func (d *DedupStorage) RenameFile(ctx context.Context, filename, newName string) error {
+	if err := d.StorageAdapter.RenameFile(ctx, filename, newName); err != nil {
+		return err
+	}
+	d.moveTree(cleanRelPath(filename), cleanRelPath(path.Join(path.Dir(filepath.ToSlash(filename)), newName)))
+	return nil
+}
+
+// MoveFile moves a file or directory and moves its index entries.
+func (d *DedupStorage) MoveFile(ctx context.Context, srcPath, dstPath string) error {
+	if err := d.StorageAdapter.MoveFile(ctx, srcPath, dstPath); err != nil {
+		return err
+	}
+	d.moveTree(cleanRelPath(srcPath), cleanRelPath(dstPath))
+	return nil
+}
+
+// DeleteDirectory deletes a directory and drops the references of all files under it.
+func (d *DedupStorage) DeleteDirectory(ctx context.Context, dirPath string, recursive bool) error {
+	if err := d.StorageAdapter.DeleteDirectory(ctx, dirPath, recursive); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.releaseTreeLocked(cleanRelPath(dirPath))
+	return nil
+}
+
+// RestoreTrash restores a trash item and deduplicates the restored files again;
+// their references were dropped when they were deleted.
+func (d *DedupStorage) RestoreTrash(ctx context.Context, id string) (interfaces.TrashItem, error) {
+	item, err := d.StorageAdapter.RestoreTrash(ctx, id)
+	if err != nil {
+		return item, err
+	}
+	err = filepath.WalkDir(d.fullPath(item.Path), func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(d.storagePath, p)
+		if err != nil {
+			return err
+		}
+		return d.ingest(ctx, filepath.ToSlash(rel))
+	})
+	return item, err
+}
+
+// DedupStats reports logical (all names) versus physical (unique blobs) bytes.
+func (d *DedupStorage) DedupStats(ctx context.Context) (interfaces.DedupStats, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	stats := interfaces.DedupStats{
+		Files:   len(d.paths),
+		Objects: len(d.refs),
+		Ratio:   1,
+	}
+	for sum, refs := range d.refs {
+		stats.PhysicalBytes += d.sizes[sum]
+		stats.LogicalBytes += int64(refs) * d.sizes[sum]
+	}
+	stats.SavedBytes = stats.LogicalBytes - stats.PhysicalBytes
+	if stats.PhysicalBytes > 0 {
+		stats.Ratio = float64(stats.LogicalBytes) / float64(stats.PhysicalBytes)
+	}
+	return stats, nil
+}
+
+// ingest links a completely written file into the object store.
+// A file that cannot be linked (for example on file systems without hard links)
+// is kept as it is and simply not deduplicated.
+func (d *DedupStorage) ingest(ctx context.Context, rel string) error {
+	full := d.fullPath(rel)
+	info, err := os.Stat(full)
+	if err != nil || !info.Mode().IsRegular() {
+		return err
+	}
+
+	// Uploads are hashed while writing, so the digest is normally cached already
+	sums, missing := md5Cache.GetHashesFromCache(full, info, writeHashAlgorithms)
+	if len(missing) > 0 {
+		if sums, err = calculateFileHashes(ctx, full, writeHashAlgorithms, nil); err != nil {
+			return err
+		}
+		md5Cache.SetHashesToCache(full, sums, stampOf(info))
+	}
+	sum := sums[hashing.SHA256]
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// The previous content of this path was replaced, release it
+	d.releaseLocked(rel)
+
+	blobPath := d.objectPath(sum)
+	blob, err := os.Stat(blobPath)
+	switch {
+	case err == nil && os.SameFile(info, blob):
+		// Already linked
+	case err == nil:
+		if err := replaceWithLink(blobPath, full); err != nil {
+			log.Printf("Dedup: keeping %s as a separate copy: %v", rel, err)
+			return nil
+		}
+		// The path now has the blob's inode and modification time
+		if linked, err := os.Stat(full); err == nil {
+			md5Cache.SetHashesToCache(full, sums, stampOf(linked))
+		}
+	default:
+		if err := os.MkdirAll(filepath.Dir(blobPath), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.Link(full, blobPath); err != nil {
+			log.Printf("Dedup: keeping %s as a separate copy: %v", rel, err)
+			return nil
+		}
+	}
+
+	d.paths[rel] = sum
+	d.refs[sum]++
+	d.sizes[sum] = info.Size()
+	d.scheduleSaveLocked()
+	return nil
+}
+
+// replaceWithLink atomically replaces dest with a hard link to blobPath.
+func replaceWithLink(blobPath, dest string) error {
+	tmp, err := createTempFile(dest)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpPath)
+
+	if err := os.Link(blobPath, tmpPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, dest); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// unshare gives a deduplicated path its own copy of the content before it is modified in place.
+func (d *DedupStorage) unshare(rel string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.paths[rel]; !ok {
+		return nil
+	}
+
+	full := d.fullPath(rel)
+	src, err := os.Open(full)
+	if err != nil {
+		if os.IsNotExist(err) {
+			d.releaseLocked(rel)
+			return nil
+		}
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := createTempFile(full)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := commitTempFile(tmp, full); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	d.releaseLocked(rel)
+	return nil
+}
+
+// moveTree moves the index entries of a file or directory to a new path.
+func (d *DedupStorage) moveTree(oldRel, newRel string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	moved := make(map[string]string)
+	for rel, sum := range d.paths {
+		if rel == oldRel || strings.HasPrefix(rel, oldRel+"/") {
+			moved[newRel+strings.TrimPrefix(rel, oldRel)] = sum
+			delete(d.paths, rel)
+		}
+	}
+	if len(moved) == 0 {
+		return
+	}
+	for rel, sum := range moved {
+		d.paths[rel] = sum
+	}
+	d.scheduleSaveLocked()
+}
+
+// releaseTreeLocked drops the references of a file or of all files under a directory.
+// The caller must hold the mutex.
+func (d *DedupStorage) releaseTreeLocked(rel string) {
+	for p := range d.paths {
+		if rel == "" || p == rel || strings.HasPrefix(p, rel+"/") {
+			d.releaseLocked(p)
+		}
+	}
+}
+
+// releaseLocked drops the reference of one path and deletes the blob once no path uses it.
+// The caller must hold the mutex.
+func (d *DedupStorage) releaseLocked(rel string) {
+	sum, ok := d.paths[rel]
+	if !ok {
+		return
+	}
+	delete(d.paths, rel)
+	d.refs[sum]--
+	if d.refs[sum] <= 0 {
+		delete(d.refs, sum)
+		delete(d.sizes, sum)
+		os.Remove(d.objectPath(sum))
+	}
+	d.scheduleSaveLocked()
+}
+
+// scheduleSaveLocked arranges for the index to be written shortly. Entries lost in a
+// crash before the write are harmless: loading drops unknown links and orphaned blobs.
+// The caller must hold the mutex.
+func (d *DedupStorage) scheduleSaveLocked() {
+	if d.saveTimer != nil {
+		return
+	}
+	d.saveTimer = time.AfterFunc(dedupIndexSaveDelay, func() {
+		d.mutex.Lock()
+		d.saveTimer = nil
+		index := dedupIndex{Version: dedupIndexVersion, Paths: make(map[string]string, len(d.paths))}
+		for rel, sum := range d.paths {
+			index.Paths[rel] = sum
+		}
+		// Written under the lock so saves never overtake each other
+		if err := writeJSONFile(d.indexPath, index); err != nil {
+			log.Printf("Failed to save dedup index: %v", err)
+		}
+		d.mutex.Unlock()
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package storage
+
+import "errors"
+
+// DiskSpace 当前平台不支持查询文件系统容量，不检查最小剩余空间
+func DiskSpace(path string) (total, available int64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package storage
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// DiskSpace 返回 path 所在卷的总容量和当前用户可用的空间（字节）
+func DiskSpace(path string) (total, available int64, err error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var free, size, totalFree uint64
+	r, _, e := procGetDiskFreeSpaceExW.Call(uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&free)), uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&totalFree)))
+	if r == 0 {
+		return 0, 0, e
+	}
+	return int64(size), int64(free), nil
+}
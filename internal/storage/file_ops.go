@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"lfs/internal/interfaces"
+)
+
+// DeleteFile 删除文件（不能删除目录）
+func DeleteFile(storagePath, filename string) error {
+	fullPath, rel, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return err
+	}
+	if rel == "" {
+		return interfaces.ErrInvalidPath
+	}
+
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.New("is a directory: " + rel)
+	}
+
+	return os.Remove(fullPath)
+}
+
+// MoveFile 移动文件或目录，目标已存在时返回 os.ErrExist
+func MoveFile(storagePath, srcPath, dstPath string) error {
+	src, srcRel, err := ResolvePath(storagePath, srcPath)
+	if err != nil {
+		return err
+	}
+	dst, dstRel, err := ResolvePath(storagePath, dstPath)
+	if err != nil {
+		return err
+	}
+	if srcRel == "" || dstRel == "" {
+		return interfaces.ErrInvalidPath
+	}
+
+	// 不能把目录移动到自身内部
+	if isSameOrChildPath(dst, src) {
+		return interfaces.ErrInvalidPath
+	}
+
+	if _, err := os.Lstat(src); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return os.ErrExist
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+// RenameFile 在同一目录下重命名文件或目录
+func RenameFile(storagePath, filename, newName string) error {
+	if newName == "" || newName == "." || newName == ".." || strings.ContainsAny(newName, "/\\") {
+		return interfaces.ErrInvalidPath
+	}
+	return MoveFile(storagePath, filename, filepath.Join(filepath.Dir(filename), newName))
+}
+
+// CreateDirectory 创建目录（包括所有父目录）
+func CreateDirectory(storagePath, dirPath string) error {
+	fullPath, rel, err := ResolvePath(storagePath, dirPath)
+	if err != nil {
+		return err
+	}
+	if rel == "" {
+		return interfaces.ErrInvalidPath
+	}
+
+	if info, err := os.Stat(fullPath); err == nil && !info.IsDir() {
+		return os.ErrExist
+	}
+	return os.MkdirAll(fullPath, os.ModePerm)
+}
+
+// DeleteDirectory 删除目录，recursive 为 false 时目录必须为空
+func DeleteDirectory(storagePath, dirPath string, recursive bool) error {
+	fullPath, rel, err := ResolvePath(storagePath, dirPath)
+	if err != nil {
+		return err
+	}
+	// 不允许删除存储根目录
+	if rel == "" {
+		return interfaces.ErrInvalidPath
+	}
+
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("not a directory: " + rel)
+	}
+
+	if recursive {
+		return os.RemoveAll(fullPath)
+	}
+
+	if err := os.Remove(fullPath); err != nil {
+		if errors.Is(err, syscall.ENOTEMPTY) || errors.Is(err, syscall.EEXIST) {
+			return interfaces.ErrDirectoryNotEmpty
+		}
+		return err
+	}
+	return nil
+}
+
+// availablePath 返回不与已有条目冲突的路径：fullPath 不存在时原样返回，
+// 否则在名称后追加 " (1)"、" (2)" 等序号，文件的序号加在扩展名之前
+func availablePath(fullPath string, isDir bool) string {
+	if _, err := os.Lstat(fullPath); err != nil {
+		return fullPath
+	}
+
+	dir, name := filepath.Split(fullPath)
+	ext := ""
+	if !isDir && filepath.Ext(name) != name {
+		ext = filepath.Ext(name)
+	}
+	stem := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s (%d)%s", stem, i, ext))
+		if _, err := os.Lstat(candidate); err != nil {
+			return candidate
+		}
+	}
+}
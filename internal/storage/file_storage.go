@@ -2,19 +2,26 @@ package storage
 
 import (
 	"context"
-	"crypto/md5"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"lfs/internal/interfaces"
+	"lfs/pkg/hashing"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -27,9 +34,11 @@ const (
 	// 分片大小
 	DefaultChunkSize = 5 * 1024 * 1024 // 5MB
 
-	// MD5计算配置
-	MD5ChunkSize     = 64 * 1024 * 1024 // 64MB 分块大小，适合大文件
-	MD5MaxConcurrent = 3                // 最大并发计算数
+	// 内部数据目录（Git LFS 对象等），不出现在文件列表中
+	InternalDirName = ".lfs"
+
+	// 旧版分片上传在内部数据目录下的目录名
+	ChunksDirName = "chunks"
 
 	// 错误消息
 	ErrFileNotFound  = "file not found"
@@ -49,6 +58,7 @@ type FileMetadata struct {
 	MD5      string         `json:"md5,omitempty"`
 	IsDir    bool           `json:"is_dir"`             // 是否为文件夹
 	Children []FileMetadata `json:"children,omitempty"` // 子文件/文件夹（仅当IsDir为true时）
+	Inode    uint64         `json:"-"`                  // inode 编号，用于生成实体标签
 }
 
 // FileChunkInfo 文件分片信息
@@ -59,452 +69,545 @@ type FileChunkInfo struct {
 	ChunkSize  int64  `json:"chunk_size"`
 	TotalChunk int    `json:"total_chunk"`
 	MD5        string `json:"md5"`
-}
 
-// MD5CacheEntry MD5缓存条目
-type MD5CacheEntry struct {
-	MD5         string  `json:"md5"`
-	FilePath    string  `json:"file_path"` // 文件路径（包含文件名）
-	FileName    string  `json:"file_name"` // 文件名
-	Size        int64   `json:"size"`
-	Calculated  bool    `json:"calculated"`
-	Calculating bool    `json:"calculating"`     // 是否正在计算中
-	Progress    float64 `json:"progress"`        // 计算进度 0.0-1.0
-	Error       string  `json:"error,omitempty"` // 计算错误信息
-}
-
-// MD5Cache MD5缓存管理器
-type MD5Cache struct {
-	cache       map[string]*MD5CacheEntry // 缓存：key为 fileName:size
-	filePathMap map[string]string         // 反向映射：filePath -> cacheKey (用于进度查询)
-	mutex       sync.RWMutex
-	semaphore   chan struct{} // 控制并发计算数量
-}
+	ChunkHashAlgorithm string `json:"chunk_hash_algorithm,omitempty"`
+	ChunkHash          string `json:"chunk_hash,omitempty"`
 
-// 全局MD5缓存实例
-var md5Cache = &MD5Cache{
-	cache:       make(map[string]*MD5CacheEntry),
-	filePathMap: make(map[string]string),
-	semaphore:   make(chan struct{}, MD5MaxConcurrent),
+	Conflict interfaces.ConflictPolicy `json:"conflict,omitempty"`
 }
 
-// getCacheKey 生成缓存键：fileName:size
-func getCacheKey(fileName string, size int64) string {
-	return fmt.Sprintf("%s:%d", fileName, size)
-}
-
-// GetMD5FromCache 从缓存获取MD5
-// 判断依据：文件名+文件大小（联合约束）
-func (mc *MD5Cache) GetMD5FromCache(filePath string, fileName string, size int64) (string, bool) {
-	cacheKey := getCacheKey(fileName, size)
-
-	mc.mutex.RLock()
-	entry, exists := mc.cache[cacheKey]
-	needUpdate := exists && entry.FilePath != filePath
-	mc.mutex.RUnlock()
-
-	if !exists {
-		return "", false
-	}
-
-	// 验证文件名和大小是否匹配
-	if entry.FileName != fileName || entry.Size != size {
-		return "", false
-	}
-
-	// 如果需要更新 filePath 映射（可能文件路径变了但文件名和大小相同）
-	if needUpdate {
-		mc.mutex.Lock()
-		// 再次检查，避免并发问题
-		if entry.FilePath != filePath {
-			// 删除旧的映射
-			if oldKey, ok := mc.filePathMap[entry.FilePath]; ok && oldKey == cacheKey {
-				delete(mc.filePathMap, entry.FilePath)
-			}
-			// 添加新映射
-			mc.filePathMap[filePath] = cacheKey
-			entry.FilePath = filePath
-		}
-		mc.mutex.Unlock()
-	} else {
-		// 确保映射存在
-		mc.mutex.Lock()
-		if _, ok := mc.filePathMap[filePath]; !ok {
-			mc.filePathMap[filePath] = cacheKey
-		}
-		mc.mutex.Unlock()
-	}
-
-	return entry.MD5, entry.Calculated
+// isSameOrChildPath 判断 filePath 是否为 basePath 本身或其子路径
+func isSameOrChildPath(filePath, basePath string) bool {
+	return filePath == basePath || strings.HasPrefix(filePath, basePath+string(filepath.Separator))
 }
 
-// SetMD5ToCache 设置MD5到缓存
-func (mc *MD5Cache) SetMD5ToCache(filePath, fileName, md5 string, size int64) {
-	mc.mutex.Lock()
-	defer mc.mutex.Unlock()
-
-	// 使用 fileName:size 作为缓存键
-	cacheKey := getCacheKey(fileName, size)
-	mc.cache[cacheKey] = &MD5CacheEntry{
-		MD5:         md5,
-		FilePath:    filePath,
-		FileName:    fileName,
-		Size:        size,
-		Calculated:  true,
-		Calculating: false,
+// SaveFile 保存文件到存储路径下的 targetDir 目录（自动创建父目录），支持断点重传
+// 完整上传写入同目录下的临时文件，写完后原子重命名，目标已存在时按 conflict 处理；
+// 续传（Range 起点大于0）从起点继续写入已有文件，不受 conflict 影响；
+// hashOnWrite 为 true 时边写边计算摘要并写入缓存
+func SaveFile(storagePath, targetDir string, file *multipart.FileHeader, rangeHeader string, conflict interfaces.ConflictPolicy, hashOnWrite bool) (interfaces.SaveResult, error) {
+	dest, rel, err := ResolvePath(storagePath, path.Join(targetDir, file.Filename))
+	if err != nil {
+		return interfaces.SaveResult{}, err
 	}
-	// 更新 filePath 映射
-	mc.filePathMap[filePath] = cacheKey
-}
-
-// SetCalculating 设置正在计算状态
-func (mc *MD5Cache) SetCalculating(filePath, fileName string, size int64) {
-	mc.mutex.Lock()
-	defer mc.mutex.Unlock()
-
-	// 使用 fileName:size 作为缓存键
-	cacheKey := getCacheKey(fileName, size)
-	mc.cache[cacheKey] = &MD5CacheEntry{
-		FilePath:    filePath,
-		FileName:    fileName,
-		Size:        size,
-		Calculated:  false,
-		Calculating: true,
-		Progress:    0.0,
+	if rel == "" {
+		return interfaces.SaveResult{}, interfaces.ErrInvalidPath
 	}
-	// 更新 filePath 映射
-	mc.filePathMap[filePath] = cacheKey
-}
-
-// UpdateProgress 更新计算进度
-func (mc *MD5Cache) UpdateProgress(filePath string, progress float64) {
-	mc.mutex.Lock()
-	defer mc.mutex.Unlock()
 
-	// 通过 filePath 映射找到缓存键
-	cacheKey, exists := mc.filePathMap[filePath]
-	if !exists {
-		return
+	// 处理 Range 头部信息
+	start, err := parseRangeStart(rangeHeader)
+	if err != nil {
+		return interfaces.SaveResult{}, err
 	}
-
-	if entry, exists := mc.cache[cacheKey]; exists {
-		entry.Progress = progress
+	if start > 0 {
+		return interfaces.SaveResult{Path: rel}, resumeFile(dest, file, start)
 	}
-}
-
-// SetError 设置计算错误
-func (mc *MD5Cache) SetError(filePath string, err error) {
-	mc.mutex.Lock()
-	defer mc.mutex.Unlock()
 
-	// 通过 filePath 映射找到缓存键
-	cacheKey, exists := mc.filePathMap[filePath]
-	if !exists {
-		return
+	if conflict == "" {
+		conflict = interfaces.DefaultConflictPolicy
 	}
-
-	if entry, exists := mc.cache[cacheKey]; exists {
-		entry.Calculating = false
-		entry.Error = err.Error()
+	final, skipped, err := resolveConflict(dest, rel, conflict)
+	result := interfaces.SaveResult{
+		Path:     conflictRelPath(rel, final),
+		Conflict: conflict,
+		Skipped:  skipped,
+		Renamed:  final != dest,
 	}
-}
-
-// GetProgress 获取计算进度
-func (mc *MD5Cache) GetProgress(filePath string) (float64, bool, string) {
-	mc.mutex.RLock()
-	defer mc.mutex.RUnlock()
-
-	// 通过 filePath 映射找到缓存键
-	cacheKey, exists := mc.filePathMap[filePath]
-	if !exists {
-		return 0, false, ""
+	dest = final
+	if err != nil || skipped {
+		return result, err
 	}
 
-	entry, exists := mc.cache[cacheKey]
-	if !exists {
-		return 0, false, ""
+	err = os.MkdirAll(filepath.Dir(dest), os.ModePerm)
+	if err != nil {
+		return result, err
 	}
 
-	return entry.Progress, entry.Calculating, entry.Error
-}
-
-// calculateFileMD5Chunked 分块计算大文件MD5（支持任意大小文件）
-func calculateFileMD5Chunked(filePath string, progressCallback func(float64)) (string, error) {
-	file, err := os.Open(filePath)
+	// 打开上传的文件
+	src, err := file.Open()
 	if err != nil {
-		return "", err
+		return result, err
 	}
-	defer file.Close()
+	defer src.Close()
 
-	// 获取文件大小
-	fileInfo, err := file.Stat()
+	tmp, err := createTempFile(dest)
 	if err != nil {
-		return "", err
+		return result, err
 	}
-	fileSize := fileInfo.Size()
-
-	hash := md5.New()
-	buf := make([]byte, MD5ChunkSize)
-	var totalRead int64
-
-	for {
-		n, err := file.Read(buf)
-		if n > 0 {
-			hash.Write(buf[:n])
-			totalRead += int64(n)
-
-			// 更新进度
-			if progressCallback != nil {
-				progress := float64(totalRead) / float64(fileSize)
-				progressCallback(progress)
-			}
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmp.Name())
 		}
+	}()
 
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return "", err
-		}
+	// 写入的内容就是整个文件，可以边写边计算摘要
+	// 使用4MB缓冲区进行复制，提高大文件传输性能
+	var w io.Writer = tmp
+	var hasher *hashing.MultiHash
+	if hashOnWrite {
+		hasher = newWriteHasher()
+		w = io.MultiWriter(tmp, hasher)
 	}
-
-	return hex.EncodeToString(hash.Sum(nil)), nil
-}
-
-// calculateFileMD5WithProgress 带进度回调的MD5计算
-func calculateFileMD5WithProgress(filePath string, progressCallback func(float64)) (string, error) {
-	// 对于大文件使用分块计算
-	fileInfo, err := os.Stat(filePath)
-	if err != nil {
-		return "", err
+	buf := make([]byte, 4*1024*1024)
+	if _, err = io.CopyBuffer(w, src, buf); err != nil {
+		return result, err
 	}
-
-	// 如果文件大于100MB，使用分块计算
-	if fileInfo.Size() > 100*1024*1024 {
-		return calculateFileMD5Chunked(filePath, progressCallback)
+	if err := commitTempFile(tmp, dest); err != nil {
+		return result, err
+	}
+	committed = true
+	if hasher != nil {
+		recordWrittenHashes(dest, hasher.Sums())
 	}
+	return result, nil
+}
 
-	// 小文件使用原有方法
-	return calculateFileMD5(filePath)
+// parseRangeStart 解析上传请求 Range 头部（bytes=start-end）的起点，空字符串返回0
+func parseRangeStart(rangeHeader string) (int64, error) {
+	if rangeHeader == "" {
+		return 0, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(rangeHeader, "bytes="), "-")
+	return strconv.ParseInt(parts[0], 10, 64)
 }
 
-// SaveFile 保存文件到指定路径，支持断点重传
-func SaveFile(storagePath string, file *multipart.FileHeader, rangeHeader string) error {
-	dest := filepath.Join(storagePath, file.Filename)
-	err := os.MkdirAll(storagePath, os.ModePerm)
+// resumeFile 从 start 处继续写入已有文件，用于断点续传
+func resumeFile(dest string, file *multipart.FileHeader, start int64) error {
+	err := os.MkdirAll(filepath.Dir(dest), os.ModePerm)
 	if err != nil {
 		return err
 	}
 
-	// 处理 Range 头部信息
-	var start int64
-	if rangeHeader != "" {
-		// 解析 Range 头部信息
-		parts := strings.Split(strings.TrimPrefix(rangeHeader, "bytes="), "-")
-		if len(parts) > 0 {
-			start, err = strconv.ParseInt(parts[0], 10, 64)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	// 打开上传的文件
 	src, err := file.Open()
 	if err != nil {
 		return err
 	}
 	defer src.Close()
 
-	// 打开目标文件，以追加模式写入
-	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
 
 	// 移动文件指针到指定位置
-	if start > 0 {
-		_, err = out.Seek(start, io.SeekStart)
-		if err != nil {
-			return err
+	if _, err = out.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	buf := make([]byte, 4*1024*1024)
+	if _, err = io.CopyBuffer(out, src, buf); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// resolveConflict 按冲突策略确定上传文件的保存路径，空策略使用 DefaultConflictPolicy
+// skipped 为 true 表示目标已存在且策略为 skip；策略为 fail 时返回包装 os.ErrExist 的错误
+func resolveConflict(dest, rel string, conflict interfaces.ConflictPolicy) (final string, skipped bool, err error) {
+	if conflict == "" {
+		conflict = interfaces.DefaultConflictPolicy
+	}
+	switch conflict {
+	case interfaces.ConflictOverwrite:
+		return dest, false, nil
+	case interfaces.ConflictRename:
+		return availablePath(dest, false), false, nil
+	case interfaces.ConflictSkip, interfaces.ConflictFail:
+		if _, err := os.Lstat(dest); err != nil {
+			return dest, false, nil
+		}
+		if conflict == interfaces.ConflictSkip {
+			return dest, true, nil
 		}
+		return dest, false, fmt.Errorf("%s: %w", rel, os.ErrExist)
+	default:
+		return dest, false, interfaces.ErrInvalidConflictPolicy
 	}
+}
 
-	// 将上传的文件内容复制到目标文件，使用更大的缓冲区提高性能
-	// 使用4MB缓冲区进行复制，提高大文件传输性能
-	buf := make([]byte, 4*1024*1024)
-	_, err = io.CopyBuffer(out, src, buf)
-	return err
+// conflictRelPath 返回按冲突策略确定的保存路径 dest 对应的相对路径，rel 为原来的相对路径
+func conflictRelPath(rel, dest string) string {
+	return path.Join(path.Dir(rel), filepath.Base(dest))
 }
 
 // SaveFileWithTimeout 保存文件到指定路径，支持超时控制
-func SaveFileWithTimeout(ctx context.Context, storagePath string, file *multipart.FileHeader, rangeHeader string) error {
+func SaveFileWithTimeout(ctx context.Context, storagePath, targetDir string, file *multipart.FileHeader, rangeHeader string, conflict interfaces.ConflictPolicy, hashOnWrite bool) (interfaces.SaveResult, error) {
 	// 创建一个带超时的上下文
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	errCh := make(chan error, 1)
+	type saveResult struct {
+		result interfaces.SaveResult
+		err    error
+	}
+	resultCh := make(chan saveResult, 1)
 	go func() {
-		errCh <- SaveFile(storagePath, file, rangeHeader)
+		result, err := SaveFile(storagePath, targetDir, file, rangeHeader, conflict, hashOnWrite)
+		resultCh <- saveResult{result, err}
 	}()
 
 	select {
-	case err := <-errCh:
-		return err
+	case r := <-resultCh:
+		return r.result, r.err
 	case <-ctx.Done():
-		return ctx.Err()
+		return interfaces.SaveResult{}, ctx.Err()
 	}
 }
 
-// SaveFileChunk 保存文件分片
-func SaveFileChunk(storagePath string, chunkInfo FileChunkInfo, file *multipart.FileHeader) error {
-	chunkDir := filepath.Join(storagePath, "chunks", chunkInfo.FileName)
-	err := os.MkdirAll(chunkDir, os.ModePerm)
+// SaveFileChunk 保存文件分片，所有分片到达后合并并返回合并报告
+// 提供分片校验值时先校验再接收，校验失败的分片不会保存，客户端只需重传该分片；
+// 分片数、分片序号、分片大小或文件大小不合法时返回 ErrChunkOutOfRange
+func SaveFileChunk(storagePath string, chunkInfo FileChunkInfo, file *multipart.FileHeader) (*interfaces.ChunkMergeReport, error) {
+	if chunkInfo.TotalChunk <= 0 || chunkInfo.ChunkIndex < 0 || chunkInfo.ChunkIndex >= chunkInfo.TotalChunk ||
+		chunkInfo.ChunkSize <= 0 || chunkInfo.TotalSize < 0 {
+		return nil, interfaces.ErrChunkOutOfRange
+	}
+	targetFile, rel, err := ResolvePath(storagePath, chunkInfo.FileName)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if rel == "" {
+		return nil, interfaces.ErrInvalidPath
+	}
+
+	// 目标已存在时，skip 和 fail 策略不必接收分片
+	if _, skipped, err := resolveConflict(targetFile, rel, chunkInfo.Conflict); err != nil {
+		return nil, err
+	} else if skipped {
+		return skippedMergeReport(chunkInfo.TotalChunk, rel), nil
 	}
 
-	chunkPath := filepath.Join(chunkDir, fmt.Sprintf("%s_%d", chunkInfo.FileName, chunkInfo.ChunkIndex))
+	var chunkHash hash.Hash
+	if chunkInfo.ChunkHash != "" {
+		chunkHash, err = newChunkHash(chunkInfo.ChunkHashAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		if chunkInfo.ChunkHashAlgorithm == "" {
+			chunkInfo.ChunkHashAlgorithm = "md5"
+		}
+	}
+
+	// 分片保存在内部数据目录下，不出现在文件列表中
+	chunkDir := filepath.Join(storagePath, InternalDirName, ChunksDirName, filepath.FromSlash(rel))
+	err = os.MkdirAll(chunkDir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	baseName := filepath.Base(targetFile)
+	chunkPath := filepath.Join(chunkDir, fmt.Sprintf("%s_%d", baseName, chunkInfo.ChunkIndex))
 
 	// 打开上传的分片文件
 	src, err := file.Open()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer src.Close()
 
-	// 创建分片文件
-	chunkFile, err := os.Create(chunkPath)
+	// 创建分片文件，先写入临时文件，写完后再重命名，避免并行请求合并写了一半的分片
+	partPath := chunkPath + ".part"
+	chunkFile, err := os.Create(partPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer chunkFile.Close()
 
+	var dst io.Writer = chunkFile
+	if chunkHash != nil {
+		dst = io.MultiWriter(chunkFile, chunkHash)
+	}
+
 	// 复制分片内容，使用优化的缓冲区
 	buf := make([]byte, ChunkBufferSize)
-	_, err = io.CopyBuffer(chunkFile, src, buf)
+	_, err = io.CopyBuffer(dst, src, buf)
 	if err != nil {
-		return err
+		os.Remove(partPath)
+		return nil, err
 	}
 
-	// 检查是否所有分片都已上传完成
-	if chunkInfo.ChunkIndex == chunkInfo.TotalChunk-1 {
-		// 合并所有分片
-		err = mergeFileChunks(chunkDir, filepath.Join(storagePath, chunkInfo.FileName), chunkInfo.TotalChunk)
-		if err != nil {
-			return err
-		}
+	if err := chunkFile.Close(); err != nil {
+		os.Remove(partPath)
+		return nil, err
+	}
 
-		// 验证文件完整性
-		md5sum, err := calculateFileMD5(filepath.Join(storagePath, chunkInfo.FileName))
-		if err != nil {
-			return err
+	// 校验分片，同时保存校验值供合并时再次校验；不带校验值的重传会清除旧的校验值
+	sumPath := chunkPath + chunkSumSuffix
+	if chunkHash != nil {
+		sum := hex.EncodeToString(chunkHash.Sum(nil))
+		if sum != strings.ToLower(chunkInfo.ChunkHash) {
+			os.Remove(partPath)
+			recordChunkFailure(chunkDir, chunkInfo.ChunkIndex,
+				fmt.Sprintf("upload: expected %s %s, got %s", chunkInfo.ChunkHashAlgorithm, chunkInfo.ChunkHash, sum))
+			return nil, fmt.Errorf("chunk %d: %w", chunkInfo.ChunkIndex, interfaces.ErrChunkChecksumMismatch)
 		}
-
-		if md5sum != chunkInfo.MD5 {
-			// MD5校验失败，删除文件
-			os.Remove(filepath.Join(storagePath, chunkInfo.FileName))
-			return fmt.Errorf("file integrity check failed: expected %s, got %s", chunkInfo.MD5, md5sum)
+		if err := os.WriteFile(sumPath, []byte(chunkInfo.ChunkHashAlgorithm+" "+sum), 0644); err != nil {
+			os.Remove(partPath)
+			return nil, err
 		}
+	} else {
+		os.Remove(sumPath)
 	}
 
-	return nil
-}
+	if err := os.Rename(partPath, chunkPath); err != nil {
+		return nil, err
+	}
 
-// mergeFileChunks 合并文件分片
-func mergeFileChunks(chunkDir, targetFile string, totalChunk int) error {
-	target, err := os.Create(targetFile)
+	// 分片可能乱序或并行到达，只有所有分片都已上传时才合并
+	if !allChunksPresent(chunkDir, baseName, chunkInfo.TotalChunk) {
+		return nil, nil
+	}
+
+	// 同一文件的合并串行执行，后到达的请求发现分片已被合并时直接返回
+	mu, _ := chunkMergeLocks.LoadOrStore(chunkDir, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+	defer chunkMergeLocks.Delete(chunkDir)
+	if !allChunksPresent(chunkDir, baseName, chunkInfo.TotalChunk) {
+		return nil, nil
+	}
+
+	// 分片上传期间目标可能已被创建，合并前再按冲突策略确定保存路径
+	conflict := chunkInfo.Conflict
+	if conflict == "" {
+		conflict = interfaces.DefaultConflictPolicy
+	}
+	dest, skipped, err := resolveConflict(targetFile, rel, conflict)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if skipped {
+		os.RemoveAll(chunkDir)
+		return skippedMergeReport(chunkInfo.TotalChunk, rel), nil
+	}
+
+	// 合并所有分片
+	err = os.MkdirAll(filepath.Dir(dest), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := mergeFileChunks(chunkDir, baseName, dest, chunkInfo.TotalChunk, chunkInfo.TotalSize, chunkInfo.MD5)
+	if report != nil {
+		report.Path = conflictRelPath(rel, dest)
+		report.Conflict = conflict
+		report.Renamed = dest != targetFile
+	}
+	return report, err
+}
+
+// skippedMergeReport 返回目标已存在、按 skip 策略跳过时的合并报告
+func skippedMergeReport(totalChunk int, rel string) *interfaces.ChunkMergeReport {
+	return &interfaces.ChunkMergeReport{
+		TotalChunks:  totalChunk,
+		FailedChunks: []interfaces.ChunkFailure{},
+		RetryChunks:  []int{},
+		Path:         rel,
+		Conflict:     interfaces.ConflictSkip,
+		Skipped:      true,
 	}
-	defer target.Close()
+}
 
-	// 使用1MB缓冲区提高合并性能
-	buf := make([]byte, 1024*1024)
+// chunkMergeLocks 分片目录 -> 合并互斥锁
+var chunkMergeLocks sync.Map
 
+// allChunksPresent 检查分片目录中是否已包含全部分片
+func allChunksPresent(chunkDir, baseName string, totalChunk int) bool {
 	for i := 0; i < totalChunk; i++ {
-		chunkPath := filepath.Join(chunkDir, fmt.Sprintf("*_%d", i))
-		matches, err := filepath.Glob(chunkPath)
-		if err != nil {
-			return err
+		if _, err := os.Stat(filepath.Join(chunkDir, fmt.Sprintf("%s_%d", baseName, i))); err != nil {
+			return false
 		}
+	}
+	return true
+}
+
+// receivedChunks 返回文件已上传的分片数，用于推送上传进度
+func receivedChunks(storagePath, fileName string, totalChunk int) int {
+	targetFile, rel, err := ResolvePath(storagePath, fileName)
+	if err != nil || rel == "" {
+		return 0
+	}
+	chunkDir := filepath.Join(storagePath, InternalDirName, ChunksDirName, filepath.FromSlash(rel))
+	baseName := filepath.Base(targetFile)
 
-		if len(matches) == 0 {
-			return fmt.Errorf("%s: chunk %d", ErrChunkNotFound, i)
+	received := 0
+	for i := 0; i < totalChunk; i++ {
+		if _, err := os.Stat(filepath.Join(chunkDir, fmt.Sprintf("%s_%d", baseName, i))); err == nil {
+			received++
 		}
+	}
+	return received
+}
 
-		chunkFile, err := os.Open(matches[0])
+// mergeWorkers 并行合并分片的协程数
+const mergeWorkers = 4
+
+// mergeFileChunks 合并文件分片
+// 分片按大小计算各自的偏移，并行写入目标文件同目录下的临时文件，带校验值的分片在写入时再次校验；
+// 全部写完后同步到磁盘、校验整个文件的MD5（expectedMD5 为空时跳过），再原子重命名覆盖目标文件。
+// 任何一步失败时目标文件保持不变；发现损坏的分片时删除这些分片并保留其余分片，返回的报告列出需要重传的分片
+func mergeFileChunks(chunkDir, baseName, targetFile string, totalChunk int, totalSize int64, expectedMD5 string) (*interfaces.ChunkMergeReport, error) {
+	chunkPaths := make([]string, totalChunk)
+	offsets := make([]int64, totalChunk)
+	var size int64
+	for i := range chunkPaths {
+		chunkPaths[i] = filepath.Join(chunkDir, fmt.Sprintf("%s_%d", baseName, i))
+		info, err := os.Stat(chunkPaths[i])
 		if err != nil {
-			return err
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("%s: chunk %d", ErrChunkNotFound, i)
+			}
+			return nil, err
 		}
+		offsets[i] = size
+		size += info.Size()
+	}
+	if size != totalSize {
+		return nil, fmt.Errorf("chunks total %d bytes, expected %d: %w", size, totalSize, interfaces.ErrChunkSizeMismatch)
+	}
 
-		_, err = io.CopyBuffer(target, chunkFile, buf)
-		chunkFile.Close()
-		if err != nil {
-			return err
+	tmp, err := createTempFile(targetFile)
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
 		}
+	}()
+	if err := tmp.Truncate(totalSize); err != nil {
+		return nil, err
 	}
 
-	// 删除分片目录
-	os.RemoveAll(chunkDir)
-	return nil
-}
+	report := &interfaces.ChunkMergeReport{
+		TotalChunks: totalChunk,
+		RetryChunks: []int{},
+	}
 
-// DownloadFile 从指定路径下载文件，支持断点重传
-func DownloadFile(c *gin.Context, storagePath, filename, rangeHeader string) error {
-	file := filepath.Join(storagePath, filename)
-	fileInfo, err := os.Stat(file)
-	if os.IsNotExist(err) {
-		return err
+	// 分片互不重叠，多个协程可以同时按偏移写入
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	indexes := make(chan int)
+	for w := 0; w < mergeWorkers && w < totalChunk; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// 使用1MB缓冲区提高合并性能
+			buf := make([]byte, 1024*1024)
+			for i := range indexes {
+				verified, corrupt, err := writeChunkAt(tmp, chunkPaths[i], offsets[i], buf)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					if firstErr == nil {
+						firstErr = err
+					}
+				case corrupt != "":
+					// 分片在磁盘上损坏，删除后等待客户端重传
+					os.Remove(chunkPaths[i])
+					os.Remove(chunkPaths[i] + chunkSumSuffix)
+					recordChunkFailure(chunkDir, i, "merge: "+corrupt)
+					report.RetryChunks = append(report.RetryChunks, i)
+				case verified:
+					report.VerifiedChunks++
+				}
+				mu.Unlock()
+			}
+		}()
 	}
-	if err != nil {
-		return err
+	for i := 0; i < totalChunk; i++ {
+		indexes <- i
 	}
+	close(indexes)
+	wg.Wait()
 
-	// 处理Range头信息
-	if rangeHeader != "" {
-		start, end, err := parseRangeHeader(rangeHeader)
-		if err != nil {
-			return err
-		}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	report.FailedChunks = readChunkFailures(chunkDir)
+	if len(report.RetryChunks) > 0 {
+		sort.Ints(report.RetryChunks)
+		return report, fmt.Errorf("chunks %v: %w", report.RetryChunks, interfaces.ErrChunkChecksumMismatch)
+	}
 
-		// 打开文件
-		f, err := os.Open(file)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
+	// 分片并行写入，无法边写边计算整个文件的摘要；趁刚写入的数据还在页缓存中顺序读取一遍，
+	// 同时用于完整性校验和填充摘要缓存，之后列表和校验不必再读取文件
+	hasher := newWriteHasher()
+	if _, err := io.CopyBuffer(hasher, io.NewSectionReader(tmp, 0, totalSize), make([]byte, DefaultBufferSize)); err != nil {
+		return report, err
+	}
+	sums := hasher.Sums()
 
-		// 获取文件大小
-		fileSize := fileInfo.Size()
+	// 验证文件完整性
+	if expectedMD5 != "" && sums[hashing.MD5] != strings.ToLower(expectedMD5) {
+		// 分片各自完整但拼接结果不一致，无法确定是哪个分片，全部丢弃
+		os.RemoveAll(chunkDir)
+		return report, fmt.Errorf("file integrity check failed: expected %s, got %s", expectedMD5, sums[hashing.MD5])
+	}
 
-		// 设置响应头
-		c.Writer.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileSize))
-		c.Writer.Header().Set("Accept-Ranges", "bytes")
-		c.Writer.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+	if err := commitTempFile(tmp, targetFile); err != nil {
+		return report, err
+	}
+	committed = true
+	recordWrittenHashes(targetFile, sums)
 
-		// 检查客户端是否已经断开连接
-		if c.Request.Context().Err() != nil {
-			return c.Request.Context().Err()
-		}
+	// 删除分片目录
+	os.RemoveAll(chunkDir)
+	return report, nil
+}
 
-		c.Writer.WriteHeader(http.StatusPartialContent)
+// writeChunkAt 将分片写入 out 的 offset 处，分片有校验值时同时校验
+// verified 表示分片通过了校验，corrupt 不为空时为校验失败的原因
+func writeChunkAt(out io.WriterAt, chunkPath string, offset int64, buf []byte) (verified bool, corrupt string, err error) {
+	chunkFile, err := os.Open(chunkPath)
+	if err != nil {
+		return false, "", err
+	}
+	defer chunkFile.Close()
 
-		// 移动文件指针到指定位置
-		_, err = f.Seek(int64(start), io.SeekStart)
-		if err != nil {
-			return err
+	var dst io.Writer = io.NewOffsetWriter(out, offset)
+	algorithm, expected := readChunkChecksum(chunkPath)
+	var chunkHash hash.Hash
+	if expected != "" {
+		if chunkHash, err = newChunkHash(algorithm); err == nil {
+			dst = io.MultiWriter(dst, chunkHash)
 		}
+	}
 
-		// 发送文件内容并检查连接状态
-		return copyWithCancel(c.Request.Context(), c.Writer, f, int64(end-start+1))
+	if _, err := io.CopyBuffer(dst, chunkFile, buf); err != nil {
+		return false, "", err
+	}
+	if chunkHash == nil {
+		return false, "", nil
 	}
+	if sum := hex.EncodeToString(chunkHash.Sum(nil)); sum != expected {
+		return false, fmt.Sprintf("expected %s %s, got %s", algorithm, expected, sum), nil
+	}
+	return true, "", nil
+}
 
-	// 对于完整文件下载，使用流式传输避免内存问题
-	// 检查客户端是否已经断开连接
-	if c.Request.Context().Err() != nil {
-		return c.Request.Context().Err()
+// DownloadFile 从指定路径下载文件，按 RFC 9110 处理 Range 和 If-Range
+// 支持单个范围、多范围（multipart/byteranges）、后缀范围和到结尾的范围，无法满足时返回 416
+func DownloadFile(c *gin.Context, storagePath, filename, rangeHeader string) error {
+	file, _, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return err
+	}
+	fileInfo, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if fileInfo.IsDir() {
+		return interfaces.ErrInvalidPath
 	}
 
 	// 打开文件
@@ -514,18 +617,69 @@ func DownloadFile(c *gin.Context, storagePath, filename, rangeHeader string) err
 	}
 	defer f.Close()
 
+	return serveFile(c, f, filepath.Base(filename), fileInfo.Size(), fileInfo.ModTime(), fileETag(fileInfo), rangeHeader)
+}
+
+// serveFile 将已打开的文件作为附件发送，处理条件请求、Range 和 If-Range
+func serveFile(c *gin.Context, f *os.File, name string, size int64, modTime time.Time, etag, rangeHeader string) error {
+	ctx := c.Request.Context()
+	contentType := "application/octet-stream"
+	headOnly := c.Request.Method == http.MethodHead
+
 	// 设置响应头
-	c.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-	c.Writer.Header().Set("Content-Type", "application/octet-stream")
-	c.Writer.Header().Set("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))
+	header := c.Writer.Header()
+	header.Set("Accept-Ranges", "bytes")
+	setValidators(header, etag, modTime)
+	if status := checkConditional(c.Request, etag, modTime); status != 0 {
+		c.Writer.WriteHeader(status)
+		return nil
+	}
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+
+	// If-Range 不成立时忽略 Range，返回完整文件
+	var ranges []byteRange
+	var err error
+	if rangeHeader != "" && ifRangeMatches(c.GetHeader("If-Range"), etag, modTime) {
+		ranges, err = parseRangeHeader(rangeHeader, size)
+		if errors.Is(err, errUnsatisfiableRange) {
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			c.Writer.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return nil
+		}
+	}
 
 	// 检查客户端是否已经断开连接
-	if c.Request.Context().Err() != nil {
-		return c.Request.Context().Err()
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
 
-	// Copy文件内容到响应并检查连接状态
-	return copyWithCancel(c.Request.Context(), c.Writer, f, fileInfo.Size())
+	switch len(ranges) {
+	case 0:
+		// 对于完整文件下载，使用流式传输避免内存问题
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Length", strconv.FormatInt(size, 10))
+		c.Writer.WriteHeader(http.StatusOK)
+		if headOnly {
+			return nil
+		}
+		return copyWithCancel(ctx, c.Writer, f, size)
+	case 1:
+		r := ranges[0]
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Range", r.contentRange(size))
+		header.Set("Content-Length", strconv.FormatInt(r.length(), 10))
+		c.Writer.WriteHeader(http.StatusPartialContent)
+		if headOnly {
+			return nil
+		}
+		return copyWithCancel(ctx, c.Writer, io.NewSectionReader(f, r.start, r.length()), r.length())
+	default:
+		var body io.ReaderAt = f
+		if headOnly {
+			body = nil
+		}
+		return writeMultipartRanges(ctx, c.Writer, body, ranges, size, contentType)
+	}
 }
 
 // copyWithCancel 带取消功能的复制函数，支持大文件长时间传输
@@ -586,17 +740,38 @@ func DownloadFileWithTimeout(ctx context.Context, c *gin.Context, storagePath, f
 
 // DownloadFileChunk 下载文件分片，支持多线程分片下载
 func DownloadFileChunk(c *gin.Context, storagePath, filename string, chunkIndex, chunkSize int64) error {
-	file := filepath.Join(storagePath, filename)
+	file, _, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return err
+	}
 	fileInfo, err := os.Stat(file)
 	if err != nil {
 		return err
 	}
 
-	start := chunkIndex * chunkSize
-	end := start + chunkSize - 1
+	if fileInfo.IsDir() {
+		return interfaces.ErrInvalidPath
+	}
+
+	// 分片与完整下载使用相同的实体标签，客户端可以据此判断分片是否来自同一版本的文件
+	etag := fileETag(fileInfo)
+	setValidators(c.Writer.Header(), etag, fileInfo.ModTime())
+	if status := checkConditional(c.Request, etag, fileInfo.ModTime()); status != 0 {
+		c.Writer.WriteHeader(status)
+		return nil
+	}
+
+	// 与 Range 请求一致，分片不在文件范围内（包括负数和溢出的参数）时返回 416
 	fileSize := fileInfo.Size()
+	if chunkIndex < 0 || chunkSize <= 0 || fileSize == 0 || chunkIndex > (fileSize-1)/chunkSize {
+		c.Writer.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", fileSize))
+		c.Writer.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return nil
+	}
 
-	if end >= fileSize {
+	start := chunkIndex * chunkSize
+	end := start + chunkSize - 1
+	if end >= fileSize || end < start {
 		end = fileSize - 1
 	}
 
@@ -619,202 +794,343 @@ func DownloadFileChunk(c *gin.Context, storagePath, filename string, chunkIndex,
 
 	c.Writer.WriteHeader(http.StatusPartialContent)
 
-	// 移动文件指针到指定位置
-	_, err = f.Seek(start, io.SeekStart)
-	if err != nil {
-		return err
-	}
+	// 只发送分片范围内的内容，并检查连接状态
+	return copyWithCancel(c.Request.Context(), c.Writer, io.NewSectionReader(f, start, end-start+1), end-start+1)
+}
+
+// ListOptions 目录列表查询条件
+type ListOptions struct {
+	Path   string // 相对路径，空字符串表示根目录
+	Depth  int    // 层级数，1 只列当前目录，0 不限
+	Limit  int    // 每页条目数，0 不分页
+	Cursor string // 分页游标
+	Sort   string // name、size、mtime
+	Order  string // asc、desc
+}
 
-	// 发送文件内容并检查连接状态
-	return copyWithCancel(c.Request.Context(), c.Writer, f, end-start+1)
+// FileListPage 一页目录列表
+type FileListPage struct {
+	Files      []FileMetadata
+	NextCursor string
+	Total      int
 }
 
-// parseRangeHeader 解析Range头信息
-func parseRangeHeader(rangeHeader string) (int, int, error) {
-	parts := strings.Split(rangeHeader, "=")[1]
-	rangeParts := strings.Split(parts, "-")
-	start, err := strconv.Atoi(rangeParts[0])
+// dirEntry 目录条目及其文件信息
+type dirEntry struct {
+	name string
+	info os.FileInfo
+}
+
+// ListFiles 列出指定目录下的文件和文件夹
+// 只读取请求的目录层级（Depth 控制子目录展开层数），并且只为返回页中的文件触发MD5计算
+func ListFiles(storagePath string, opts ListOptions) (FileListPage, error) {
+	dirPath, relativePath, err := ResolvePath(storagePath, opts.Path)
 	if err != nil {
-		return 0, 0, err
+		return FileListPage{}, err
+	}
+
+	// 根目录不存在时自动创建
+	if relativePath == "" {
+		if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
+			return FileListPage{}, err
+		}
 	}
 
-	// 如果没有结束位置，则默认到最后
-	if rangeParts[1] == "" {
-		// 我们需要获取文件大小来确定结束位置，但在这里我们简单处理
-		return 0, 0, fmt.Errorf("range end position required")
+	entries, err := readDirEntries(dirPath, relativePath == "")
+	if err != nil {
+		return FileListPage{}, err
 	}
+	sortDirEntries(entries, opts.Sort, opts.Order)
 
-	end, err := strconv.Atoi(rangeParts[1])
+	offset, err := decodeListCursor(opts.Cursor)
 	if err != nil {
-		return 0, 0, err
+		return FileListPage{}, err
+	}
+	if offset > len(entries) {
+		offset = len(entries)
 	}
-	return start, end, nil
+
+	end := len(entries)
+	if opts.Limit > 0 && offset+opts.Limit < end {
+		end = offset + opts.Limit
+	}
+
+	page := FileListPage{
+		Files: make([]FileMetadata, 0, end-offset),
+		Total: len(entries),
+	}
+	for _, entry := range entries[offset:end] {
+		page.Files = append(page.Files, buildFileMetadata(dirPath, relativePath, entry, opts))
+	}
+	if end < len(entries) {
+		page.NextCursor = encodeListCursor(end)
+	}
+
+	return page, nil
 }
 
-// ListFiles 列出存储路径下的所有文件和文件夹（支持递归）
-func ListFiles(storagePath string) ([]FileMetadata, error) {
-	return listFilesRecursive(storagePath, storagePath, "")
+// listChildren 列出子目录的全部内容（不分页），depth 为剩余层级数
+func listChildren(dirPath, relativePath string, opts ListOptions) []FileMetadata {
+	entries, err := readDirEntries(dirPath, false)
+	if err != nil {
+		// 如果无法读取子文件夹，仍然添加文件夹但无子项
+		return []FileMetadata{}
+	}
+	sortDirEntries(entries, opts.Sort, opts.Order)
+
+	children := make([]FileMetadata, 0, len(entries))
+	for _, entry := range entries {
+		children = append(children, buildFileMetadata(dirPath, relativePath, entry, opts))
+	}
+	return children
 }
 
-// listFilesRecursive 递归列出文件和文件夹
-func listFilesRecursive(basePath, currentPath, relativePath string) ([]FileMetadata, error) {
-	var files []FileMetadata
+// buildFileMetadata 生成单个条目的元数据，目录按剩余层级展开子项，文件查询或触发MD5计算
+func buildFileMetadata(dirPath, relativePath string, entry dirEntry, opts ListOptions) FileMetadata {
+	filePath := filepath.Join(dirPath, entry.name)
+	fileRelativePath := filepath.ToSlash(filepath.Join(relativePath, entry.name))
+
+	if !entry.info.IsDir() {
+		return FileMetadata{
+			Name:    entry.name,
+			Path:    fileRelativePath,
+			Size:    entry.info.Size(),
+			ModTime: entry.info.ModTime(),
+			MD5:     cachedMD5OrSchedule(filePath, entry.info),
+			IsDir:   false,
+		}
+	}
+
+	file := FileMetadata{
+		Name:    entry.name,
+		Path:    fileRelativePath,
+		Size:    0,
+		ModTime: entry.info.ModTime(),
+		IsDir:   true,
+	}
 
-	err := os.MkdirAll(currentPath, os.ModePerm)
-	if err != nil {
-		return nil, err
+	// Depth 为 1 时不展开子目录，0 表示不限层级
+	if opts.Depth != 1 {
+		childOpts := opts
+		if opts.Depth > 1 {
+			childOpts.Depth = opts.Depth - 1
+		}
+		file.Children = listChildren(filePath, fileRelativePath, childOpts)
 	}
 
-	entries, err := os.ReadDir(currentPath)
+	return file
+}
+
+// readDirEntries 读取目录条目，跳过无法读取信息的条目以及根目录下的内部数据目录
+func readDirEntries(dirPath string, isRoot bool) ([]dirEntry, error) {
+	entries, err := os.ReadDir(dirPath)
 	if err != nil {
 		return nil, err
 	}
 
+	result := make([]dirEntry, 0, len(entries))
 	for _, entry := range entries {
+		if isRoot && entry.Name() == InternalDirName {
+			continue
+		}
+		// 正在写入的临时文件不对外展示
+		if isTempFileName(entry.Name()) {
+			continue
+		}
+
 		info, err := entry.Info()
 		if err != nil {
 			continue // 跳过无法读取信息的条目
 		}
+		result = append(result, dirEntry{name: entry.Name(), info: info})
+	}
+	return result, nil
+}
 
-		filePath := filepath.Join(currentPath, info.Name())
-		fileRelativePath := filepath.Join(relativePath, info.Name())
-		if relativePath == "" {
-			fileRelativePath = info.Name()
+// sortDirEntries 对目录条目排序：目录始终在前，其余按指定字段排序，字段相同时按名称排序
+func sortDirEntries(entries []dirEntry, sortBy, order string) {
+	desc := order == "desc"
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.info.IsDir() != b.info.IsDir() {
+			return a.info.IsDir()
 		}
 
-		if entry.IsDir() {
-			// 递归获取子文件夹内容
-			children, err := listFilesRecursive(basePath, filePath, fileRelativePath)
-			if err != nil {
-				// 如果无法读取子文件夹，仍然添加文件夹但无子项
-				children = []FileMetadata{}
-			}
+		var cmp int
+		switch sortBy {
+		case "size":
+			cmp = compareInt64(a.info.Size(), b.info.Size())
+		case "mtime":
+			cmp = compareInt64(a.info.ModTime().UnixNano(), b.info.ModTime().UnixNano())
+		}
+		if cmp == 0 {
+			cmp = strings.Compare(a.name, b.name)
+		}
 
-			file := FileMetadata{
-				Name:     info.Name(),
-				Path:     fileRelativePath,
-				Size:     0,
-				ModTime:  info.ModTime(),
-				IsDir:    true,
-				Children: children,
-			}
-			files = append(files, file)
-		} else {
-			fileName := info.Name()
-
-			// 先尝试从缓存获取MD5（使用文件路径+文件名+大小作为判断依据）
-			md5sum, calculated := md5Cache.GetMD5FromCache(filePath, fileName, info.Size())
-
-			// 如果缓存中没有或文件已修改，异步计算MD5
-			if !calculated {
-				// 检查是否已经在计算中
-				_, calculating, _ := md5Cache.GetProgress(filePath)
-				if !calculating {
-					// 设置正在计算状态
-					md5Cache.SetCalculating(filePath, fileName, info.Size())
-
-					// 异步计算MD5（不阻塞列表响应，支持任意大小文件）
-					go func(filePath, fileName string, size int64) {
-						// 获取信号量，控制并发数
-						md5Cache.semaphore <- struct{}{}
-						defer func() { <-md5Cache.semaphore }()
-
-						// 使用带进度回调的计算方法
-						md5, err := calculateFileMD5WithProgress(filePath, func(progress float64) {
-							md5Cache.UpdateProgress(filePath, progress)
-						})
-
-						if err != nil {
-							// 计算失败，设置错误状态
-							md5Cache.SetError(filePath, err)
-							return
-						}
-
-						// 计算成功，更新缓存
-						md5Cache.SetMD5ToCache(filePath, fileName, md5, size)
-					}(filePath, fileName, info.Size())
-				}
+		if desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+}
 
-				// 列表响应中不包含MD5，但会异步计算
-				md5sum = ""
-			}
+// compareInt64 比较两个整数，返回 -1、0 或 1
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
 
-			file := FileMetadata{
-				Name:    info.Name(),
-				Path:    fileRelativePath,
-				Size:    info.Size(),
-				ModTime: info.ModTime(),
-				MD5:     md5sum,
-				IsDir:   false,
-			}
-			files = append(files, file)
-		}
+// encodeListCursor 将偏移量编码为不透明的分页游标
+func encodeListCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// decodeListCursor 解码分页游标，空游标表示从头开始
+func decodeListCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
 	}
 
-	return files, nil
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, interfaces.ErrInvalidCursor
+	}
+	offset, err := strconv.Atoi(string(data))
+	if err != nil || offset < 0 {
+		return 0, interfaces.ErrInvalidCursor
+	}
+	return offset, nil
 }
 
-// CheckFileExists 检查文件是否存在
-func CheckFileExists(storagePath string, filename string) error {
-	file := filepath.Join(storagePath, filename)
-	_, err := os.Stat(file)
-	return err
+// cachedMD5OrSchedule 从缓存获取文件MD5，缓存中没有时提交后台计算任务并返回空字符串
+func cachedMD5OrSchedule(filePath string, info os.FileInfo) string {
+	// 先尝试从缓存获取MD5（文件路径+大小+修改时间+inode 一致才有效）
+	md5sum, calculated := md5Cache.GetMD5FromCache(filePath, info)
+	if calculated {
+		return md5sum
+	}
+
+	// 列表响应中不包含MD5，但会在后台以低优先级计算（不阻塞列表响应，支持任意大小文件）
+	scheduleMD5(filePath, interfaces.JobPriorityLow)
+	return ""
 }
 
-// calculateFileMD5 计算文件的MD5值
-func calculateFileMD5(filePath string) (string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", err
+// scheduleMD5 提交计算文件MD5的后台任务，同一文件已有未结束的任务时只提升优先级
+// 返回任务服务和任务，没有配置任务服务时返回的任务服务为空
+func scheduleMD5(filePath string, priority int) (interfaces.JobService, interfaces.Job, error) {
+	md5Cache.mutex.RLock()
+	jobs, target := md5Cache.jobs, md5Cache.relativePathLocked(filePath)
+	md5Cache.mutex.RUnlock()
+	if jobs == nil {
+		return nil, interfaces.Job{}, nil
 	}
-	defer file.Close()
 
-	hash := md5.New()
-	// 使用1MB缓冲区提高MD5计算性能
-	buf := make([]byte, 1024*1024)
-	if _, err := io.CopyBuffer(hash, file, buf); err != nil {
-		return "", err
+	job, err := jobs.Submit(interfaces.JobRequest{
+		Type:     interfaces.JobTypeMD5,
+		Target:   target,
+		Priority: priority,
+		Run:      md5Job(filePath),
+	})
+	return jobs, job, err
+}
+
+// md5Job 返回计算文件MD5并写入缓存的任务函数
+func md5Job(filePath string) interfaces.JobFunc {
+	return func(ctx context.Context, progress func(float64)) error {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return err
+		}
+		// 排队期间可能已经由其他请求算好
+		if _, calculated := md5Cache.GetMD5FromCache(filePath, info); calculated {
+			return nil
+		}
+
+		stamp := stampOf(info)
+		md5Cache.SetCalculating(filePath, stamp)
+		sums, err := calculateFileHashes(ctx, filePath, []string{hashing.MD5}, func(p float64) {
+			md5Cache.UpdateProgress(filePath, p)
+			progress(p)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				md5Cache.CancelCalculating(filePath)
+			} else {
+				md5Cache.SetError(filePath, err)
+			}
+			return err
+		}
+
+		md5Cache.SetMD5ToCache(filePath, sums[hashing.MD5], stamp)
+		return nil
 	}
+}
 
-	return hex.EncodeToString(hash.Sum(nil)), nil
+// CheckFileExists 检查文件是否存在
+func CheckFileExists(storagePath string, filename string) error {
+	file, _, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(file)
+	return err
 }
 
 // GetFileMD5 获取文件的MD5值（带缓存，支持大文件）
-func GetFileMD5(storagePath, filename string) (string, error) {
-	filePath := filepath.Join(storagePath, filename)
+// 缓存中没有时以高优先级提交计算任务（已有任务时提升其优先级）并等待结果，ctx 结束时停止等待，任务继续执行
+func GetFileMD5(ctx context.Context, storagePath, filename string) (string, error) {
+	filePath, _, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return "", err
+	}
 
 	// 获取文件信息
 	info, err := os.Stat(filePath)
 	if err != nil {
 		return "", err
 	}
+	if info.IsDir() {
+		return "", interfaces.ErrInvalidPath
+	}
 
-	fileName := filepath.Base(filePath)
-
-	// 先尝试从缓存获取（使用文件路径+文件名+大小作为判断依据）
-	md5sum, calculated := md5Cache.GetMD5FromCache(filePath, fileName, info.Size())
+	// 先尝试从缓存获取（文件路径+大小+修改时间+inode 一致才有效）
+	md5sum, calculated := md5Cache.GetMD5FromCache(filePath, info)
 	if calculated {
 		return md5sum, nil
 	}
 
-	// 检查是否正在计算中
-	progress, calculating, errorMsg := md5Cache.GetProgress(filePath)
-	if calculating {
-		return "", fmt.Errorf("%s: progress %.1f%%", ErrMD5InProgress, progress*100)
+	jobs, job, err := scheduleMD5(filePath, interfaces.JobPriorityHigh)
+	if err != nil {
+		return "", err
 	}
-
-	if errorMsg != "" {
-		return "", fmt.Errorf("MD5 calculation failed: %s", errorMsg)
+	if jobs == nil {
+		// 没有任务服务时在当前请求中计算
+		if err := md5Job(filePath)(ctx, func(float64) {}); err != nil {
+			return "", err
+		}
+	} else {
+		job, err = jobs.Wait(ctx, job.ID)
+		if err != nil {
+			return "", err
+		}
+		if job.State != interfaces.JobSucceeded {
+			return "", fmt.Errorf("MD5 calculation %s: %s", job.State, job.Error)
+		}
 	}
 
-	// 缓存中没有且未在计算，同步计算MD5（支持大文件）
-	md5sum, err = calculateFileMD5WithProgress(filePath, nil)
-	if err != nil {
+	// 任务只在文件未被修改时写入缓存
+	if info, err = os.Stat(filePath); err != nil {
 		return "", err
 	}
-
-	// 更新缓存
-	md5Cache.SetMD5ToCache(filePath, fileName, md5sum, info.Size())
+	if md5sum, calculated = md5Cache.GetMD5FromCache(filePath, info); !calculated {
+		return "", fmt.Errorf("%s: file was modified during MD5 calculation", filename)
+	}
 	return md5sum, nil
 }
 
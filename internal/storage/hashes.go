@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"lfs/internal/interfaces"
+	"lfs/pkg/hashing"
+)
+
+// writeHashAlgorithms 写入文件时同时计算并缓存的摘要算法
+var writeHashAlgorithms = []string{hashing.MD5, hashing.SHA256}
+
+// newWriteHasher 创建写入文件时使用的摘要计算器
+func newWriteHasher() *hashing.MultiHash {
+	hasher, _ := hashing.NewMulti(writeHashAlgorithms)
+	return hasher
+}
+
+// recordWrittenHashes 将写入时计算的摘要存入缓存，使用写入完成后的文件状态
+func recordWrittenHashes(filePath string, sums map[string]string) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return
+	}
+	md5Cache.SetHashesToCache(filePath, sums, stampOf(info))
+}
+
+// calculateFileHashes 读取一遍文件同时计算多种摘要，progressCallback 可以为 nil
+func calculateFileHashes(ctx context.Context, filePath string, algorithms []string, progressCallback func(float64)) (map[string]string, error) {
+	multi, err := hashing.NewMulti(algorithms)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, interfaces.ErrInvalidPath
+	}
+
+	buf := make([]byte, DefaultBufferSize)
+	var totalRead int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := file.Read(buf)
+		if n > 0 {
+			multi.Write(buf[:n])
+			totalRead += int64(n)
+			if progressCallback != nil && info.Size() > 0 {
+				progressCallback(float64(totalRead) / float64(info.Size()))
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return multi.Sums(), nil
+}
+
+// GetFileHashes 获取文件的多种摘要（带缓存），缓存中缺少的摘要读取一遍文件同时计算
+func GetFileHashes(ctx context.Context, storagePath, filename string, algorithms []string) (map[string]string, error) {
+	algorithms, err := hashing.Normalize(algorithms)
+	if err != nil {
+		return nil, err
+	}
+	if len(algorithms) == 0 {
+		return nil, interfaces.ErrUnsupportedHashAlgorithm
+	}
+
+	filePath, _, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, interfaces.ErrInvalidPath
+	}
+
+	hashes, missing := md5Cache.GetHashesFromCache(filePath, info, algorithms)
+	if len(missing) == 0 {
+		return hashes, nil
+	}
+
+	sums, err := calculateFileHashes(ctx, filePath, missing, nil)
+	if err != nil {
+		return nil, err
+	}
+	md5Cache.SetHashesToCache(filePath, sums, stampOf(info))
+	for algorithm, sum := range sums {
+		hashes[algorithm] = sum
+	}
+	return hashes, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package storage
+
+import "os"
+
+// fileInode 当前平台不提供 inode，只依靠大小和修改时间判断文件是否被修改
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
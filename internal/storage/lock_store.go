@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"lfs/internal/interfaces"
+)
+
+// LockStore 文件锁的持久化存储
+// 所有锁保存在内部数据目录下的 locks.json 中，每次修改后整体原子写回
+type LockStore struct {
+	file   string
+	locks  map[string]interfaces.Lock // 锁ID -> 锁
+	byPath map[string]string          // 路径 -> 锁ID
+	mutex  sync.RWMutex
+}
+
+// NewLockStore 创建锁存储，并从磁盘加载已有的锁
+func NewLockStore(storagePath string) (*LockStore, error) {
+	s := &LockStore{
+		file:   filepath.Join(storagePath, InternalDirName, "locks.json"),
+		locks:  make(map[string]interfaces.Lock),
+		byPath: make(map[string]string),
+	}
+
+	// 损坏的锁文件移到一旁，从没有锁的状态开始，而不是让服务无法启动
+	var locks []interfaces.Lock
+	if err := readJSONFile(s.file, &locks); err != nil && !os.IsNotExist(err) {
+		if !isCorruptJSON(err) {
+			return nil, err
+		}
+		if err := quarantineFile(s.file, err); err != nil {
+			return nil, err
+		}
+		locks = nil
+	}
+	for _, lock := range locks {
+		if lock.ID == "" || lock.Path == "" {
+			continue
+		}
+		s.locks[lock.ID] = lock
+		s.byPath[lock.Path] = lock.ID
+	}
+
+	return s, nil
+}
+
+// Create 保存新锁，路径已被锁定时返回已有的锁
+func (s *LockStore) Create(lock interfaces.Lock) (interfaces.Lock, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if id, exists := s.byPath[lock.Path]; exists {
+		return s.locks[id], interfaces.ErrLockExists
+	}
+
+	s.locks[lock.ID] = lock
+	s.byPath[lock.Path] = lock.ID
+	if err := s.persist(); err != nil {
+		delete(s.locks, lock.ID)
+		delete(s.byPath, lock.Path)
+		return interfaces.Lock{}, err
+	}
+
+	return lock, nil
+}
+
+// Get 根据ID获取锁
+func (s *LockStore) Get(id string) (interfaces.Lock, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	lock, exists := s.locks[id]
+	if !exists {
+		return interfaces.Lock{}, interfaces.ErrLockNotFound
+	}
+	return lock, nil
+}
+
+// FindByPath 根据路径获取锁
+func (s *LockStore) FindByPath(path string) (interfaces.Lock, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	id, exists := s.byPath[path]
+	if !exists {
+		return interfaces.Lock{}, false
+	}
+	return s.locks[id], true
+}
+
+// List 返回所有锁，按加锁时间和ID排序
+func (s *LockStore) List() []interfaces.Lock {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.sortedLocks()
+}
+
+// Delete 删除锁
+func (s *LockStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lock, exists := s.locks[id]
+	if !exists {
+		return interfaces.ErrLockNotFound
+	}
+
+	delete(s.locks, id)
+	delete(s.byPath, lock.Path)
+	if err := s.persist(); err != nil {
+		s.locks[id] = lock
+		s.byPath[lock.Path] = id
+		return err
+	}
+	return nil
+}
+
+// sortedLocks 返回排序后的锁列表（调用方需持有锁）
+func (s *LockStore) sortedLocks() []interfaces.Lock {
+	locks := make([]interfaces.Lock, 0, len(s.locks))
+	for _, lock := range s.locks {
+		locks = append(locks, lock)
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		if locks[i].LockedAt.Equal(locks[j].LockedAt) {
+			return locks[i].ID < locks[j].ID
+		}
+		return locks[i].LockedAt.Before(locks[j].LockedAt)
+	})
+	return locks
+}
+
+// persist 将所有锁写回磁盘（调用方需持有写锁）
+func (s *LockStore) persist() error {
+	return writeJSONFile(s.file, s.sortedLocks())
+}
@@ -0,0 +1,657 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"lfs/internal/interfaces"
+	"lfs/pkg/hashing"
+)
+
+const (
+	// hashIndexFile 持久化摘要索引的快照文件名，保存在内部数据目录下
+	hashIndexFile = "hash-index.json"
+
+	// hashLogFile 快照之后的变更日志文件名，每行一条记录，只追加写入
+	hashLogFile = "hash-index.log"
+
+	// hashIndexVersion 索引文件格式版本，不一致时丢弃旧索引
+	hashIndexVersion = 1
+
+	// hashIndexSaveDelay 索引变更后延迟写盘的时间，合并短时间内的多次变更
+	hashIndexSaveDelay = 2 * time.Second
+
+	// hashLogCompactMin 日志记录数超过该值和索引条目数中的较大者时，重写快照并清空日志
+	hashLogCompactMin = 1000
+
+	// hashProgressStep 进度至少增加这么多才推送一次进度事件
+	hashProgressStep = 0.01
+)
+
+// fileStamp 判断缓存的MD5是否仍然有效的文件状态：大小、修改时间和 inode 任一变化即视为文件已修改
+type fileStamp struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`        // 修改时间（纳秒）
+	Inode   uint64 `json:"inode,omitempty"` // 不支持 inode 的平台为0
+}
+
+// stampOf 返回文件当前的状态
+func stampOf(info os.FileInfo) fileStamp {
+	return fileStamp{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Inode:   fileInode(info),
+	}
+}
+
+// MD5CacheEntry MD5缓存条目，同时保存按需计算的其他算法摘要
+type MD5CacheEntry struct {
+	MD5         string            `json:"md5"`
+	Hashes      map[string]string `json:"hashes,omitempty"` // 其他算法的摘要（十六进制），键为算法名
+	Stamp       fileStamp         `json:"stamp"`            // 计算摘要时的文件状态
+	Calculated  bool              `json:"calculated"`
+	Calculating bool              `json:"calculating"`     // 是否正在计算中
+	Progress    float64           `json:"progress"`        // 计算进度 0.0-1.0
+	Error       string            `json:"error,omitempty"` // 计算错误信息
+
+	published float64 // 最近一次推送的进度
+}
+
+// hasDigests 判断条目是否保存了任何摘要
+func (e *MD5CacheEntry) hasDigests() bool {
+	return e.Calculated || len(e.Hashes) > 0
+}
+
+// hashIndexRecord 索引文件中的一条记录
+type hashIndexRecord struct {
+	MD5    string            `json:"md5,omitempty"`
+	Hashes map[string]string `json:"hashes,omitempty"`
+	fileStamp
+}
+
+// hashIndex 索引文件内容，键为相对存储根目录的路径（使用 / 分隔）
+type hashIndex struct {
+	Version int                        `json:"version"`
+	Entries map[string]hashIndexRecord `json:"entries"`
+}
+
+// hashLogRecord 变更日志中的一条记录：设置或删除一个路径的摘要
+type hashLogRecord struct {
+	Path    string `json:"path"`              // 相对存储根目录的路径（使用 / 分隔）
+	Deleted bool   `json:"deleted,omitempty"` // 为 true 时删除该路径的记录
+	hashIndexRecord
+}
+
+// MD5Cache MD5缓存管理器
+// 以文件完整路径为键，已计算的MD5和其他摘要持久化到存储目录下的索引文件，重启后无需重新计算
+// 变更追加写入日志文件，日志过长时才重写整个快照，索引大小不影响单次变更的写盘量
+type MD5Cache struct {
+	cache  map[string]*MD5CacheEntry // 缓存：key为文件完整路径
+	mutex  sync.RWMutex
+	jobs   interfaces.JobService     // 执行后台计算的任务服务，为空时不预计算
+	events interfaces.EventPublisher // 推送计算进度的事件服务，为空时不推送
+
+	root       string                     // 存储根目录，为空表示不持久化
+	missing    map[string]hashIndexRecord // 加载索引时文件已不存在的记录，留给巡检报告
+	pending    []hashLogRecord            // 尚未写入日志的变更
+	logRecords int                        // 日志文件中的记录数
+	compact    bool                       // 下次写盘时重写快照并清空日志
+	saveTimer  *time.Timer                // 延迟写盘的定时器
+	saveMutex  sync.Mutex                 // 保证同一时间只有一次写盘
+}
+
+// 全局MD5缓存实例
+var md5Cache = &MD5Cache{
+	cache: make(map[string]*MD5CacheEntry),
+}
+
+// LoadIndex 从 storagePath 下的索引快照和变更日志加载已计算的摘要，并丢弃文件已删除或已修改的记录
+// 之后的变更会自动追加到日志；快照损坏时返回错误，缓存从空开始并在下次写盘时重写快照
+func (mc *MD5Cache) LoadIndex(storagePath string) error {
+	var index hashIndex
+	err := readJSONFile(filepath.Join(storagePath, InternalDirName, hashIndexFile), &index)
+	if os.IsNotExist(err) {
+		index, err = hashIndex{Version: hashIndexVersion}, nil
+	}
+	if index.Entries == nil {
+		index.Entries = make(map[string]hashIndexRecord)
+	}
+	var logged int
+	var corrupt bool
+	if err == nil && index.Version == hashIndexVersion {
+		logged, corrupt, err = readHashLog(filepath.Join(storagePath, InternalDirName, hashLogFile), index.Entries)
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.root = storagePath
+	mc.logRecords = logged
+	if err != nil {
+		mc.compact = true
+		mc.scheduleSaveLocked()
+		return err
+	}
+	if index.Version != hashIndexVersion {
+		mc.compact = true
+		mc.scheduleSaveLocked()
+		return nil
+	}
+
+	// 日志的最后一行可能在写入时中断，加载后总是把日志合并进快照，之后从空日志开始追加
+	stale := logged > 0 || corrupt
+	for rel, record := range index.Entries {
+		filePath := filepath.Join(storagePath, filepath.FromSlash(rel))
+		info, err := os.Stat(filePath)
+		if err != nil || info.IsDir() || stampOf(info) != record.fileStamp {
+			if os.IsNotExist(err) {
+				if mc.missing == nil {
+					mc.missing = make(map[string]hashIndexRecord)
+				}
+				mc.missing[rel] = record
+			}
+			stale = true
+			continue
+		}
+		mc.cache[filePath] = &MD5CacheEntry{
+			MD5:        record.MD5,
+			Hashes:     record.Hashes,
+			Stamp:      record.fileStamp,
+			Calculated: record.MD5 != "",
+		}
+	}
+	if stale {
+		mc.compact = true
+		mc.scheduleSaveLocked()
+	}
+	return nil
+}
+
+// readHashLog 按顺序把变更日志中的记录应用到 entries，返回有效记录数和是否遇到无法解析的记录
+// 日志不存在时不做任何修改
+func readHashLog(path string, entries map[string]hashIndexRecord) (int, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	defer f.Close()
+
+	count, corrupt := 0, false
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record hashLogRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Path == "" {
+			corrupt = true
+			continue
+		}
+		count++
+		if record.Deleted {
+			delete(entries, record.Path)
+		} else {
+			entries[record.Path] = record.hashIndexRecord
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		// 超长的行只可能来自损坏的日志，之前读到的记录仍然有效
+		corrupt = true
+	}
+	return count, corrupt, nil
+}
+
+// GetMD5FromCache 从缓存获取MD5
+// 判断依据：文件路径+大小+修改时间+inode，文件已被修改时删除过期条目
+func (mc *MD5Cache) GetMD5FromCache(filePath string, info os.FileInfo) (string, bool) {
+	stamp := stampOf(info)
+
+	mc.mutex.RLock()
+	entry, exists := mc.cache[filePath]
+	valid := exists && entry.Calculated && entry.Stamp == stamp
+	var md5sum string
+	if valid {
+		md5sum = entry.MD5
+	}
+	mc.mutex.RUnlock()
+
+	if exists && entry.hasDigests() && entry.Stamp != stamp {
+		mc.mutex.Lock()
+		if current, ok := mc.cache[filePath]; ok && current.hasDigests() && current.Stamp != stamp {
+			delete(mc.cache, filePath)
+			mc.recordLocked(filePath, nil)
+		}
+		mc.mutex.Unlock()
+	}
+
+	return md5sum, valid
+}
+
+// SetMD5ToCache 设置MD5到缓存，stamp 为开始计算前的文件状态
+// 计算期间文件被修改时不写入，避免缓存与内容不一致
+func (mc *MD5Cache) SetMD5ToCache(filePath, md5 string, stamp fileStamp) {
+	mc.SetHashesToCache(filePath, map[string]string{hashing.MD5: md5}, stamp)
+}
+
+// GetHashesFromCache 从缓存获取多种摘要，返回已缓存的摘要和缺少的算法
+// 文件状态与缓存不一致时全部视为缺少
+func (mc *MD5Cache) GetHashesFromCache(filePath string, info os.FileInfo, algorithms []string) (map[string]string, []string) {
+	stamp := stampOf(info)
+
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	entry, exists := mc.cache[filePath]
+	if exists && entry.Stamp != stamp {
+		exists = false
+	}
+
+	found := make(map[string]string, len(algorithms))
+	var missing []string
+	for _, algorithm := range algorithms {
+		switch {
+		case !exists:
+			missing = append(missing, algorithm)
+		case algorithm == hashing.MD5 && entry.Calculated:
+			found[algorithm] = entry.MD5
+		case entry.Hashes[algorithm] != "":
+			found[algorithm] = entry.Hashes[algorithm]
+		default:
+			missing = append(missing, algorithm)
+		}
+	}
+	return found, missing
+}
+
+// SetHashesToCache 将多种摘要合并到缓存，stamp 为开始计算前的文件状态
+// 文件状态未变时保留已有的其他摘要；计算期间文件被修改时丢弃条目
+func (mc *MD5Cache) SetHashesToCache(filePath string, hashes map[string]string, stamp fileStamp) {
+	info, err := os.Stat(filePath)
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if err != nil || stampOf(info) != stamp {
+		// 只删除这次计算对应的条目，文件被替换后写入的新摘要保留
+		if entry, exists := mc.cache[filePath]; exists && entry.Stamp == stamp {
+			delete(mc.cache, filePath)
+		}
+		return
+	}
+
+	entry, exists := mc.cache[filePath]
+	if !exists || entry.Stamp != stamp {
+		entry = &MD5CacheEntry{Stamp: stamp}
+		mc.cache[filePath] = entry
+	}
+	if entry.Calculating && hashes[hashing.MD5] != "" {
+		mc.publishLocked(interfaces.Event{
+			Type:     interfaces.EventHashProgress,
+			Path:     mc.relativePathLocked(filePath),
+			Progress: 1,
+			MD5:      hashes[hashing.MD5],
+		})
+	}
+	for algorithm, sum := range hashes {
+		if algorithm == hashing.MD5 {
+			entry.MD5 = sum
+			entry.Calculated = true
+			entry.Calculating = false
+			entry.Error = ""
+			continue
+		}
+		if entry.Hashes == nil {
+			entry.Hashes = make(map[string]string)
+		}
+		entry.Hashes[algorithm] = sum
+	}
+	mc.recordLocked(filePath, entry)
+}
+
+// SetCalculating 设置正在计算状态，文件状态未变时保留已有的其他摘要
+func (mc *MD5Cache) SetCalculating(filePath string, stamp fileStamp) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	entry := &MD5CacheEntry{
+		Stamp:       stamp,
+		Calculated:  false,
+		Calculating: true,
+		Progress:    0.0,
+	}
+	if current, exists := mc.cache[filePath]; exists && current.Stamp == stamp {
+		entry.Hashes = current.Hashes
+	}
+	mc.cache[filePath] = entry
+}
+
+// UpdateProgress 更新计算进度
+func (mc *MD5Cache) UpdateProgress(filePath string, progress float64) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	entry, exists := mc.cache[filePath]
+	if !exists {
+		return
+	}
+	entry.Progress = progress
+	if progress-entry.published >= hashProgressStep {
+		entry.published = progress
+		mc.publishLocked(interfaces.Event{
+			Type:     interfaces.EventHashProgress,
+			Path:     mc.relativePathLocked(filePath),
+			Progress: progress,
+		})
+	}
+}
+
+// SetError 设置计算错误
+func (mc *MD5Cache) SetError(filePath string, err error) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if entry, exists := mc.cache[filePath]; exists {
+		entry.Calculating = false
+		entry.Error = err.Error()
+		mc.publishLocked(interfaces.Event{
+			Type:     interfaces.EventHashProgress,
+			Path:     mc.relativePathLocked(filePath),
+			Progress: entry.Progress,
+			Error:    entry.Error,
+		})
+	}
+}
+
+// CancelCalculating 清除被取消的计算状态，没有任何摘要的条目直接删除
+func (mc *MD5Cache) CancelCalculating(filePath string) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	entry, exists := mc.cache[filePath]
+	if !exists || !entry.Calculating {
+		return
+	}
+	if !entry.hasDigests() {
+		delete(mc.cache, filePath)
+		return
+	}
+	entry.Calculating = false
+	entry.Progress = 0
+}
+
+// GetProgress 获取计算进度
+func (mc *MD5Cache) GetProgress(filePath string) (float64, bool, string) {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	entry, exists := mc.cache[filePath]
+	if !exists {
+		return 0, false, ""
+	}
+
+	return entry.Progress, entry.Calculating, entry.Error
+}
+
+// InvalidatePath 删除文件或目录下所有文件的缓存条目
+func (mc *MD5Cache) InvalidatePath(path string) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	for filePath, entry := range mc.cache {
+		if !isSameOrChildPath(filePath, path) {
+			continue
+		}
+		delete(mc.cache, filePath)
+		if entry.hasDigests() {
+			mc.recordLocked(filePath, nil)
+		}
+	}
+}
+
+// RenamePath 将文件或目录下所有文件的缓存条目迁移到新路径
+// 重命名不改变文件的大小、修改时间和 inode，迁移后的条目仍然有效
+func (mc *MD5Cache) RenamePath(oldPath, newPath string) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	// 先收集再迁移，避免遍历过程中修改映射
+	moved := make(map[string]*MD5CacheEntry)
+	for filePath, entry := range mc.cache {
+		if isSameOrChildPath(filePath, oldPath) {
+			moved[filePath] = entry
+			delete(mc.cache, filePath)
+		}
+	}
+	if len(moved) == 0 {
+		return
+	}
+
+	for filePath, entry := range moved {
+		target := newPath + strings.TrimPrefix(filePath, oldPath)
+		mc.cache[target] = entry
+		if entry.hasDigests() {
+			mc.recordLocked(filePath, nil)
+			mc.recordLocked(target, entry)
+		}
+	}
+}
+
+// recordedDigests 返回文件已记录的全部摘要（包括MD5）和记录时的文件状态
+func (mc *MD5Cache) recordedDigests(filePath string) (map[string]string, fileStamp, bool) {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	entry, exists := mc.cache[filePath]
+	if !exists || !entry.hasDigests() {
+		return nil, fileStamp{}, false
+	}
+	digests := maps.Clone(entry.Hashes)
+	if digests == nil {
+		digests = make(map[string]string)
+	}
+	if entry.Calculated {
+		digests[hashing.MD5] = entry.MD5
+	}
+	return digests, entry.Stamp, true
+}
+
+// indexedPaths 返回所有保存了摘要的文件路径
+func (mc *MD5Cache) indexedPaths() []string {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	var paths []string
+	for filePath, entry := range mc.cache {
+		if entry.hasDigests() {
+			paths = append(paths, filePath)
+		}
+	}
+	return paths
+}
+
+// takeMissing 返回并清空加载索引时已不存在的文件记录，键为相对路径
+func (mc *MD5Cache) takeMissing() map[string]hashIndexRecord {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	missing := mc.missing
+	mc.missing = nil
+	return missing
+}
+
+// relativePathLocked 返回相对存储根目录的路径（使用 / 分隔），调用方需持有 mutex
+func (mc *MD5Cache) relativePathLocked(filePath string) string {
+	if mc.root == "" {
+		return filePath
+	}
+	rel, err := filepath.Rel(mc.root, filePath)
+	if err != nil {
+		return filePath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// publishLocked 推送事件，调用方需持有 mutex；事件服务不会阻塞
+func (mc *MD5Cache) publishLocked(event interfaces.Event) {
+	if mc.events != nil {
+		mc.events.Publish(event)
+	}
+}
+
+// indexKeyLocked 返回文件在索引中的键（相对存储根目录、使用 / 分隔），不在存储根目录下时返回 false
+// 调用方需持有 mutex
+func (mc *MD5Cache) indexKeyLocked(filePath string) (string, bool) {
+	if mc.root == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(mc.root, filePath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// indexRecordOf 返回条目在索引中的记录，摘要表被复制，写盘可以在释放锁之后进行
+func indexRecordOf(entry *MD5CacheEntry) hashIndexRecord {
+	record := hashIndexRecord{Hashes: maps.Clone(entry.Hashes), fileStamp: entry.Stamp}
+	if entry.Calculated {
+		record.MD5 = entry.MD5
+	}
+	return record
+}
+
+// recordLocked 记录文件摘要的变更并安排写盘，entry 为空或没有摘要时记录删除；调用方需持有 mutex
+func (mc *MD5Cache) recordLocked(filePath string, entry *MD5CacheEntry) {
+	rel, ok := mc.indexKeyLocked(filePath)
+	if !ok {
+		return
+	}
+	record := hashLogRecord{Path: rel, Deleted: entry == nil || !entry.hasDigests()}
+	if !record.Deleted {
+		record.hashIndexRecord = indexRecordOf(entry)
+	}
+	mc.pending = append(mc.pending, record)
+	mc.scheduleSaveLocked()
+}
+
+// scheduleSaveLocked 安排一次延迟写盘，调用方需持有 mutex
+func (mc *MD5Cache) scheduleSaveLocked() {
+	if mc.root == "" || mc.saveTimer != nil {
+		return
+	}
+	mc.saveTimer = time.AfterFunc(hashIndexSaveDelay, func() {
+		mc.mutex.Lock()
+		mc.saveTimer = nil
+		mc.mutex.Unlock()
+		mc.Flush()
+	})
+}
+
+// Flush 立即将尚未写盘的变更追加到日志；日志过长或需要重建时改为重写快照并清空日志
+func (mc *MD5Cache) Flush() error {
+	mc.saveMutex.Lock()
+	defer mc.saveMutex.Unlock()
+
+	mc.mutex.Lock()
+	root := mc.root
+	pending := mc.pending
+	mc.pending = nil
+	compact := mc.compact || mc.logRecords+len(pending) > max(hashLogCompactMin, len(mc.cache))
+	var index hashIndex
+	if compact {
+		index = mc.snapshotLocked()
+		mc.compact = false
+	}
+	mc.mutex.Unlock()
+
+	if root == "" {
+		return nil
+	}
+	internalDir := filepath.Join(root, InternalDirName)
+	if compact {
+		err := writeJSONFile(filepath.Join(internalDir, hashIndexFile), index)
+		if err == nil {
+			// 快照已包含日志中的全部记录，中途失败时重放日志得到的结果相同
+			if err = os.Truncate(filepath.Join(internalDir, hashLogFile), 0); os.IsNotExist(err) {
+				err = nil
+			}
+		}
+		mc.finishFlush(err, 0)
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	err := appendHashLog(filepath.Join(internalDir, hashLogFile), pending)
+	mc.finishFlush(err, len(pending))
+	return err
+}
+
+// finishFlush 更新日志记录数；写盘失败时下次改为重写快照，保证内存中的变更最终写入
+func (mc *MD5Cache) finishFlush(err error, appended int) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if err != nil {
+		mc.compact = true
+		return
+	}
+	if appended == 0 {
+		mc.logRecords = 0
+	} else {
+		mc.logRecords += appended
+	}
+}
+
+// snapshotLocked 返回当前所有已计算摘要的索引快照，调用方需持有 mutex
+func (mc *MD5Cache) snapshotLocked() hashIndex {
+	index := hashIndex{
+		Version: hashIndexVersion,
+		Entries: make(map[string]hashIndexRecord),
+	}
+	for filePath, entry := range mc.cache {
+		if !entry.hasDigests() {
+			continue
+		}
+		if rel, ok := mc.indexKeyLocked(filePath); ok {
+			index.Entries[rel] = indexRecordOf(entry)
+		}
+	}
+	return index
+}
+
+// appendHashLog 将记录逐行追加到日志文件并同步到磁盘
+func appendHashLog(path string, records []hashLogRecord) error {
+	var buf []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -1,5 +1,11 @@
 package storage
 
+import (
+	"os"
+
+	"lfs/internal/interfaces"
+)
+
 // MD5CacheAdapter implements the MD5Cache interface, providing MD5 value caching functionality.
 // It delegates interface calls to the underlying MD5Cache implementation.
 type MD5CacheAdapter struct {
@@ -7,26 +13,59 @@ type MD5CacheAdapter struct {
 }
 
 // NewMD5CacheAdapter creates and returns a new MD5 cache adapter instance.
-func NewMD5CacheAdapter() *MD5CacheAdapter {
-	return &MD5CacheAdapter{
+// Previously calculated MD5 values are loaded from the hash index under storagePath,
+// and later changes are written back to it. A corrupt index is reported as an error
+// but still yields a usable, empty cache. Missing MD5 values of listed files are
+// calculated in the background as low-priority jobs on jobs, and their progress
+// is published to events.
+func NewMD5CacheAdapter(storagePath string, jobs interfaces.JobService, events interfaces.EventPublisher) (*MD5CacheAdapter, error) {
+	a := &MD5CacheAdapter{
 		cache: md5Cache, // Use global instance
 	}
+	a.cache.mutex.Lock()
+	a.cache.jobs = jobs
+	a.cache.events = events
+	a.cache.mutex.Unlock()
+	return a, a.cache.LoadIndex(storagePath)
 }
 
 // GetMD5 gets the MD5 value of a file from cache.
-func (a *MD5CacheAdapter) GetMD5(filePath, fileName string, size int64) (string, bool) {
-	return a.cache.GetMD5FromCache(filePath, fileName, size)
+// Entries are only returned while the file's size, modification time and inode are unchanged.
+func (a *MD5CacheAdapter) GetMD5(filePath string) (string, bool) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", false
+	}
+	return a.cache.GetMD5FromCache(filePath, info)
+}
+
+// SetMD5 sets the MD5 value of the file's current content to cache.
+func (a *MD5CacheAdapter) SetMD5(filePath, md5 string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	a.cache.SetMD5ToCache(filePath, md5, stampOf(info))
+	return nil
 }
 
-// SetMD5 sets the MD5 value to cache.
-func (a *MD5CacheAdapter) SetMD5(filePath, fileName, md5 string, size int64) error {
-	a.cache.SetMD5ToCache(filePath, fileName, md5, size)
+// SetHashes merges digests of the file's current content, keyed by algorithm, into the cache.
+func (a *MD5CacheAdapter) SetHashes(filePath string, hashes map[string]string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	a.cache.SetHashesToCache(filePath, hashes, stampOf(info))
 	return nil
 }
 
 // SetCalculating marks that a file is being calculated for MD5.
-func (a *MD5CacheAdapter) SetCalculating(filePath, fileName string, size int64) error {
-	a.cache.SetCalculating(filePath, fileName, size)
+func (a *MD5CacheAdapter) SetCalculating(filePath string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	a.cache.SetCalculating(filePath, stampOf(info))
 	return nil
 }
 
@@ -46,3 +85,15 @@ func (a *MD5CacheAdapter) SetError(filePath string, err error) error {
 func (a *MD5CacheAdapter) GetProgress(filePath string) (float64, bool, string) {
 	return a.cache.GetProgress(filePath)
 }
+
+// Invalidate removes the cache entries of a file or of all files under a directory.
+func (a *MD5CacheAdapter) Invalidate(filePath string) error {
+	a.cache.InvalidatePath(filePath)
+	return nil
+}
+
+// Rename migrates the cache entries of a file or of all files under a directory to a new path.
+func (a *MD5CacheAdapter) Rename(oldPath, newPath string) error {
+	a.cache.RenamePath(oldPath, newPath)
+	return nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"lfs/internal/interfaces"
+)
+
+// SanitizePath 校验并清理用户提供的相对路径，返回以 / 分隔的规范相对路径（根目录为空字符串）
+// 以下路径会被拒绝并返回 interfaces.ErrInvalidPath：
+//   - 绝对路径（以 / 或 \ 开头，或带盘符）
+//   - 包含 .. 路径段
+//   - 包含空字符
+//   - 位于内部数据目录下
+func SanitizePath(name string) (string, error) {
+	if strings.ContainsRune(name, 0) {
+		return "", interfaces.ErrInvalidPath
+	}
+
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" || (len(name) >= 2 && name[1] == ':') {
+		return "", interfaces.ErrInvalidPath
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", interfaces.ErrInvalidPath
+		}
+	}
+
+	rel := filepath.ToSlash(filepath.Clean(name))
+	if rel == "." {
+		return "", nil
+	}
+	if rel == InternalDirName || strings.HasPrefix(rel, InternalDirName+"/") {
+		return "", interfaces.ErrInvalidPath
+	}
+
+	return rel, nil
+}
+
+// ResolvePath 将用户提供的相对路径解析为存储路径下的完整路径，是所有存储入口共用的路径检查
+// 除 SanitizePath 的检查外，还会解析路径上已存在部分的符号链接，拒绝指向存储路径之外的链接；
+// 返回完整路径和规范相对路径（根目录为空字符串）
+func ResolvePath(storagePath, name string) (string, string, error) {
+	rel, err := SanitizePath(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	fullPath := filepath.Join(storagePath, filepath.FromSlash(rel))
+	if err := checkSymlinkEscape(storagePath, fullPath); err != nil {
+		return "", "", err
+	}
+
+	return fullPath, rel, nil
+}
+
+// checkSymlinkEscape 检查路径中已存在的最长前缀解析符号链接后是否仍位于存储路径内
+func checkSymlinkEscape(storagePath, fullPath string) error {
+	realRoot, err := filepath.EvalSymlinks(storagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// 存储路径尚未创建，不存在符号链接
+			return nil
+		}
+		return err
+	}
+	if realRoot == string(filepath.Separator) {
+		return nil
+	}
+
+	p := fullPath
+	for {
+		realPath, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			if !isSameOrChildPath(realPath, realRoot) {
+				return interfaces.ErrInvalidPath
+			}
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		// 悬空的符号链接，写入时会在其指向的位置创建文件
+		if _, lerr := os.Lstat(p); lerr == nil {
+			return interfaces.ErrInvalidPath
+		}
+
+		// 路径不存在时检查其父目录
+		parent := filepath.Dir(p)
+		if parent == p {
+			return nil
+		}
+		p = parent
+	}
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"lfs/internal/interfaces"
+)
+
+func TestSanitizePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty is root", input: "", want: ""},
+		{name: "dot is root", input: ".", want: ""},
+		{name: "plain file", input: "a/b.txt", want: "a/b.txt"},
+		{name: "redundant separators", input: "a//b/./c/", want: "a/b/c"},
+		{name: "dots inside a name", input: "a..b", want: "a..b"},
+		{name: "leading dots in a name", input: "..a/b..", want: "..a/b.."},
+		{name: "parent segment", input: "..", wantErr: true},
+		{name: "leading parent", input: "../etc/passwd", wantErr: true},
+		{name: "inner parent", input: "a/../b", wantErr: true},
+		{name: "trailing parent", input: "a/..", wantErr: true},
+		{name: "absolute path", input: "/etc/passwd", wantErr: true},
+		{name: "backslash absolute path", input: `\windows\system32`, wantErr: true},
+		{name: "drive letter", input: "C:/Windows", wantErr: true},
+		{name: "drive relative", input: "c:file", wantErr: true},
+		{name: "backslash separators", input: `a\b\c.txt`, want: "a/b/c.txt"},
+		{name: "backslash parent", input: `a\..\..\b`, wantErr: true},
+		{name: "null byte", input: "a\x00b", wantErr: true},
+		{name: "internal dir", input: ".lfs", wantErr: true},
+		{name: "inside internal dir", input: ".lfs/locks.json", wantErr: true},
+		{name: "internal dir after dot", input: "./.lfs/objects", wantErr: true},
+		{name: "internal dir with backslash", input: `.lfs\locks.json`, wantErr: true},
+		{name: "nested dir named like internal dir", input: "a/.lfs", want: "a/.lfs"},
+		{name: "prefix of internal dir name", input: ".lfsx/file", want: ".lfsx/file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SanitizePath(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, interfaces.ErrInvalidPath) {
+					t.Fatalf("SanitizePath(%q) = %q, %v; want ErrInvalidPath", tt.input, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("SanitizePath(%q) = %q, %v; want %q", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "sub"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		filepath.Join(root, "link-out"):    outside,
+		filepath.Join(root, "link-in"):     filepath.Join(root, "sub"),
+		filepath.Join(root, "link-rel"):    filepath.Join("..", "outside"),
+		filepath.Join(root, "dangling"):    filepath.Join(outside, "missing"),
+		filepath.Join(root, "sub", "loop"): filepath.Join(root, "sub"),
+		filepath.Join(base, "root-link"):   root,
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		root    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "root", root: root, input: "", want: ""},
+		{name: "existing file path", root: root, input: "sub/file.txt", want: "sub/file.txt"},
+		{name: "missing parents", root: root, input: "new/dir/file.txt", want: "new/dir/file.txt"},
+		{name: "link inside root", root: root, input: "link-in/file.txt", want: "link-in/file.txt"},
+		{name: "nested link inside root", root: root, input: "sub/loop/file.txt", want: "sub/loop/file.txt"},
+		{name: "link outside root", root: root, input: "link-out", wantErr: true},
+		{name: "file below link outside root", root: root, input: "link-out/file.txt", wantErr: true},
+		{name: "relative link outside root", root: root, input: "link-rel/new/file.txt", wantErr: true},
+		{name: "dangling link outside root", root: root, input: "dangling", wantErr: true},
+		{name: "parent segment", root: root, input: "../outside/file.txt", wantErr: true},
+		{name: "absolute path", root: root, input: filepath.ToSlash(outside), wantErr: true},
+		{name: "backslash parent", root: root, input: `sub\..\..\outside`, wantErr: true},
+		{name: "internal dir", root: root, input: ".lfs/locks.json", wantErr: true},
+		{name: "storage path is a link", root: filepath.Join(base, "root-link"), input: "sub/file.txt", want: "sub/file.txt"},
+		{name: "storage path is a link, escape", root: filepath.Join(base, "root-link"), input: "link-out/file.txt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			full, rel, err := ResolvePath(tt.root, tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, interfaces.ErrInvalidPath) {
+					t.Fatalf("ResolvePath(%q) = %q, %q, %v; want ErrInvalidPath", tt.input, full, rel, err)
+				}
+				return
+			}
+			if err != nil || rel != tt.want {
+				t.Fatalf("ResolvePath(%q) = %q, %q, %v; want %q", tt.input, full, rel, err, tt.want)
+			}
+			if wantFull := filepath.Join(tt.root, filepath.FromSlash(tt.want)); full != wantFull {
+				t.Fatalf("ResolvePath(%q) full path = %q; want %q", tt.input, full, wantFull)
+			}
+		})
+	}
+}
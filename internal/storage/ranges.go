@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRanges 单个请求允许的最大范围数，超过时忽略 Range 返回完整文件
+const maxRanges = 64
+
+// errUnsatisfiableRange 表示 Range 中没有任何范围与文件重叠，应返回 416
+var errUnsatisfiableRange = errors.New("range not satisfiable")
+
+// byteRange 文件中的闭区间 [start, end]
+type byteRange struct {
+	start, end int64
+}
+
+// length 返回范围的字节数
+func (r byteRange) length() int64 {
+	return r.end - r.start + 1
+}
+
+// contentRange 返回范围的 Content-Range 值
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, size)
+}
+
+// parseRangeHeader 按 RFC 9110 14.1.2 解析 Range 请求头，支持 a-b、a-（到结尾）和 -n（最后 n 字节）
+// 语法错误、非 bytes 单位或范围过多时返回 nil，调用方应忽略 Range 返回完整文件；
+// 超出文件大小的结束位置截断到文件末尾，没有任何可满足的范围时返回 errUnsatisfiableRange
+func parseRangeHeader(header string, size int64) ([]byteRange, error) {
+	unit, spec, ok := strings.Cut(header, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+		return nil, nil
+	}
+
+	specs := strings.Split(spec, ",")
+	if len(specs) > maxRanges {
+		return nil, nil
+	}
+
+	var ranges []byteRange
+	for _, s := range specs {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		startPart, endPart, ok := strings.Cut(s, "-")
+		if !ok {
+			return nil, nil
+		}
+		startPart, endPart = strings.TrimSpace(startPart), strings.TrimSpace(endPart)
+
+		if startPart == "" {
+			// 后缀范围：最后 n 字节
+			n, err := strconv.ParseInt(endPart, 10, 64)
+			if err != nil || n < 0 {
+				return nil, nil
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			ranges = append(ranges, byteRange{start: size - n, end: size - 1})
+			continue
+		}
+
+		start, err := strconv.ParseInt(startPart, 10, 64)
+		if err != nil || start < 0 {
+			return nil, nil
+		}
+		end := size - 1
+		if endPart != "" {
+			if end, err = strconv.ParseInt(endPart, 10, 64); err != nil || end < start {
+				return nil, nil
+			}
+			if end >= size {
+				end = size - 1
+			}
+		}
+		if start >= size {
+			continue
+		}
+		ranges = append(ranges, byteRange{start: start, end: end})
+	}
+
+	if len(ranges) == 0 {
+		return nil, errUnsatisfiableRange
+	}
+	return ranges, nil
+}
+
+// ifRangeMatches 判断 If-Range 条件是否成立（RFC 9110 13.1.5）：
+// 实体标签使用强比较，日期必须与最后修改时间完全一致；条件不成立时应忽略 Range 返回完整文件
+func ifRangeMatches(ifRange, etag string, modTime time.Time) bool {
+	ifRange = strings.TrimSpace(ifRange)
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, "\"") || strings.HasPrefix(ifRange, "W/") {
+		return ifRange == etag
+	}
+	t, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	return modTime.Truncate(time.Second).Equal(t)
+}
+
+// writeMultipartRanges 以 multipart/byteranges 格式发送多个范围，Content-Length 预先计算
+// f 为 nil 时只发送响应头（HEAD 请求）
+func writeMultipartRanges(ctx context.Context, w http.ResponseWriter, f io.ReaderAt, ranges []byteRange, size int64, contentType string) error {
+	partHeader := func(r byteRange) textproto.MIMEHeader {
+		return textproto.MIMEHeader{
+			"Content-Type":  {contentType},
+			"Content-Range": {r.contentRange(size)},
+		}
+	}
+
+	// 先用相同的分隔符空写一遍，计算响应的总长度
+	counter := &countingWriter{w: io.Discard}
+	mw := multipart.NewWriter(counter)
+	for _, r := range ranges {
+		mw.CreatePart(partHeader(r))
+		counter.n += r.length()
+	}
+	mw.Close()
+
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	w.Header().Set("Content-Length", strconv.FormatInt(counter.n, 10))
+	w.WriteHeader(http.StatusPartialContent)
+	if f == nil {
+		return nil
+	}
+
+	out := multipart.NewWriter(w)
+	if err := out.SetBoundary(mw.Boundary()); err != nil {
+		return err
+	}
+	for _, r := range ranges {
+		part, err := out.CreatePart(partHeader(r))
+		if err != nil {
+			return err
+		}
+		if err := copyWithCancel(ctx, part, io.NewSectionReader(f, r.start, r.length()), r.length()); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"lfs/internal/interfaces"
+	"lfs/pkg/hashing"
+)
+
+const (
+	// scrubReportFile 最近一次巡检报告的文件名，保存在内部数据目录下
+	scrubReportFile = "scrub-report.json"
+
+	// scrubBufferSize 巡检读取文件的缓冲区大小，较小的缓冲区让限速更平滑
+	scrubBufferSize = 1024 * 1024
+)
+
+// rateLimiter 限制巡检的平均读取速度
+type rateLimiter struct {
+	rate  int64 // 每秒字节数，不大于0表示不限速
+	start time.Time
+	bytes int64
+}
+
+// wait 记录读取了 n 字节，读取速度超过限制时等待
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l.rate <= 0 {
+		return nil
+	}
+	l.bytes += int64(n)
+	ahead := time.Duration(float64(l.bytes)/float64(l.rate)*float64(time.Second)) - time.Since(l.start)
+	if ahead <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(ahead)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// scrubFile 巡检要检查的文件
+type scrubFile struct {
+	path string
+	size int64
+}
+
+// ScrubFiles 重新计算存储目录下所有文件的摘要并与摘要索引比对，结果保存到内部数据目录
+// 文件状态（大小、修改时间、inode）与索引一致但摘要不同视为损坏；状态已变化或没有记录的文件计算后写入索引。
+// 巡检期间被修改的文件跳过，不视为损坏。
+func ScrubFiles(ctx context.Context, storagePath string, bytesPerSecond int64, progress func(float64)) (interfaces.ScrubReport, error) {
+	report := interfaces.ScrubReport{
+		StartedAt: time.Now().UTC(),
+		Issues:    []interfaces.ScrubIssue{},
+	}
+	err := scrubFiles(ctx, storagePath, bytesPerSecond, progress, &report)
+	report.FinishedAt = time.Now().UTC()
+	report.Completed = err == nil
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	if saveErr := writeJSONFile(filepath.Join(storagePath, InternalDirName, scrubReportFile), report); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return report, err
+}
+
+// scrubFiles 执行巡检，把结果记录到 report
+func scrubFiles(ctx context.Context, storagePath string, bytesPerSecond int64, progress func(float64), report *interfaces.ScrubReport) error {
+	// 上次启动时已经丢失的文件
+	for rel, record := range md5Cache.takeMissing() {
+		report.Issues = append(report.Issues, missingIssue(rel, record.MD5))
+	}
+
+	files, total, err := collectScrubFiles(ctx, storagePath)
+	if err != nil {
+		return err
+	}
+
+	limiter := &rateLimiter{rate: bytesPerSecond, start: time.Now()}
+	visited := make(map[string]bool, len(files))
+	var done int64
+	for _, file := range files {
+		visited[file.path] = true
+		if err := scrubFileDigests(ctx, storagePath, file.path, limiter, report); err != nil {
+			return err
+		}
+		done += file.size
+		if progress != nil && total > 0 {
+			progress(float64(done) / float64(total))
+		}
+	}
+
+	// 运行期间在存储之外被删除的文件
+	for _, filePath := range md5Cache.indexedPaths() {
+		if visited[filePath] || !isSameOrChildPath(filePath, storagePath) {
+			continue
+		}
+		if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+			continue
+		}
+		digests, _, ok := md5Cache.recordedDigests(filePath)
+		if !ok {
+			continue
+		}
+		report.Issues = append(report.Issues, missingIssue(scrubRelativePath(storagePath, filePath), digests[hashing.MD5]))
+		md5Cache.InvalidatePath(filePath)
+	}
+	return nil
+}
+
+// missingIssue 返回文件丢失的问题记录，md5sum 为索引中记录的MD5（可能为空）
+func missingIssue(rel, md5sum string) interfaces.ScrubIssue {
+	issue := interfaces.ScrubIssue{
+		Path:       rel,
+		Kind:       interfaces.ScrubMissing,
+		DetectedAt: time.Now().UTC(),
+	}
+	if md5sum != "" {
+		issue.Algorithm = hashing.MD5
+		issue.Expected = md5sum
+	}
+	return issue
+}
+
+// collectScrubFiles 列出存储目录下的普通文件和总大小，跳过内部数据目录和临时文件
+func collectScrubFiles(ctx context.Context, storagePath string) ([]scrubFile, int64, error) {
+	internalDir := filepath.Join(storagePath, InternalDirName)
+	var files []scrubFile
+	var total int64
+	err := filepath.WalkDir(storagePath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if d.IsDir() {
+			if p == internalDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || isTempFileName(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, scrubFile{path: p, size: info.Size()})
+		total += info.Size()
+		return nil
+	})
+	return files, total, err
+}
+
+// scrubFileDigests 重新计算一个文件的摘要，与索引比对或写入索引
+// 只有 ctx 被取消时返回错误，单个文件的问题记录在报告中
+func scrubFileDigests(ctx context.Context, storagePath, filePath string, limiter *rateLimiter, report *interfaces.ScrubReport) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil // 遍历之后被删除
+	}
+	stamp := stampOf(info)
+	recorded, recordedStamp, ok := md5Cache.recordedDigests(filePath)
+	verify := ok && recordedStamp == stamp
+
+	algorithms := writeHashAlgorithms
+	if verify {
+		algorithms = make([]string, 0, len(recorded))
+		for algorithm := range recorded {
+			algorithms = append(algorithms, algorithm)
+		}
+		sort.Strings(algorithms)
+	}
+
+	sums, err := hashFileLimited(ctx, filePath, algorithms, limiter)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		report.Issues = append(report.Issues, interfaces.ScrubIssue{
+			Path:       scrubRelativePath(storagePath, filePath),
+			Kind:       interfaces.ScrubUnreadable,
+			Error:      err.Error(),
+			DetectedAt: time.Now().UTC(),
+		})
+		return nil
+	}
+	report.FilesChecked++
+	report.BytesChecked += info.Size()
+
+	// 巡检期间文件被修改，结果没有意义
+	if after, err := os.Stat(filePath); err != nil || stampOf(after) != stamp {
+		return nil
+	}
+
+	if !verify {
+		md5Cache.SetHashesToCache(filePath, sums, stamp)
+		report.FilesRecorded++
+		return nil
+	}
+
+	report.FilesVerified++
+	for _, algorithm := range algorithms {
+		if sums[algorithm] == recorded[algorithm] {
+			continue
+		}
+		// 保留索引中的摘要，它记录的是损坏前的内容，之后的巡检会继续报告
+		report.Issues = append(report.Issues, interfaces.ScrubIssue{
+			Path:       scrubRelativePath(storagePath, filePath),
+			Kind:       interfaces.ScrubMismatch,
+			Algorithm:  algorithm,
+			Expected:   recorded[algorithm],
+			Actual:     sums[algorithm],
+			DetectedAt: time.Now().UTC(),
+		})
+		break
+	}
+	return nil
+}
+
+// hashFileLimited 按限速读取文件并计算多种摘要
+func hashFileLimited(ctx context.Context, filePath string, algorithms []string, limiter *rateLimiter) (map[string]string, error) {
+	multi, err := hashing.NewMulti(algorithms)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, scrubBufferSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := file.Read(buf)
+		if n > 0 {
+			multi.Write(buf[:n])
+			if err := limiter.wait(ctx, n); err != nil {
+				return nil, err
+			}
+		}
+		if err == io.EOF {
+			return multi.Sums(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// scrubRelativePath 返回报告中使用的相对路径
+func scrubRelativePath(storagePath, filePath string) string {
+	rel, err := filepath.Rel(storagePath, filePath)
+	if err != nil {
+		return filePath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// LastScrubReport 读取最近一次保存的巡检报告
+func LastScrubReport(storagePath string) (interfaces.ScrubReport, error) {
+	var report interfaces.ScrubReport
+	err := readJSONFile(filepath.Join(storagePath, InternalDirName, scrubReportFile), &report)
+	return report, err
+}
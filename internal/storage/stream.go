@@ -0,0 +1,371 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"lfs/internal/interfaces"
+)
+
+// limitedReadCloser 将限长读取器与底层文件的关闭操作组合在一起
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// OpenFile 打开存储路径下的文件用于读取
+func OpenFile(storagePath, filename string) (io.ReadCloser, error) {
+	fullPath, _, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(fullPath)
+}
+
+// OpenFileRange 打开文件并只读取 [start, end] 闭区间内的数据
+func OpenFileRange(storagePath, filename string, start, end int64) (io.ReadCloser, error) {
+	if start < 0 || end < start {
+		return nil, os.ErrInvalid
+	}
+
+	fullPath, _, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &limitedReadCloser{
+		Reader: io.LimitReader(f, end-start+1),
+		Closer: f,
+	}, nil
+}
+
+// WriteFileStream 将数据流写入文件
+// 先写入同目录下的临时文件并同步到磁盘，成功后再重命名覆盖目标文件，
+// 读取或写入失败时目标文件保持不变
+func WriteFileStream(ctx context.Context, storagePath, filename string, data io.Reader) error {
+	dest, rel, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return err
+	}
+	if rel == "" {
+		return interfaces.ErrInvalidPath
+	}
+
+	dir := filepath.Dir(dest)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := createTempFile(dest)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// 任何一步失败都删除临时文件
+	success := false
+	defer func() {
+		if !success {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err := copyWithCancel(ctx, tmp, data, -1); err != nil {
+		return err
+	}
+	if err := commitTempFile(tmp, dest); err != nil {
+		return err
+	}
+
+	success = true
+	return nil
+}
+
+// createTempFile 在目标文件所在目录创建临时文件（.<文件名>.tmp-<随机串>）
+// 临时文件不出现在文件列表中，写入中断留下的临时文件由清理任务删除
+func createTempFile(dest string) (*os.File, error) {
+	return os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".tmp-*")
+}
+
+// commitTempFile 将写完的临时文件同步到磁盘并关闭，然后原子重命名覆盖目标文件
+// 读取者只会看到旧文件或完整的新文件；失败时由调用方删除临时文件
+func commitTempFile(tmp *os.File, dest string) error {
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return err
+	}
+
+	// 同步目录，保证重命名在断电后仍然有效（部分平台不支持，忽略错误）
+	if dir, err := os.Open(filepath.Dir(dest)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// WriteFileStreamAt 从指定偏移开始写入数据流，文件不存在时自动创建
+func WriteFileStreamAt(ctx context.Context, storagePath, filename string, start int64, data io.Reader) error {
+	if start < 0 {
+		return os.ErrInvalid
+	}
+
+	dest, rel, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return err
+	}
+	if rel == "" {
+		return interfaces.ErrInvalidPath
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := out.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	// 即使复制中断也把已写入的数据落盘，调用方可能据此记录续传偏移
+	err = copyWithCancel(ctx, out, data, -1)
+	if syncErr := out.Sync(); err == nil {
+		err = syncErr
+	}
+	return err
+}
+
+// partialFilePath 返回目标文件的分段上传数据路径（同目录下的隐藏文件）
+// 文件名符合临时文件的格式，不出现在文件列表中，放弃的分段上传由清理任务删除
+func partialFilePath(dest string) string {
+	return filepath.Join(filepath.Dir(dest), "."+filepath.Base(dest)+".tmp-partial")
+}
+
+// PartialFileSize 返回目标文件的分段上传已接收的字节数，没有分段上传时返回0
+func PartialFileSize(storagePath, filename string) (int64, error) {
+	dest, rel, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return 0, err
+	}
+	if rel == "" {
+		return 0, interfaces.ErrInvalidPath
+	}
+
+	info, err := os.Stat(partialFilePath(dest))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// WritePartialFile 从 start 处写入分段上传数据，start 之后原有的数据被丢弃，返回已接收的字节数
+// 写入失败（包括 data 在结束时返回的校验错误）时截断回 start，不保留未经校验的数据
+func WritePartialFile(ctx context.Context, storagePath, filename string, start int64, data io.Reader) (int64, error) {
+	dest, rel, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return 0, err
+	}
+	if rel == "" {
+		return 0, interfaces.ErrInvalidPath
+	}
+	if info, err := os.Stat(dest); err == nil && info.IsDir() {
+		return 0, interfaces.ErrInvalidPath
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return 0, err
+	}
+
+	out, err := os.OpenFile(partialFilePath(dest), os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	info, err := out.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if start < 0 || start > info.Size() {
+		return info.Size(), interfaces.ErrOffsetMismatch
+	}
+	if err := out.Truncate(start); err != nil {
+		return 0, err
+	}
+	if _, err := out.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	if err := copyWithCancel(ctx, out, data, -1); err != nil {
+		out.Truncate(start)
+		return start, err
+	}
+	if err := out.Sync(); err != nil {
+		out.Truncate(start)
+		return start, err
+	}
+
+	offset, err := out.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+// CommitPartialFile 分段上传已接收 size 字节时同步到磁盘并原子替换目标文件
+// hashOnWrite 为 true 时在替换前读取一遍分段数据计算摘要并返回，替换后的文件不必再读取；否则返回 nil
+func CommitPartialFile(ctx context.Context, storagePath, filename string, size int64, hashOnWrite bool) (map[string]string, error) {
+	dest, rel, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return nil, err
+	}
+	if rel == "" {
+		return nil, interfaces.ErrInvalidPath
+	}
+
+	f, err := os.OpenFile(partialFilePath(dest), os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.Size() != size {
+		f.Close()
+		return nil, interfaces.ErrOffsetMismatch
+	}
+
+	var sums map[string]string
+	if hashOnWrite {
+		hasher := newWriteHasher()
+		if err := copyWithCancel(ctx, hasher, io.NewSectionReader(f, 0, size), size); err != nil {
+			f.Close()
+			return nil, err
+		}
+		sums = hasher.Sums()
+	}
+	if err := commitTempFile(f, dest); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return sums, nil
+}
+
+// StatFile 获取文件或目录的元数据，只带上已缓存的MD5，不触发计算
+func StatFile(storagePath, filename string) (FileMetadata, error) {
+	fullPath, rel, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return FileMetadata{}, err
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return FileMetadata{}, err
+	}
+
+	size := info.Size()
+	md5sum := ""
+	if info.IsDir() {
+		size = 0
+	} else {
+		md5sum, _ = md5Cache.GetMD5FromCache(fullPath, info)
+	}
+
+	return FileMetadata{
+		Name:    info.Name(),
+		Path:    rel,
+		Size:    size,
+		ModTime: info.ModTime(),
+		MD5:     md5sum,
+		IsDir:   info.IsDir(),
+		Inode:   fileInode(info),
+	}, nil
+}
+
+// writeJSONFile 将值编码为JSON并原子写入指定文件（用于内部元数据持久化）
+// 数据同步到磁盘后才重命名，断电后读取者只会看到旧文件或完整的新文件
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = commitTempFile(tmp, path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// readJSONFile 读取并解码JSON文件，文件不存在时返回 os.ErrNotExist
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// isCorruptJSON 判断 readJSONFile 返回的错误是否表示文件内容损坏（而不是读取失败）
+func isCorruptJSON(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
+}
+
+// quarantineFile 将损坏的元数据文件重命名为 <文件名>.corrupt-<时间戳>，保留原内容以便人工恢复
+// 重命名后加载时不会再读取该文件
+func quarantineFile(path string, cause error) error {
+	target := fmt.Sprintf("%s.corrupt-%d", path, time.Now().Unix())
+	if err := os.Rename(path, target); err != nil {
+		return err
+	}
+	log.Printf("Moved corrupt %s to %s: %v", path, target, cause)
+	return nil
+}
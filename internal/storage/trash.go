@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"lfs/internal/interfaces"
+)
+
+const (
+	// TrashDirName 回收站在内部数据目录下的目录名
+	TrashDirName = "trash"
+
+	// trashInfoFile 条目元数据的文件名
+	trashInfoFile = "info.json"
+
+	// trashEntryName 条目目录中保存被删除内容的名称
+	trashEntryName = "item"
+)
+
+// 回收站的每个条目保存在内部数据目录下的 trash/<id> 中：info.json 记录原路径和删除时间，
+// item 是移动过来的文件或目录。先写元数据再移动，中断时只会留下没有内容的条目，由清空回收站时删除
+
+// trashDir 返回回收站目录
+func trashDir(storagePath string) string {
+	return filepath.Join(storagePath, InternalDirName, TrashDirName)
+}
+
+// trashEntryDir 返回条目目录，ID 不合法时返回 ErrTrashItemNotFound
+func trashEntryDir(storagePath, id string) (string, error) {
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return "", interfaces.ErrTrashItemNotFound
+	}
+	return filepath.Join(trashDir(storagePath), id), nil
+}
+
+// newTrashID 生成随机的条目ID
+func newTrashID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// TrashFile 将文件移入回收站（不能用于目录）
+func TrashFile(storagePath, filename string) (interfaces.TrashItem, error) {
+	fullPath, rel, err := ResolvePath(storagePath, filename)
+	if err != nil {
+		return interfaces.TrashItem{}, err
+	}
+	if rel == "" {
+		return interfaces.TrashItem{}, interfaces.ErrInvalidPath
+	}
+
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return interfaces.TrashItem{}, err
+	}
+	if info.IsDir() {
+		return interfaces.TrashItem{}, errors.New("is a directory: " + rel)
+	}
+	return moveToTrash(storagePath, fullPath, rel, info)
+}
+
+// TrashDirectory 将目录移入回收站，recursive 为 false 时目录必须为空
+func TrashDirectory(storagePath, dirPath string, recursive bool) (interfaces.TrashItem, error) {
+	fullPath, rel, err := ResolvePath(storagePath, dirPath)
+	if err != nil {
+		return interfaces.TrashItem{}, err
+	}
+	// 不允许删除存储根目录
+	if rel == "" {
+		return interfaces.TrashItem{}, interfaces.ErrInvalidPath
+	}
+
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return interfaces.TrashItem{}, err
+	}
+	if !info.IsDir() {
+		return interfaces.TrashItem{}, errors.New("not a directory: " + rel)
+	}
+	if !recursive {
+		entries, err := os.ReadDir(fullPath)
+		if err != nil {
+			return interfaces.TrashItem{}, err
+		}
+		if len(entries) > 0 {
+			return interfaces.TrashItem{}, interfaces.ErrDirectoryNotEmpty
+		}
+	}
+	return moveToTrash(storagePath, fullPath, rel, info)
+}
+
+// moveToTrash 为文件或目录创建回收站条目并把它移动进去
+func moveToTrash(storagePath, fullPath, rel string, info os.FileInfo) (interfaces.TrashItem, error) {
+	id, err := newTrashID()
+	if err != nil {
+		return interfaces.TrashItem{}, err
+	}
+	entryDir := filepath.Join(trashDir(storagePath), id)
+
+	item := interfaces.TrashItem{
+		ID:        id,
+		Path:      rel,
+		IsDir:     info.IsDir(),
+		Size:      info.Size(),
+		DeletedAt: time.Now(),
+	}
+	if item.IsDir {
+		item.Size, _ = dirUsage(fullPath)
+	}
+
+	if err := writeJSONFile(filepath.Join(entryDir, trashInfoFile), item); err != nil {
+		os.RemoveAll(entryDir)
+		return interfaces.TrashItem{}, err
+	}
+	// 回收站与存储目录在同一文件系统上，移动不复制数据
+	if err := os.Rename(fullPath, filepath.Join(entryDir, trashEntryName)); err != nil {
+		os.RemoveAll(entryDir)
+		return interfaces.TrashItem{}, err
+	}
+	return item, nil
+}
+
+// readTrashItem 读取条目元数据，内容已不存在的条目视为不存在
+func readTrashItem(entryDir string) (interfaces.TrashItem, error) {
+	var item interfaces.TrashItem
+	if err := readJSONFile(filepath.Join(entryDir, trashInfoFile), &item); err != nil {
+		return item, err
+	}
+	if _, err := os.Lstat(filepath.Join(entryDir, trashEntryName)); err != nil {
+		return item, err
+	}
+	return item, nil
+}
+
+// ListTrash 返回回收站中的条目，最近删除的在前
+func ListTrash(storagePath string) ([]interfaces.TrashItem, error) {
+	entries, err := os.ReadDir(trashDir(storagePath))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	items := make([]interfaces.TrashItem, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		item, err := readTrashItem(filepath.Join(trashDir(storagePath), entry.Name()))
+		if err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items, nil
+}
+
+// GetTrashItem 返回回收站中的一个条目
+func GetTrashItem(storagePath, id string) (interfaces.TrashItem, error) {
+	entryDir, err := trashEntryDir(storagePath, id)
+	if err != nil {
+		return interfaces.TrashItem{}, err
+	}
+	item, err := readTrashItem(entryDir)
+	if os.IsNotExist(err) {
+		return interfaces.TrashItem{}, interfaces.ErrTrashItemNotFound
+	}
+	return item, err
+}
+
+// RestoreTrash 将条目移回原路径，原路径已被占用时使用不冲突的新名称，返回恢复后的条目
+func RestoreTrash(storagePath, id string) (interfaces.TrashItem, error) {
+	item, err := GetTrashItem(storagePath, id)
+	if err != nil {
+		return item, err
+	}
+	entryDir := filepath.Join(trashDir(storagePath), id)
+
+	// 原路径可能已经不合法，例如父目录被替换为指向存储目录之外的符号链接
+	dest, _, err := ResolvePath(storagePath, item.Path)
+	if err != nil {
+		return item, err
+	}
+	parent := filepath.Dir(dest)
+	if info, err := os.Stat(parent); err == nil && !info.IsDir() {
+		return item, fmt.Errorf("restore %s: parent is not a directory: %w", item.Path, os.ErrExist)
+	}
+	if err := os.MkdirAll(parent, os.ModePerm); err != nil {
+		return item, err
+	}
+
+	dest = availablePath(dest, item.IsDir)
+	if err := os.Rename(filepath.Join(entryDir, trashEntryName), dest); err != nil {
+		return item, err
+	}
+	os.RemoveAll(entryDir)
+
+	rel, err := filepath.Rel(storagePath, dest)
+	if err != nil {
+		return item, err
+	}
+	item.Path = filepath.ToSlash(rel)
+	return item, nil
+}
+
+// PurgeTrash 彻底删除回收站中的条目
+func PurgeTrash(storagePath, id string) error {
+	entryDir, err := trashEntryDir(storagePath, id)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(entryDir, trashInfoFile)); err != nil {
+		if os.IsNotExist(err) {
+			return interfaces.ErrTrashItemNotFound
+		}
+		return err
+	}
+	return os.RemoveAll(entryDir)
+}
+
+// EmptyTrash 彻底删除删除时间早于 maxAge 的条目，以及移动中断留下的残缺条目
+func EmptyTrash(ctx context.Context, storagePath string, maxAge time.Duration) (interfaces.CleanupStats, error) {
+	var stats interfaces.CleanupStats
+	cutoff := time.Now().Add(-maxAge)
+
+	entries, err := os.ReadDir(trashDir(storagePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return stats, nil
+		}
+		return stats, err
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		entryDir := filepath.Join(trashDir(storagePath), entry.Name())
+		item, err := readTrashItem(entryDir)
+		if err != nil {
+			// 残缺条目可能正在创建，按目录的修改时间判断
+			if info, statErr := entry.Info(); statErr != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+		} else if item.DeletedAt.After(cutoff) {
+			continue
+		}
+
+		size, _ := dirUsage(entryDir)
+		if err := os.RemoveAll(entryDir); err != nil {
+			return stats, err
+		}
+		stats.TrashItems++
+		stats.FreedBytes += size
+	}
+	return stats, nil
+}
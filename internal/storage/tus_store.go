@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"lfs/internal/interfaces"
+)
+
+// TusDirName tus 上传在内部数据目录下的目录名
+const TusDirName = "tus"
+
+// TusStore tus 上传的持久化存储
+// 每个上传的元数据保存在内部数据目录下的 tus/<id>.info 中，数据保存在 tus/<id>.bin，
+// 删除上传时一并删除
+type TusStore struct {
+	dir     string
+	uploads map[string]interfaces.TusUpload // 上传ID -> 上传
+	mutex   sync.RWMutex
+}
+
+// NewTusStore 创建 tus 上传存储，并从磁盘加载已有的上传
+func NewTusStore(storagePath string) (*TusStore, error) {
+	s := &TusStore{
+		dir:     filepath.Join(storagePath, InternalDirName, TusDirName),
+		uploads: make(map[string]interfaces.TusUpload),
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".info" {
+			continue
+		}
+		var upload interfaces.TusUpload
+		infoPath := filepath.Join(s.dir, entry.Name())
+		if err := readJSONFile(infoPath, &upload); err != nil {
+			// 损坏的记录移到一旁并跳过，对应的上传数据由清理任务删除
+			if isCorruptJSON(err) {
+				if err := quarantineFile(infoPath, err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, err
+		}
+		if upload.ID == "" {
+			continue
+		}
+		s.uploads[upload.ID] = upload
+	}
+
+	return s, nil
+}
+
+// Create 保存新上传
+func (s *TusStore) Create(upload interfaces.TusUpload) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.persist(upload); err != nil {
+		return err
+	}
+	s.uploads[upload.ID] = upload
+	return nil
+}
+
+// Get 根据ID获取上传
+func (s *TusStore) Get(id string) (interfaces.TusUpload, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	upload, exists := s.uploads[id]
+	if !exists {
+		return interfaces.TusUpload{}, interfaces.ErrTusUploadNotFound
+	}
+	return upload, nil
+}
+
+// Update 保存上传的最新状态
+func (s *TusStore) Update(upload interfaces.TusUpload) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.uploads[upload.ID]; !exists {
+		return interfaces.ErrTusUploadNotFound
+	}
+	if err := s.persist(upload); err != nil {
+		return err
+	}
+	s.uploads[upload.ID] = upload
+	return nil
+}
+
+// List 返回所有上传，按创建时间和ID排序
+func (s *TusStore) List() []interfaces.TusUpload {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	uploads := make([]interfaces.TusUpload, 0, len(s.uploads))
+	for _, upload := range s.uploads {
+		uploads = append(uploads, upload)
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].CreatedAt.Equal(uploads[j].CreatedAt) {
+			return uploads[i].ID < uploads[j].ID
+		}
+		return uploads[i].CreatedAt.Before(uploads[j].CreatedAt)
+	})
+	return uploads
+}
+
+// Delete 删除上传的元数据和数据文件
+func (s *TusStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.uploads[id]; !exists {
+		return interfaces.ErrTusUploadNotFound
+	}
+
+	if err := os.Remove(filepath.Join(s.dir, id+".bin")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.dir, id+".info")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(s.uploads, id)
+	return nil
+}
+
+// persist 将上传元数据写回磁盘（调用方需持有写锁）
+func (s *TusStore) persist(upload interfaces.TusUpload) error {
+	return writeJSONFile(filepath.Join(s.dir, upload.ID+".info"), upload)
+}
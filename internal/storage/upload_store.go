@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"lfs/internal/interfaces"
+)
+
+// UploadsDirName 上传会话在内部数据目录下的目录名
+const UploadsDirName = "uploads"
+
+// uploadSessionFile 会话元数据的文件名，与分片数据保存在同一目录
+const uploadSessionFile = "session.json"
+
+// UploadStore 上传会话的持久化存储
+// 每个会话保存在内部数据目录下的 uploads/<id>/session.json 中，分片数据也放在该目录，
+// 删除会话时一并删除
+type UploadStore struct {
+	dir      string
+	sessions map[string]interfaces.UploadSession // 会话ID -> 会话
+	mutex    sync.RWMutex
+}
+
+// NewUploadStore 创建上传会话存储，并从磁盘加载未完成的会话
+func NewUploadStore(storagePath string) (*UploadStore, error) {
+	s := &UploadStore{
+		dir:      filepath.Join(storagePath, InternalDirName, UploadsDirName),
+		sessions: make(map[string]interfaces.UploadSession),
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		var session interfaces.UploadSession
+		sessionPath := filepath.Join(s.dir, entry.Name(), uploadSessionFile)
+		if err := readJSONFile(sessionPath, &session); err != nil {
+			// 没有元数据的目录是创建过程中中断留下的，跳过
+			if os.IsNotExist(err) {
+				continue
+			}
+			// 损坏的元数据移到一旁并跳过，会话目录由清理任务删除
+			if isCorruptJSON(err) {
+				if err := quarantineFile(sessionPath, err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, err
+		}
+		if session.ID == "" {
+			continue
+		}
+		s.sessions[session.ID] = session
+	}
+
+	return s, nil
+}
+
+// Create 保存新会话
+func (s *UploadStore) Create(session interfaces.UploadSession) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.persist(session); err != nil {
+		return err
+	}
+	s.sessions[session.ID] = session
+	return nil
+}
+
+// Get 根据ID获取会话
+func (s *UploadStore) Get(id string) (interfaces.UploadSession, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return interfaces.UploadSession{}, interfaces.ErrUploadNotFound
+	}
+	return session, nil
+}
+
+// MarkChunk 将分片标记为已接收
+func (s *UploadStore) MarkChunk(id string, index int) (interfaces.UploadSession, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return interfaces.UploadSession{}, interfaces.ErrUploadNotFound
+	}
+	if index < 0 || index >= len(session.Received) {
+		return interfaces.UploadSession{}, interfaces.ErrChunkOutOfRange
+	}
+
+	received := []byte(session.Received)
+	received[index] = '1'
+	session.Received = string(received)
+	session.UpdatedAt = time.Now().UTC()
+
+	if err := s.persist(session); err != nil {
+		return interfaces.UploadSession{}, err
+	}
+	s.sessions[id] = session
+	return session, nil
+}
+
+// List 返回所有会话，按创建时间和ID排序
+func (s *UploadStore) List() []interfaces.UploadSession {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	sessions := make([]interfaces.UploadSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].CreatedAt.Equal(sessions[j].CreatedAt) {
+			return sessions[i].ID < sessions[j].ID
+		}
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions
+}
+
+// Delete 删除会话及其分片数据
+func (s *UploadStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.sessions[id]; !exists {
+		return interfaces.ErrUploadNotFound
+	}
+
+	if err := os.RemoveAll(filepath.Join(s.dir, id)); err != nil {
+		return err
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+// persist 将会话元数据写回磁盘（调用方需持有写锁）
+func (s *UploadStore) persist(session interfaces.UploadSession) error {
+	return writeJSONFile(filepath.Join(s.dir, session.ID, uploadSessionFile), session)
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"lfs/internal/interfaces"
+)
+
+// ScanUsage 统计存储目录下每个顶层目录、根目录文件和内部数据目录的大小，顶层目录按名称排序
+// 内部数据不包括暂存的分片（合并前按声明的总大小检查配额，计入会重复）和去重对象（与文件共享数据，已按文件计入）
+func ScanUsage(ctx context.Context, storagePath string) (interfaces.StorageUsage, error) {
+	usage := interfaces.StorageUsage{
+		Directories: []interfaces.DirectoryUsage{},
+		ScannedAt:   time.Now(),
+	}
+	entries, err := os.ReadDir(storagePath)
+	if err != nil {
+		return usage, err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return usage, err
+		}
+		fullPath := filepath.Join(storagePath, entry.Name())
+		switch {
+		case entry.Name() == InternalDirName:
+			usage.InternalBytes = internalUsage(fullPath)
+		case entry.IsDir():
+			size, _ := dirUsage(fullPath)
+			usage.Directories = append(usage.Directories, interfaces.DirectoryUsage{
+				Path:      entry.Name(),
+				UsedBytes: size,
+			})
+			usage.UsedBytes += size
+		default:
+			if info, err := entry.Info(); err == nil {
+				usage.RootFilesBytes += info.Size()
+			}
+		}
+	}
+	usage.UsedBytes += usage.RootFilesBytes + usage.InternalBytes
+	return usage, nil
+}
+
+// internalUsage 返回内部数据目录的大小，不包括暂存的分片和去重对象
+func internalUsage(internalDir string) int64 {
+	entries, err := os.ReadDir(internalDir)
+	if err != nil {
+		return 0
+	}
+	var size int64
+	for _, entry := range entries {
+		if entry.Name() == ChunksDirName || entry.Name() == DedupDirName {
+			continue
+		}
+		n, _ := dirUsage(filepath.Join(internalDir, entry.Name()))
+		size += n
+	}
+	return size
+}
@@ -0,0 +1,532 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lfs/internal/interfaces"
+	"lfs/pkg/hashing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// VersionsDirName 版本历史在内部数据目录下的目录名
+	VersionsDirName = "versions"
+
+	// versionIndexFile 版本索引文件名
+	versionIndexFile = "index.json"
+
+	// versionIndexVersion 索引文件格式版本
+	versionIndexVersion = 1
+)
+
+// versionRecord 索引中的一个历史版本
+type versionRecord struct {
+	interfaces.FileVersion
+	Object string `json:"object"` // objects 目录下保存内容的文件名
+}
+
+// versionHistory 一个文件的版本历史
+type versionHistory struct {
+	Next     int             `json:"next"`     // 下一个版本号
+	Versions []versionRecord `json:"versions"` // 按版本号升序
+}
+
+// versionIndex 索引文件内容，键为相对存储根目录的路径（使用 / 分隔）
+type versionIndex struct {
+	Version int                        `json:"version"`
+	Files   map[string]*versionHistory `json:"files"`
+}
+
+// pendingVersion 覆盖前保存的内容，覆盖成功后才记入索引
+type pendingVersion struct {
+	rel    string
+	object string
+	record versionRecord
+}
+
+// VersionedStorage wraps a Storage and keeps the previous content of a file as a
+// version whenever the file is overwritten.
+//
+// Versions live under .lfs/versions/objects and are listed in .lfs/versions/index.json
+// by path. Content that is replaced atomically is kept by hard linking the old file,
+// so no data is copied; content modified in place (appends) is copied first. Each
+// new version prunes the history of its file down to keep entries, and PruneVersions
+// drops versions older than maxAge. Histories follow renames and moves and outlive
+// deleted files until they expire.
+type VersionedStorage struct {
+	interfaces.Storage
+
+	storagePath string
+	objectsDir  string
+	indexPath   string
+	keep        int
+	maxAge      time.Duration
+
+	mutex sync.Mutex
+	files map[string]*versionHistory
+}
+
+// NewVersionedStorage wraps base with version history and loads the version index.
+// Index entries whose content is missing are dropped and unreferenced content is deleted.
+func NewVersionedStorage(base interfaces.Storage, storagePath string, keep int, maxAge time.Duration) (*VersionedStorage, error) {
+	versionsDir := filepath.Join(storagePath, InternalDirName, VersionsDirName)
+	v := &VersionedStorage{
+		Storage:     base,
+		storagePath: storagePath,
+		objectsDir:  filepath.Join(versionsDir, "objects"),
+		indexPath:   filepath.Join(versionsDir, versionIndexFile),
+		keep:        keep,
+		maxAge:      maxAge,
+		files:       make(map[string]*versionHistory),
+	}
+	if err := os.MkdirAll(v.objectsDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	var index versionIndex
+	if err := readJSONFile(v.indexPath, &index); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	changed := false
+	referenced := make(map[string]bool)
+	for rel, history := range index.Files {
+		if history == nil {
+			changed = true
+			continue
+		}
+		kept := history.Versions[:0]
+		for _, record := range history.Versions {
+			if _, err := os.Stat(filepath.Join(v.objectsDir, record.Object)); err != nil {
+				changed = true
+				continue
+			}
+			referenced[record.Object] = true
+			kept = append(kept, record)
+		}
+		history.Versions = kept
+		v.files[rel] = history
+	}
+
+	// Content left behind by a crash between saving it and updating the index
+	entries, err := os.ReadDir(v.objectsDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !referenced[entry.Name()] {
+			os.Remove(filepath.Join(v.objectsDir, entry.Name()))
+		}
+	}
+
+	if changed {
+		v.mutex.Lock()
+		err = v.saveLocked()
+		v.mutex.Unlock()
+	}
+	return v, err
+}
+
+// SaveFile saves an uploaded file. A new upload that overwrites an existing file keeps
+// the old content as a version and reports it in the result; resumed uploads (a Range
+// starting after 0) continue the same content, and the other conflict policies never
+// replace the existing file.
+func (v *VersionedStorage) SaveFile(ctx context.Context, targetDir string, file *multipart.FileHeader, rangeHeader string, conflict interfaces.ConflictPolicy) (interfaces.SaveResult, error) {
+	var pending *pendingVersion
+	if start, err := parseRangeStart(rangeHeader); err == nil && start == 0 && conflict == interfaces.ConflictOverwrite {
+		if pending, err = v.capture(path.Join(targetDir, file.Filename), false); err != nil {
+			return interfaces.SaveResult{}, err
+		}
+	}
+	result, err := v.Storage.SaveFile(ctx, targetDir, file, rangeHeader, conflict)
+	result.Versioned = v.finish(pending, err == nil && !result.Skipped)
+	return result, err
+}
+
+// SaveFileChunk saves a file chunk. The content replaced by the merged file is kept
+// as a version; it is captured only when the upload overwrites and the chunk may be
+// the last one missing.
+func (v *VersionedStorage) SaveFileChunk(ctx context.Context, chunkInfo interfaces.FileChunkInfo, file *multipart.FileHeader) (*interfaces.ChunkMergeReport, error) {
+	var pending *pendingVersion
+	if chunkInfo.Conflict == interfaces.ConflictOverwrite &&
+		receivedChunks(v.storagePath, chunkInfo.FileName, chunkInfo.TotalChunk) >= chunkInfo.TotalChunk-1 {
+		var err error
+		if pending, err = v.capture(chunkInfo.FileName, false); err != nil {
+			return nil, err
+		}
+	}
+	report, err := v.Storage.SaveFileChunk(ctx, chunkInfo, file)
+	if v.finish(pending, err == nil && report != nil && !report.Skipped) {
+		report.Versioned = true
+	}
+	return report, err
+}
+
+// WriteFile atomically replaces a file and keeps its previous content as a version.
+func (v *VersionedStorage) WriteFile(ctx context.Context, filePath string, data io.Reader) error {
+	pending, err := v.capture(filePath, false)
+	if err != nil {
+		return err
+	}
+	err = v.Storage.WriteFile(ctx, filePath, data)
+	v.finish(pending, err == nil)
+	return err
+}
+
+// WriteFileRange writes into a file in place, keeping a copy of its previous content as a version.
+func (v *VersionedStorage) WriteFileRange(ctx context.Context, filePath string, start int64, data io.Reader) error {
+	pending, err := v.capture(filePath, true)
+	if err != nil {
+		return err
+	}
+	err = v.Storage.WriteFileRange(ctx, filePath, start, data)
+	v.finish(pending, err == nil)
+	return err
+}
+
+// CommitPartialFile replaces the target with a completed ranged upload and keeps
+// the previous content as a version.
+func (v *VersionedStorage) CommitPartialFile(ctx context.Context, filePath string, size int64) error {
+	pending, err := v.capture(filePath, false)
+	if err != nil {
+		return err
+	}
+	err = v.Storage.CommitPartialFile(ctx, filePath, size)
+	v.finish(pending, err == nil)
+	return err
+}
+
+// RenameFile renames a file or directory in place together with its version history.
+func (v *VersionedStorage) RenameFile(ctx context.Context, filename, newName string) error {
+	if err := v.Storage.RenameFile(ctx, filename, newName); err != nil {
+		return err
+	}
+	v.moveTree(cleanRelPath(filename), cleanRelPath(filepath.Join(filepath.Dir(filename), newName)))
+	return nil
+}
+
+// MoveFile moves a file or directory together with its version history.
+func (v *VersionedStorage) MoveFile(ctx context.Context, srcPath, dstPath string) error {
+	if err := v.Storage.MoveFile(ctx, srcPath, dstPath); err != nil {
+		return err
+	}
+	v.moveTree(cleanRelPath(srcPath), cleanRelPath(dstPath))
+	return nil
+}
+
+// ListVersions returns the versions of a file, newest first.
+func (v *VersionedStorage) ListVersions(ctx context.Context, filePath string) ([]interfaces.FileVersion, error) {
+	full, rel, err := ResolvePath(v.storagePath, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if rel == "" {
+		return nil, interfaces.ErrInvalidPath
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	history := v.files[rel]
+	if history == nil || len(history.Versions) == 0 {
+		info, err := os.Stat(full)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, interfaces.ErrInvalidPath
+		}
+	}
+
+	versions := make([]interfaces.FileVersion, 0)
+	if history != nil {
+		for i := len(history.Versions) - 1; i >= 0; i-- {
+			versions = append(versions, history.Versions[i].FileVersion)
+		}
+	}
+	return versions, nil
+}
+
+// DownloadVersion sends a version of a file with Range and conditional request support.
+// Versions never change, so the ETag is derived from the recorded size and modification time.
+func (v *VersionedStorage) DownloadVersion(ctx context.Context, c *gin.Context, filePath string, version int, rangeHeader string) error {
+	f, record, err := v.openVersion(filePath, version)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	etag := interfaces.FileMetadata{
+		ModTime: record.ModTime,
+		Size:    record.Size,
+	}.ETag()
+	return serveFile(c, f, path.Base(cleanRelPath(filePath)), record.Size, record.ModTime, etag, rangeHeader)
+}
+
+// RestoreVersion writes a version back over the file. The current content is kept
+// as a new version first, so a restore can itself be undone.
+func (v *VersionedStorage) RestoreVersion(ctx context.Context, filePath string, version int) error {
+	f, _, err := v.openVersion(filePath, version)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return v.WriteFile(ctx, filePath, f)
+}
+
+// PruneVersions applies the retention policy to every history. Histories of deleted
+// files are dropped once their last version has expired.
+func (v *VersionedStorage) PruneVersions(ctx context.Context) (interfaces.CleanupStats, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	var stats interfaces.CleanupStats
+	now := time.Now()
+	for rel, history := range v.files {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		stats.Add(v.pruneLocked(history, now))
+		if len(history.Versions) == 0 {
+			if _, err := os.Stat(v.fullPath(rel)); os.IsNotExist(err) {
+				delete(v.files, rel)
+			}
+		}
+	}
+	if stats.Versions == 0 {
+		return stats, nil
+	}
+	return stats, v.saveLocked()
+}
+
+// fullPath returns the absolute path of a relative path.
+func (v *VersionedStorage) fullPath(rel string) string {
+	return filepath.Join(v.storagePath, filepath.FromSlash(rel))
+}
+
+// openVersion opens the content of a version.
+func (v *VersionedStorage) openVersion(filePath string, version int) (*os.File, versionRecord, error) {
+	_, rel, err := ResolvePath(v.storagePath, filePath)
+	if err != nil {
+		return nil, versionRecord{}, err
+	}
+
+	v.mutex.Lock()
+	record, ok := v.findLocked(rel, version)
+	v.mutex.Unlock()
+	if !ok {
+		return nil, versionRecord{}, interfaces.ErrVersionNotFound
+	}
+
+	// The version may have been pruned since it was looked up
+	f, err := os.Open(filepath.Join(v.objectsDir, record.Object))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, versionRecord{}, interfaces.ErrVersionNotFound
+	}
+	return f, record, err
+}
+
+// findLocked returns a version of a file. The caller must hold the mutex.
+func (v *VersionedStorage) findLocked(rel string, version int) (versionRecord, bool) {
+	history := v.files[rel]
+	if history == nil {
+		return versionRecord{}, false
+	}
+	for _, record := range history.Versions {
+		if record.Version == version {
+			return record, true
+		}
+	}
+	return versionRecord{}, false
+}
+
+// capture saves the current content of a file before it is overwritten. The content is
+// hard linked unless inPlace is set (the write modifies the file itself) or linking fails. Missing and empty files have
+// nothing worth keeping and return nil; so do invalid paths, which the wrapped
+// storage rejects itself.
+func (v *VersionedStorage) capture(filePath string, inPlace bool) (*pendingVersion, error) {
+	full, rel, err := ResolvePath(v.storagePath, filePath)
+	if err != nil || rel == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(full)
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+		return nil, nil
+	}
+
+	tmp, err := os.CreateTemp(v.objectsDir, "v-*")
+	if err != nil {
+		return nil, err
+	}
+	object := tmp.Name()
+	sums, missing := md5Cache.GetHashesFromCache(full, info, writeHashAlgorithms)
+
+	linked := false
+	if !inPlace {
+		tmp.Close()
+		os.Remove(object)
+		if err := os.Link(full, object); err == nil {
+			linked = true
+		} else if tmp, err = os.Create(object); err != nil {
+			return nil, err
+		}
+	}
+	if !linked {
+		var hasher *hashing.MultiHash
+		if len(missing) > 0 {
+			hasher = newWriteHasher()
+		}
+		err := copyVersionContent(tmp, full, hasher)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(object)
+			return nil, err
+		}
+		if hasher != nil {
+			sums = hasher.Sums()
+		}
+	}
+
+	return &pendingVersion{
+		rel:    rel,
+		object: object,
+		record: versionRecord{
+			FileVersion: interfaces.FileVersion{
+				Size:    info.Size(),
+				MD5:     sums[hashing.MD5],
+				SHA256:  sums[hashing.SHA256],
+				ModTime: info.ModTime(),
+			},
+			Object: filepath.Base(object),
+		},
+	}, nil
+}
+
+// copyVersionContent copies a file into dst, hashing it when hasher is not nil.
+func copyVersionContent(dst io.Writer, src string, hasher *hashing.MultiHash) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if hasher != nil {
+		dst = io.MultiWriter(dst, hasher)
+	}
+	_, err = io.Copy(dst, in)
+	return err
+}
+
+// finish records a captured version once the file has been overwritten, or deletes it
+// when the write failed and the file still holds that content. It reports whether a
+// version was recorded.
+func (v *VersionedStorage) finish(pending *pendingVersion, overwritten bool) bool {
+	if pending == nil {
+		return false
+	}
+	if !overwritten {
+		os.Remove(pending.object)
+		return false
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	history := v.files[pending.rel]
+	if history == nil {
+		history = &versionHistory{Next: 1}
+		v.files[pending.rel] = history
+	}
+	record := pending.record
+	record.Version = history.Next
+	record.CreatedAt = time.Now()
+	history.Next++
+	history.Versions = append(history.Versions, record)
+	v.pruneLocked(history, record.CreatedAt)
+
+	// The file was written successfully, a failed index update only loses this version
+	if err := v.saveLocked(); err != nil {
+		log.Printf("Failed to save version index: %v", err)
+	}
+	return true
+}
+
+// pruneLocked removes the versions of a history beyond the newest keep and those
+// created before maxAge. The caller must hold the mutex.
+func (v *VersionedStorage) pruneLocked(history *versionHistory, now time.Time) interfaces.CleanupStats {
+	var stats interfaces.CleanupStats
+	cutoff := now.Add(-v.maxAge)
+	excess := len(history.Versions) - v.keep
+
+	kept := history.Versions[:0]
+	for i, record := range history.Versions {
+		if i < excess || record.CreatedAt.Before(cutoff) {
+			if err := os.Remove(filepath.Join(v.objectsDir, record.Object)); err == nil || os.IsNotExist(err) {
+				stats.Versions++
+				stats.FreedBytes += record.Size
+				continue
+			}
+		}
+		kept = append(kept, record)
+	}
+	history.Versions = kept
+	return stats
+}
+
+// moveTree moves the histories of a file or of all files under a directory to a new path.
+// When the destination already has a history (of a file deleted earlier), the moved
+// versions are appended to it with new version numbers.
+func (v *VersionedStorage) moveTree(oldRel, newRel string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	var moved []string
+	for rel := range v.files {
+		if rel == oldRel || strings.HasPrefix(rel, oldRel+"/") {
+			moved = append(moved, rel)
+		}
+	}
+	if len(moved) == 0 {
+		return
+	}
+	sort.Strings(moved)
+
+	for _, rel := range moved {
+		history := v.files[rel]
+		delete(v.files, rel)
+		target := newRel + strings.TrimPrefix(rel, oldRel)
+		existing := v.files[target]
+		if existing == nil {
+			v.files[target] = history
+			continue
+		}
+		for _, record := range history.Versions {
+			record.Version = existing.Next
+			existing.Next++
+			existing.Versions = append(existing.Versions, record)
+		}
+		v.pruneLocked(existing, time.Now())
+	}
+	if err := v.saveLocked(); err != nil {
+		log.Printf("Failed to save version index: %v", err)
+	}
+}
+
+// saveLocked writes the version index. The caller must hold the mutex.
+func (v *VersionedStorage) saveLocked() error {
+	return writeJSONFile(v.indexPath, versionIndex{Version: versionIndexVersion, Files: v.files})
+}
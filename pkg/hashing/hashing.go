@@ -0,0 +1,121 @@
+// Package hashing provides the digest algorithms supported for file integrity checks
+// and computes several of them in a single pass over the data.
+package hashing
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"lfs/internal/interfaces"
+
+	"github.com/zeebo/blake3"
+)
+
+// Supported algorithm names.
+const (
+	MD5    = "md5"
+	SHA1   = "sha1"
+	SHA256 = "sha256"
+	BLAKE3 = "blake3"
+	CRC32C = "crc32c"
+)
+
+// Algorithms lists every supported algorithm.
+var Algorithms = []string{MD5, SHA1, SHA256, BLAKE3, CRC32C}
+
+// aliases maps alternative spellings, such as the RFC 9530 names, to algorithm names.
+var aliases = map[string]string{
+	"sha-1":   SHA1,
+	"sha":     SHA1,
+	"sha-256": SHA256,
+}
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// New returns a hash for the named algorithm.
+// CRC32C sums are big-endian, so their hex form matches the usual notation.
+func New(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case MD5:
+		return md5.New(), nil
+	case SHA1:
+		return sha1.New(), nil
+	case SHA256:
+		return sha256.New(), nil
+	case BLAKE3:
+		return blake3.New(), nil
+	case CRC32C:
+		return crc32.New(castagnoliTable), nil
+	default:
+		return nil, interfaces.ErrUnsupportedHashAlgorithm
+	}
+}
+
+// Normalize lowercases algorithm names, resolves aliases and drops duplicates and blanks.
+// It fails if any algorithm is not supported.
+func Normalize(algorithms []string) ([]string, error) {
+	seen := make(map[string]bool, len(algorithms))
+	result := make([]string, 0, len(algorithms))
+	for _, name := range algorithms {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if alias, ok := aliases[name]; ok {
+			name = alias
+		}
+		if _, err := New(name); err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
+
+// MultiHash writes data to several hashes at once.
+type MultiHash struct {
+	hashes map[string]hash.Hash
+	w      io.Writer
+}
+
+// NewMulti returns a MultiHash computing every algorithm in algorithms.
+func NewMulti(algorithms []string) (*MultiHash, error) {
+	m := &MultiHash{hashes: make(map[string]hash.Hash, len(algorithms))}
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, name := range algorithms {
+		if _, ok := m.hashes[name]; ok {
+			continue
+		}
+		h, err := New(name)
+		if err != nil {
+			return nil, err
+		}
+		m.hashes[name] = h
+		writers = append(writers, h)
+	}
+	m.w = io.MultiWriter(writers...)
+	return m, nil
+}
+
+// Write adds p to every hash.
+func (m *MultiHash) Write(p []byte) (int, error) {
+	return m.w.Write(p)
+}
+
+// Sums returns the hex-encoded digest of every algorithm.
+func (m *MultiHash) Sums() map[string]string {
+	sums := make(map[string]string, len(m.hashes))
+	for name, h := range m.hashes {
+		sums[name] = hex.EncodeToString(h.Sum(nil))
+	}
+	return sums
+}